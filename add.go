@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	registerCommand("add", "Add bookmarks for the URLs given, or found in the clipboard, to the Bookmarks file", addCmd)
+}
+
+// "add" command
+func addCmd(args []string) error {
+	fs := newFlagSet("add", "[URL...]")
+	opts := newImportOptions(fs)
+
+	var clipboard bool
+
+	fs.BoolVar(&clipboard, "from-clipboard", false, "Add the URLs found in the system clipboard")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	folder := new(Folder)
+
+	for _, arg := range fs.Args() {
+		if !isWebURL(arg) {
+			return fmt.Errorf("Invalid URL %q", arg)
+		}
+
+		folder.Links = append(folder.Links, &Link{URL: arg})
+	}
+
+	if clipboard {
+		text, err := readClipboard()
+
+		if err != nil {
+			return err
+		}
+
+		n := len(folder.Links)
+
+		for _, s := range strings.Fields(text) {
+			if isWebURL(s) {
+				folder.Links = append(folder.Links, &Link{URL: s})
+			}
+		}
+
+		if len(folder.Links) == n {
+			return errors.New("No URLs in the clipboard")
+		}
+	}
+
+	if len(folder.Links) == 0 {
+		return errors.New("No URLs to add")
+	}
+
+	return opts.add(&Folder{Folders: []*Folder{folder}, container: true})
+}
+
+// commands printing the clipboard contents, tried in order
+var clipboardCommands = [][]string{
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-out"},
+	{"xsel", "--clipboard", "--output"},
+	{"pbpaste"},
+	{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+}
+
+// reads the clipboard using the first of the commands available
+func readClipboard() (string, error) {
+	var last error
+
+	for _, cmd := range clipboardCommands {
+		path, err := exec.LookPath(cmd[0])
+
+		if err != nil {
+			continue
+		}
+
+		// wl-paste fails outside of Wayland, and xclip without X, so the next command is tried
+		out, err := exec.Command(path, cmd[1:]...).Output()
+
+		if err == nil {
+			return string(out), nil
+		}
+
+		last = fmt.Errorf("%s: %s", cmd[0], err)
+	}
+
+	if last != nil {
+		return "", last
+	}
+
+	return "", errors.New("No clipboard tool found, please install wl-clipboard, xclip or xsel")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	defer func(cmds [][]string) { clipboardCommands = cmds }(clipboardCommands)
+
+	clipboardCommands = [][]string{
+		{"no-such-clipboard-tool"},
+		{"false"},
+		{"echo", "see https://a.com/x and\nhttps://example.com/ or ftp://b.com/"},
+	}
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+
+	if err := ioutil.WriteFile(name, []byte(testBookmarks("v1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := addCmd([]string{"-q", "-i", name, "--yes", "--folder", "Bar/Later", "not-a-url"}); err == nil {
+		t.Fatal("Invalid URL accepted")
+	}
+
+	if err := addCmd([]string{"-q", "-i", name, "--yes", "--folder", "Bar/Later", "--from-clipboard", "https://c.com/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := loadTree(name, readOptions{strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"Bar/v1 <https://example.com/>",
+		"Bar/Later/c.com <https://c.com/>",
+		"Bar/Later/a.com / x <https://a.com/x>",
+	}
+
+	if lines := linkLines(root); !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	clipboardCommands = [][]string{{"echo", "nothing"}}
+
+	if err := addCmd([]string{"-q", "-i", name, "--yes", "--from-clipboard"}); err == nil {
+		t.Fatal("Missing error")
+	}
+}
@@ -0,0 +1,335 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func init() {
+	registerCommand("archive", "Submit bookmark URLs to the Internet Archive (Wayback Machine)", archiveCmd)
+}
+
+// "archive" command
+func archiveCmd(args []string) error {
+	// command line parameters
+	opts := newOptions()
+	fs := newFlagSet("archive", "")
+
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+	opts.networkFlags(fs)
+
+	var stateName string
+
+	fs.StringVar(&stateName, "state", defaultArchiveState(),
+		"File recording the snapshot URL of every archived bookmark, also used for resuming interrupted runs "+
+			"(default location is $XDG_DATA_HOME/"+programName+"/archive.json)")
+
+	var delay time.Duration
+
+	fs.DurationVar(&delay, "delay", 5*time.Second, "Minimum delay between submissions")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(stateName) == 0 {
+		return errors.New("State file location is unknown, please specify --state")
+	}
+
+	if delay <= 0 {
+		return errors.New("Invalid delay: " + delay.String())
+	}
+
+	// read bookmarks
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	// read state
+	state, err := loadArchiveState(stateName)
+
+	if err != nil {
+		return err
+	}
+
+	// save the state on interrupt; the handler is removed on return, so as not to
+	// outlive the command when run by the daemon
+	sig, done := make(chan os.Signal, 1), make(chan struct{})
+
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	defer func() {
+		signal.Stop(sig)
+		close(done)
+	}()
+
+	go func() {
+		select {
+		case <-sig:
+		case <-done:
+			return
+		}
+
+		if err := state.flush(); err != nil {
+			die(err)
+		}
+
+		os.Exit(1)
+	}()
+
+	// submit
+	links := state.pending(webLinks(roots))
+
+	logInfo("%d links to archive", len(links))
+
+	client := opts.newWebClient()
+	ticker := time.NewTicker(delay)
+
+	defer ticker.Stop()
+
+	var failed int
+	var mu sync.Mutex
+
+	err = forEachLink(links, opts.concurrency, func(link *Link) error {
+		<-ticker.C
+
+		snapshot, err := waybackSave(client, link.URL)
+
+		if err != nil {
+			logWarn("%s: %s", link.URL, err)
+
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			return nil
+		}
+
+		logInfo("%s -> %s", link.URL, snapshot)
+		return state.put(link.URL, snapshot)
+	})
+
+	if e := state.flush(); e != nil && err == nil {
+		err = e
+	}
+
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("Failed to archive %d out of %d links", failed, len(links))
+	}
+
+	return err
+}
+
+// Wayback Machine location, variable for testing
+var waybackHost = "https://web.archive.org"
+
+// submits the URL to the Wayback Machine "Save Page Now" service, returns the snapshot URL
+func waybackSave(client *webClient, link string) (string, error) {
+	req, err := http.NewRequest("GET", waybackHost, nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	// the target is carried verbatim, including its query; the fragment
+	// cannot be sent as is, so it is escaped
+	req.URL.Opaque = "/save/" + strings.Replace(link, "#", "%23", -1)
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close()
+
+	// snapshot location is either in the header, or in the URL we have been redirected to
+	if loc := resp.Header.Get("Content-Location"); strings.HasPrefix(loc, "/web/") {
+		return waybackHost + loc, nil
+	}
+
+	if r := resp.Request.URL; strings.HasPrefix(r.Path, "/web/") {
+		return waybackHost + r.RequestURI(), nil
+	}
+
+	return "", errors.New("Snapshot location is not found in the response")
+}
+
+// archiving state, saved periodically and at the end of the run
+type archiveState struct {
+	name      string
+	lock      sync.Mutex
+	snapshots map[string]archiveRecord
+	unsaved   int
+}
+
+type archiveRecord struct {
+	Snapshot string    `json:"snapshot"`
+	Archived time.Time `json:"archived"`
+}
+
+// number of new records triggering a save
+const archiveSaveInterval = 50
+
+// $XDG_DATA_HOME/opera-bookmarks/archive.json, or empty string if the data directory is unknown
+func defaultArchiveState() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "archive.json")
+	}
+
+	return ""
+}
+
+func loadArchiveState(name string) (*archiveState, error) {
+	state := &archiveState{
+		name:      name,
+		snapshots: make(map[string]archiveRecord),
+	}
+
+	file, err := os.Open(name)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&state.snapshots); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return state, nil
+}
+
+// links not archived yet
+func (state *archiveState) pending(links []*Link) (res []*Link) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	for _, link := range links {
+		if _, ok := state.snapshots[link.URL]; !ok {
+			res = append(res, link)
+		}
+	}
+
+	return
+}
+
+func (state *archiveState) put(link, snapshot string) error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.snapshots[link] = archiveRecord{snapshot, time.Now().UTC()}
+
+	if state.unsaved++; state.unsaved < archiveSaveInterval {
+		return nil
+	}
+
+	return state.save()
+}
+
+func (state *archiveState) flush() error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	if state.unsaved == 0 {
+		return nil
+	}
+
+	return state.save()
+}
+
+// must be called with the lock held
+func (state *archiveState) save() error {
+	if err := writeJSONFile(state.name, state.snapshots); err != nil {
+		return err
+	}
+
+	state.unsaved = 0
+	return nil
+}
+
+// writes the value as indented JSON
+func writeJSONFile(name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(name, data)
+}
+
+// writes the file via a temporary one, creating the directory if needed
+func writeFileAtomic(name string, data []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return
+	}
+
+	tmp := name + ".tmp"
+
+	err = ioutil.WriteFile(tmp, data, 0644)
+
+	// keep the permissions of the existing file, like 0600 of the Bookmarks file
+	if info, e := os.Stat(name); err == nil && e == nil {
+		err = os.Chmod(tmp, info.Mode().Perm())
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+	}
+
+	return
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWaybackSave(t *testing.T) {
+	const target = "https://example.com/page?a=1&b=2#frag"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/save/https://example.com/page?a=1&b=2%23frag":
+			w.Header().Set("Content-Location", "/web/20240101000000/https://example.com/page?a=1&b=2")
+		case "/save/https://example.com/redirect":
+			// not http.Redirect, because it cleans up the path
+			w.Header().Set("Location", "/web/20240102000000/https://example.com/redirect")
+			w.WriteHeader(http.StatusFound)
+		case "/web/20240102000000/https://example.com/redirect":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+	defer func(host string) { waybackHost = host }(waybackHost)
+
+	waybackHost = srv.URL
+	client := newWebClient()
+
+	// Content-Location header
+	snapshot, err := waybackSave(client, target)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := srv.URL + "/web/20240101000000/https://example.com/page?a=1&b=2"; snapshot != exp {
+		t.Fatalf("Unexpected snapshot: %q instead of %q", snapshot, exp)
+	}
+
+	// redirect
+	if snapshot, err = waybackSave(client, "https://example.com/redirect"); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := srv.URL + "/web/20240102000000/https://example.com/redirect"; snapshot != exp {
+		t.Fatalf("Unexpected snapshot: %q instead of %q", snapshot, exp)
+	}
+
+	// no location
+	if _, err = waybackSave(client, "https://example.com/missing"); err == nil {
+		t.Fatal("Missing error")
+	}
+}
+
+func TestArchiveStateResume(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sub", "archive.json")
+	state, err := loadArchiveState(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = state.put("https://example.com/", "https://web.archive.org/web/1/https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is written until flush
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("Unexpected state file: %v", err)
+	}
+
+	if err = state.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(name + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Temporary file left behind: %v", err)
+	}
+
+	// resume
+	if state, err = loadArchiveState(name); err != nil {
+		t.Fatal(err)
+	}
+
+	links := state.pending([]*Link{{URL: "https://example.com/"}, {URL: "https://example.org/"}})
+
+	if len(links) != 1 || links[0].URL != "https://example.org/" {
+		t.Fatalf("Unexpected pending links: %v", links)
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/gnuflag"
+)
+
+func init() {
+	registerCommand("backup", "Save a timestamped snapshot of the Bookmarks file, removing old snapshots", backupCmd)
+	registerCommand("restore", "Put a snapshot back in place of the Bookmarks file (lists snapshots if none given)", restoreCmd)
+}
+
+// options common to "backup" and "restore"
+type backupOptions struct {
+	*options
+	dir string
+}
+
+func newBackupOptions(fs *gnuflag.FlagSet) *backupOptions {
+	opts := &backupOptions{options: newOptions()}
+
+	fs.StringVar(&opts.dir, "dir", defaultBackupDir(),
+		"Snapshot directory (default location is $XDG_DATA_HOME/"+programName+"/backups)")
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+
+	return opts
+}
+
+// parses command line and returns the Bookmarks file name
+func (opts *backupOptions) parse(fs *gnuflag.FlagSet, args []string) (string, error) {
+	if err := opts.options.parse(fs, args); err != nil {
+		return "", err
+	}
+
+	if len(opts.dir) == 0 {
+		return "", errors.New("Snapshot directory location is unknown, please specify --dir")
+	}
+
+	if len(opts.inputs) > 1 {
+		return "", errors.New("Only one input file is allowed")
+	}
+
+	if name := opts.inputs[0].name; name != stdin {
+		return name, nil
+	}
+
+	return "", errors.New("Cannot use STDIN with snapshots")
+}
+
+func defaultBackupDir() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "backups")
+	}
+
+	return ""
+}
+
+// "backup" command
+func backupCmd(args []string) error {
+	fs := newFlagSet("backup", "")
+	opts := newBackupOptions(fs)
+
+	var daily, weekly int
+
+	fs.IntVar(&daily, "keep-daily", 7, "Number of most recent days to keep the last snapshot of")
+	fs.IntVar(&weekly, "keep-weekly", 4, "Number of most recent weeks to keep the last snapshot of")
+
+	name, err := opts.parse(fs, args)
+
+	if err != nil {
+		return err
+	}
+
+	if err = noArgs(fs); err != nil {
+		return err
+	}
+
+	if daily < 0 || weekly < 0 {
+		return errors.New("Invalid number of snapshots to keep")
+	}
+
+	snap, err := makeSnapshot(opts.dir, name, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	if len(snap) > 0 {
+		logInfo("saved snapshot %s", snap)
+	} else {
+		logInfo("%s is unchanged since the last snapshot", name)
+	}
+
+	snaps, err := listSnapshots(opts.dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, s := range expiredSnapshots(snaps, daily, weekly) {
+		logInfo("removing snapshot %s", s.name)
+
+		if err = os.Remove(filepath.Join(opts.dir, s.name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// "restore" command
+func restoreCmd(args []string) error {
+	fs := newFlagSet("restore", "[SNAPSHOT | latest]")
+	opts := newBackupOptions(fs)
+	opts.writeBackFlags(fs)
+
+	name, err := opts.parse(fs, args)
+
+	if err != nil {
+		return err
+	}
+
+	snaps, err := listSnapshots(opts.dir)
+
+	if err != nil {
+		return err
+	}
+
+	switch fs.NArg() {
+	case 0:
+		for _, s := range snaps {
+			fmt.Println(s.name + "\t" + s.time.Format("2006-01-02 15:04:05"))
+		}
+
+		return nil
+	case 1:
+	default:
+		return errors.New("Too many arguments")
+	}
+
+	snap := fs.Arg(0)
+
+	if snap == "latest" {
+		if len(snaps) == 0 {
+			return errors.New("No snapshots in " + opts.dir)
+		}
+
+		snap = snaps[0].name
+	} else if _, ok := parseSnapshotName(snap); !ok || snap != filepath.Base(snap) {
+		return fmt.Errorf("Invalid snapshot name %q", snap)
+	}
+
+	data, err := loadRawData(filepath.Join(opts.dir, snap))
+
+	if err != nil {
+		return err
+	}
+
+	// show the changes
+	var from, to *Folder
+
+	if to, err = loadTree(filepath.Join(opts.dir, snap), readOptions{}); err != nil {
+		return err
+	}
+
+	if from, err = loadTree(name, readOptions{}); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if apply, err := opts.confirm(treeChanges(from, to)); !apply {
+		return err
+	}
+
+	// keep the current file, in case the wrong snapshot is restored
+	if prev, err := makeSnapshot(opts.dir, name, time.Now()); err != nil && !os.IsNotExist(err) {
+		return err
+	} else if len(prev) > 0 {
+		logNotice("the current file is saved as snapshot %s", prev)
+	}
+
+	// a snapshot may have been edited by hand, so its checksum is recomputed
+	if err = data.save(name); err != nil {
+		return err
+	}
+
+	logNotice("restored %s from %s; Opera must not be running while the file is replaced", name, snap)
+	return nil
+}
+
+// bookmarks snapshot
+type snapshot struct {
+	name string
+	time time.Time
+}
+
+const (
+	snapshotPrefix     = "Bookmarks-"
+	snapshotTimeLayout = "20060102-150405"
+)
+
+func parseSnapshotName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, snapshotPrefix) {
+		return time.Time{}, false
+	}
+
+	ts, err := time.ParseInLocation(snapshotTimeLayout, name[len(snapshotPrefix):], time.Local)
+
+	return ts, err == nil
+}
+
+// lists snapshots in the directory, most recent first
+func listSnapshots(dir string) ([]snapshot, error) {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+
+		return nil, err
+	}
+
+	var snaps []snapshot
+
+	for _, file := range files {
+		if ts, ok := parseSnapshotName(file.Name()); ok && file.Mode().IsRegular() {
+			snaps = append(snaps, snapshot{name: file.Name(), time: ts})
+		}
+	}
+
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].time.After(snaps[j].time) })
+	return snaps, nil
+}
+
+// copies the file into the directory, unless it is the same as the latest snapshot;
+// returns the name of the new snapshot, or empty string if nothing has been saved
+func makeSnapshot(dir, name string, now time.Time) (string, error) {
+	data, err := ioutil.ReadFile(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	snaps, err := listSnapshots(dir)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(snaps) > 0 {
+		last, err := ioutil.ReadFile(filepath.Join(dir, snaps[0].name))
+
+		if err != nil {
+			return "", err
+		}
+
+		if bytes.Equal(data, last) {
+			return "", nil
+		}
+	}
+
+	// the new snapshot must be the most recent one, and never overwrite an existing snapshot
+	if len(snaps) > 0 && !now.Truncate(time.Second).After(snaps[0].time) {
+		now = snaps[0].time.Add(time.Second)
+	}
+
+	snap := snapshotPrefix + now.Format(snapshotTimeLayout)
+
+	return snap, writeFileAtomic(filepath.Join(dir, snap), data)
+}
+
+// selects snapshots to remove: of the most recent snapshots only the last one per day is kept
+// for the given number of days, and the last one per week for the given number of weeks;
+// the most recent snapshot is always kept
+func expiredSnapshots(snaps []snapshot, daily, weekly int) (expired []snapshot) {
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	for i, s := range snaps {
+		keep := i == 0
+
+		if day := s.time.Format("2006-01-02"); !days[day] && len(days) < daily {
+			days[day], keep = true, true
+		}
+
+		year, week := s.time.ISOWeek()
+
+		if key := fmt.Sprintf("%d-%d", year, week); !weeks[key] && len(weeks) < weekly {
+			weeks[key], keep = true, true
+		}
+
+		if !keep {
+			expired = append(expired, s)
+		}
+	}
+
+	return
+}
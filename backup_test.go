@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local) // Friday
+	var snaps []snapshot
+
+	// two snapshots a day for 30 days, most recent first
+	for i := 0; i < 60; i++ {
+		ts := now.Add(-time.Duration(i) * 12 * time.Hour)
+		snaps = append(snaps, snapshot{name: ts.Format(snapshotTimeLayout), time: ts})
+	}
+
+	kept := make(map[string]bool)
+
+	for _, s := range snaps {
+		kept[s.name] = true
+	}
+
+	for _, s := range expiredSnapshots(snaps, 3, 2) {
+		delete(kept, s.name)
+	}
+
+	exp := map[string]bool{
+		"20240315-120000": true, // today, and this week
+		"20240315-000000": false,
+		"20240314-120000": true,
+		"20240313-120000": true,
+		"20240310-120000": true, // Sunday, last week
+	}
+
+	for name, keep := range exp {
+		if kept[name] != keep {
+			t.Errorf("%s: expected kept=%v", name, keep)
+		}
+	}
+
+	if len(kept) != 4 {
+		t.Errorf("Unexpected number of kept snapshots: %d", len(kept))
+	}
+
+	if len(expiredSnapshots(snaps[:1], 0, 0)) != 0 {
+		t.Error("The most recent snapshot is removed")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	snapDir := filepath.Join(dir, "backups")
+
+	if err := ioutil.WriteFile(name, []byte(testBookmarks("v1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "--dir", snapDir, "-i", name}
+
+	if err := backupCmd(args); err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged file is not saved again
+	if err := backupCmd(args); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err := listSnapshots(snapDir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snaps) != 1 {
+		t.Fatalf("Unexpected snapshots: %v", snaps)
+	}
+
+	if err = ioutil.WriteFile(name, []byte(testBookmarks("v2")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is written without --yes
+	if err = restoreCmd(append(args, snaps[0].name)); err == nil {
+		t.Fatal("Restored without confirmation")
+	}
+
+	if err = restoreCmd(append(args, "--dry-run", snaps[0].name)); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ioutil.ReadFile(name); err != nil || string(data) != testBookmarks("v2") {
+		t.Fatalf("Modified by dry run: %q, %v", data, err)
+	}
+
+	if err = restoreCmd(append(args, "--yes", snaps[0].name)); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := loadTree(name, readOptions{strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := linkLines(root); len(lines) != 1 || lines[0] != "Bar/v1 <https://example.com/>" {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	// v2 has been saved before restoring
+	if snaps, err = listSnapshots(snapDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snaps) != 2 {
+		t.Fatalf("Unexpected snapshots: %v", snaps)
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(snapDir, snaps[0].name)); err != nil || string(data) != testBookmarks("v2") {
+		t.Fatalf("Unexpected content: %q, %v", data, err)
+	}
+
+	if err = restoreCmd(append(args, "--yes", "../Bookmarks")); err == nil {
+		t.Fatal("Invalid snapshot name accepted")
+	}
+}
+
+// minimal Bookmarks file with one link
+func testBookmarks(name string) string {
+	return `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [{"type": "url", "name": "` + name + `", "url": "https://example.com/", "id": "2", "date_added": "0"}]}}}`
+}
@@ -30,136 +30,1046 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/juju/gnuflag"
 )
 
 func main() {
+	name, args := "export", os.Args[1:]
+
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		} else if strings.HasPrefix(args[0], "chrome-extension://") {
+			name = "native-host" // started by the browser
+		}
+	}
+
+	if err := commands[name].run(args); err != nil {
+		die(err)
+	}
+}
+
+// command registry
+type command struct {
+	about string
+	run   func(args []string) error
+}
+
+type commandMap map[string]*command
+
+var commands = make(commandMap)
+
+func registerCommand(name, about string, run func([]string) error) {
+	commands[name] = &command{about, run}
+}
+
+// finds command by its full name, like "push pocket"
+func findCommand(name string) *command {
+	if i := strings.IndexByte(name, ' '); i > 0 && name[:i] == "push" {
+		return pushTargets[name[i+1:]]
+	}
+
+	return commands[name]
+}
+
+// prints sorted list of commands
+func (cmds commandMap) list(w io.Writer) {
+	names := make([]string, 0, len(cmds))
+
+	for n := range cmds {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", n, cmds[n].about)
+	}
+}
+
+func init() {
+	registerCommand("export", "Convert bookmarks to HTML or other formats (default command)", exportCmd)
+	registerCommand("convert", "Convert bookmarks from one format to another, without any processing", convertCmd)
+	registerFormat(exportFunc{"html", foldersToHTML})
+}
+
+// "export" command
+func exportCmd(args []string) error {
+	start := time.Now()
+
 	// command line parameters
-	inputName, outputName := parseCmdLine()
+	opts := newOptions()
+	fs := newFlagSet("export", "")
+
+	opts.inputFlags(fs)
+	opts.outputFlags(fs)
+	opts.treeFlags(fs)
+	opts.pageFlags(fs)
+	opts.networkFlags(fs)
+	opts.emailFlags(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	// read all inputs
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	// printout
+	//printFolder(roots[0], 0)
+	if err = writeFolders(opts, topFolders(roots)); err != nil {
+		return err
+	}
+
+	if len(opts.searchIndex) > 0 {
+		if err = writeSearchIndex(opts.searchIndex, topFolders(roots)); err != nil {
+			return err
+		}
+	}
+
+	logInfo("written %s in %s", opts.outputName, time.Since(start))
+	return nil
+}
+
+// "convert" command
+func convertCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("convert", "")
+
+	opts.inputFlags(fs)
+	opts.outputFlags(fs)
+	fs.StringVar(&opts.from, "from", opts.from, "Input format: "+readerNames())
+	fs.StringVar(&opts.format, "to", "html", "Output format, same as --format")
+	fs.BoolVar(&opts.headings, "headings", false, "Put links of Markdown input in folders by their headings")
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	return writeFolders(opts, topFolders(roots))
+}
+
+// each input becomes a separate top-level section, unless there is only one
+func topFolders(roots []*Folder) []*Folder {
+	if len(roots) > 1 {
+		return roots
+	}
+
+	return roots[0].Folders
+}
+
+// command line parameters processor
+const (
+	stdout = "-"
+	stdin  = "-"
+)
+
+const programName = "opera-bookmarks"
+
+type options struct {
+	inputs              inputList
+	from                string // input format
+	outputName, format  string
+	output              outputMode
+	showDates, compress bool
+	verbose, quiet      bool
+	concurrency         int
+	history, sortBy     string
+	collate             string // language of name collation
+	refreshTitles       string
+	descriptions        bool
+	thumbnails, browser string
+	snapshots           string // directory of pages saved by "snapshot" command
+	embedFavicons       bool
+	faviconCache        string
+	favicons            map[string]string // data URIs of site icons, by origin
+	qr, encrypt         bool
+	dryRun, yes         bool
+	readOptions
+	normalizeNames       bool
+	deriveTitles         bool
+	cleanURLs            bool
+	mergeFolders         bool
+	onlyBookmarklets     bool
+	noBookmarklets       bool
+	toc                  bool
+	azIndex              bool
+	domains              bool
+	searchIndex          string
+	email                emailOptions
+	maxDepth             int
+	depthSummary         bool
+	maxPerFolder         int
+	anchors              map[*Folder]string // HTML folder anchor ids
+	stripParams          stringList
+	paths                stringList
+	query                string
+	tagRules             string    // file of link rules
+	projection           *query    // --query with "select"
+	rootNames            stringMap // display names of the roots, by key
+	templateFile         string
+	lang                 string
+	passFile, passphrase string
+	pageCache            string
+	cacheMaxAge          time.Duration
+	perHostDelay         time.Duration
+	perHostMax           int
+	userAgent            string
+	proxy                string
+	timeout              time.Duration
+	retries              int
+	retryDelay           time.Duration
+	headers              stringList
+	warcName             string
+	warc                 *warcWriter // recorder of HTTP traffic, from --warc
+}
+
+func newOptions() *options {
+	return &options{
+		outputName:  stdout,
+		from:        "opera",
+		format:      "html",
+		lang:        "en",
+		concurrency: defaultConcurrency,
+		pageCache:   defaultPageCache(),
+		cacheMaxAge: 7 * 24 * time.Hour,
+		timeout:     defaultTimeout,
+		rootNames:   make(stringMap),
+	}
+}
+
+// input file with its section label
+type input struct {
+	label, name string
+}
+
+// list of inputs, one per --input flag
+type inputList []input
+
+func (list *inputList) String() string {
+	names := make([]string, len(*list))
+
+	for i, in := range *list {
+		names[i] = in.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// accepts either "pathname" or "label=pathname"
+func (list *inputList) Set(s string) error {
+	in := input{label: s, name: s}
+
+	// the label cannot look like a directory, so file names like "/tmp/a=b/Bookmarks" are taken as is
+	if i := strings.IndexByte(s, '='); i > 0 && !strings.ContainsAny(s[:i], "/"+string(os.PathSeparator)) {
+		if _, err := os.Stat(s); err != nil {
+			in.label, in.name = s[:i], s[i+1:]
+		}
+	}
+
+	if len(in.name) == 0 {
+		return errors.New("Empty input file name")
+	}
+
+	if in.name == stdin {
+		for _, prev := range *list {
+			if prev.name == stdin {
+				return errors.New("STDIN can only be read once")
+			}
+		}
+
+		if in.label == stdin {
+			in.label = "STDIN"
+		}
+	}
+
+	*list = append(*list, in)
+	return nil
+}
+
+// list of strings from a repeated flag
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ", ")
+}
+
+func (list *stringList) Set(s string) error {
+	*list = append(*list, s)
+	return nil
+}
+
+// "key=value" pairs from a flag, comma separated, and the flag may be repeated
+type stringMap map[string]string
+
+func (m stringMap) String() string {
+	pairs := make([]string, 0, len(m))
+
+	for key, val := range m {
+		pairs = append(pairs, key+"="+val)
+	}
+
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m stringMap) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.IndexByte(pair, '=')
+
+		if i <= 0 {
+			return fmt.Errorf("Invalid pair %q, expected \"key=value\"", pair)
+		}
+
+		m[pair[:i]] = pair[i+1:]
+	}
+
+	return nil
+}
+
+// command line error handling, the daemon continues on errors in the commands it runs
+var flagErrors = gnuflag.ExitOnError
+
+// when set, options.parse() returns errParsed instead of letting the command run
+var parseOnly bool
+
+var errParsed = errors.New("Command line parsed")
+
+// makes a flag set for the command
+func newFlagSet(name, argsHelp string) *gnuflag.FlagSet {
+	fs := gnuflag.NewFlagSet(name, flagErrors)
+
+	fs.Usage = func() {
+		if name == "export" {
+			os.Stderr.WriteString("Usage: " + programName + " [COMMAND] [OPTIONS]\n\nCommands:\n")
+			commands.list(os.Stderr)
+			os.Stderr.WriteString("\nOptions for \"export\":\n")
+		} else {
+			os.Stderr.WriteString(strings.TrimSpace("Usage: "+programName+" "+name+" [OPTIONS] "+argsHelp) + "\n" +
+				findCommand(name).about + "\n\nOptions:\n")
+		}
+
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// flag groups
+func (opts *options) inputFlags(fs *gnuflag.FlagSet) {
+	const help = "Bookmarks file pathname, optionally prefixed with \"label=\" (\"-\" for STDIN; may be repeated)"
+
+	fs.Var(&opts.inputs, "input", help)
+	fs.Var(&opts.inputs, "i", help)
+	fs.BoolVar(&opts.strict, "strict", false, "Fail on Bookmarks file checksum mismatch, instead of a warning")
+	fs.BoolVar(&opts.lenient, "lenient", false,
+		"Skip malformed nodes of the Bookmarks file, like those without name or URL, instead of failing")
+	fs.BoolVar(&opts.allErrors, "all-errors", false,
+		"Report all malformed nodes in the Bookmarks file, instead of stopping at the first one")
+}
+
+const outputHelp = "Output file pathname (\"-\" for STDOUT)"
+
+func (opts *options) outputFlags(fs *gnuflag.FlagSet) {
+	fs.StringVar(&opts.outputName, "output", stdout, outputHelp)
+	fs.StringVar(&opts.outputName, "o", stdout, outputHelp)
+	opts.forceFlag(fs)
+	fs.BoolVar(&opts.output.backup, "backup", false, "Keep the previous output file, if any, as NAME.bak")
+	fs.BoolVar(&opts.output.append, "output-append", false, "Append to the output file, if it exists (jsonl and csv formats only)")
+	fs.StringVar(&opts.format, "format", "html", "Output format: "+formatNames())
+	fs.StringVar(&opts.format, "f", "html", "Output format: "+formatNames())
+	fs.Var(opts.rootNames, "root-names", "Display names of the roots, like \"bookmark_bar=Toolbar,trash=Deleted\"")
+	fs.StringVar(&opts.templateFile, "template-file", "",
+		"Go text/template file for the output (implies --format template)")
+	fs.StringVar(&opts.lang, "lang", "en", "Language of HTML output, one of: "+languageNames())
+	fs.BoolVar(&opts.showDates, "show-dates", false, "Show bookmark dates in the output")
+	fs.BoolVar(&opts.toc, "toc", false, "Add table of contents to HTML output")
+	fs.BoolVar(&opts.azIndex, "az-index", false, "Add A-Z index of all links by name to HTML output")
+	fs.BoolVar(&opts.domains, "domains", false, "Add a weighted list of web sites, with links per site, to HTML output, or web sites to DOT graph")
+	fs.BoolVar(&opts.qr, "qr", false, "Show QR code for every link in HTML output")
+	fs.StringVar(&opts.snapshots, "snapshots", "", "Link the pages saved by \"snapshot\" command to this directory from HTML output")
+	fs.StringVar(&opts.searchIndex, "search-index", "", "Also write JSON list of all links, to be indexed by lunr.js or the like, to this file")
+	fs.BoolVar(&opts.compress, "compress", false, "Compress output with gzip (implied by .gz file name extension)")
+	fs.BoolVar(&opts.encrypt, "encrypt", false, "Encrypt output with a passphrase (see \"decrypt\" command)")
+	fs.StringVar(&opts.passFile, "passphrase-file", "", passphraseHelp)
+}
+
+func (opts *options) treeFlags(fs *gnuflag.FlagSet) {
+	fs.BoolVar(&opts.onlyBookmarklets, "bookmarklets-only", false, "Only keep bookmarklets (\"javascript:\" links)")
+	fs.BoolVar(&opts.noBookmarklets, "no-bookmarklets", false, "Remove bookmarklets (\"javascript:\" links)")
+	fs.IntVar(&opts.maxDepth, "max-depth", 0, "Only export this many levels of folders (default is no limit)")
+	fs.BoolVar(&opts.depthSummary, "depth-summary", false,
+		"With --max-depth, show the number of links in omitted sub-folders in HTML and Markdown output")
+	fs.IntVar(&opts.maxPerFolder, "max-per-folder", 0,
+		"Only export this many most recently added links from every folder (default is no limit)")
+	fs.Var(&opts.paths, "path",
+		"Only keep links with the folder path and name matching this pattern, like \"Bookmarks bar/News/*\" (may be repeated)")
+	fs.StringVar(&opts.query, "query", "",
+		"Filter, sort and select links with a query like 'added > 2024-01-01 && host == github.com | sort -added'")
+	fs.StringVar(&opts.tagRules, "tag-rules", "",
+		"File of rules like \"*.arxiv.org -> Papers\", moving matching links into folders (see README)")
+	fs.BoolVar(&opts.mergeFolders, "merge-folders", false, "Merge sibling folders with the same name")
+	fs.BoolVar(&opts.cleanURLs, "clean-urls", false, "Remove tracking parameters like utm_* or fbclid from URLs")
+	fs.Var(&opts.stripParams, "strip-param",
+		"Also remove this query parameter from URLs, trailing \"*\" matching any suffix (may be repeated; implies --clean-urls)")
+	fs.BoolVar(&opts.deriveTitles, "derive-titles", false, "Make names for unnamed bookmarks from their URLs")
+	fs.BoolVar(&opts.normalizeNames, "normalize-names", false,
+		"Convert bookmark names to Unicode NFC form, and fold any white space into a single space")
+	fs.StringVar(&opts.history, "history", "", "Browser History file to take visit counts from")
+	fs.StringVar(&opts.sortBy, "sort", "", "Sort links by one of: "+sortKeyNames()+"; prefix with \"-\" for descending order")
+	opts.collateFlag(fs)
+}
+
+func (opts *options) collateFlag(fs *gnuflag.FlagSet) {
+	fs.StringVar(&opts.collate, "collate", "",
+		"Sort names by the rules of this language, like \"de\" or \"sv\" (\"und\" for any), instead of by character codes")
+}
+
+func (opts *options) pageFlags(fs *gnuflag.FlagSet) {
+	fs.StringVar(&opts.refreshTitles, "refresh-titles", "",
+		"Fetch bookmarked pages and either \"report\" bookmarks whose names differ from page titles, or \"fix\" the names")
+	fs.BoolVar(&opts.descriptions, "descriptions", false,
+		"Fetch bookmarked pages and show their descriptions under the links in HTML, Markdown and EPUB output")
+	fs.StringVar(&opts.thumbnails, "thumbnails", "",
+		"Directory to capture page thumbnails to, using a headless browser, for \"gallery\" output format")
+	fs.StringVar(&opts.browser, "browser", "", "Headless browser for capturing thumbnails (default is Chromium or Chrome)")
+	fs.StringVar(&opts.pageCache, "page-cache", defaultPageCache(), "File caching information fetched from pages")
+	fs.BoolVar(&opts.embedFavicons, "embed-favicons", false,
+		"Fetch web site icons and embed them into HTML output as data URIs, so it works offline")
+	fs.StringVar(&opts.faviconCache, "favicon-cache", defaultFaviconCache(), "Directory caching the web site icons")
+	fs.DurationVar(&opts.cacheMaxAge, "cache-max-age", 7*24*time.Hour, "Maximum age of cached page information")
+}
+
+func (opts *options) forceFlag(fs *gnuflag.FlagSet) {
+	fs.BoolVar(&opts.output.force, "force", false, "Replace the output file if it exists")
+}
+
+func (opts *options) logFlags(fs *gnuflag.FlagSet) {
+	fs.BoolVar(&opts.verbose, "verbose", false, "Print progress information to STDERR")
+	fs.BoolVar(&opts.verbose, "v", false, "Print progress information to STDERR")
+	fs.BoolVar(&opts.quiet, "quiet", false, "Suppress all messages except errors")
+	fs.BoolVar(&opts.quiet, "q", false, "Suppress all messages except errors")
+}
+
+func (opts *options) networkFlags(fs *gnuflag.FlagSet) {
+	fs.IntVar(&opts.concurrency, "concurrency", defaultConcurrency, "Number of concurrent network requests")
+	fs.DurationVar(&opts.perHostDelay, "per-host-delay", 0, "Minimum delay between requests to the same web site")
+	fs.IntVar(&opts.perHostMax, "per-host-max", 1, "Maximum number of concurrent requests to the same web site (0 is no limit)")
+	fs.DurationVar(&opts.timeout, "timeout", defaultTimeout, "Timeout of a single HTTP request")
+	fs.IntVar(&opts.retries, "retries", 2, "Number of retries of HTTP requests failed with a network error or a transient status")
+	fs.DurationVar(&opts.retryDelay, "retry-delay", time.Second, "Delay before the first retry, doubled for every next one")
+	fs.StringVar(&opts.proxy, "proxy", "",
+		"Proxy for all HTTP requests, like \"socks5://localhost:9050\" (default is taken from $HTTP_PROXY and $HTTPS_PROXY)")
+	fs.StringVar(&opts.userAgent, "user-agent", "", "User-Agent header of HTTP requests (default is \""+programName+"\")")
+	fs.Var(&opts.headers, "header", "Extra HTTP request header like \"Accept-Language: en\" (may be repeated)")
+}
+
+// parses and validates command line arguments
+func (opts *options) parse(fs *gnuflag.FlagSet, args []string) error {
+	if err := fs.Parse(true, args); err != nil {
+		return err
+	}
+
+	if opts.concurrency < 1 {
+		return errors.New("Invalid concurrency: " + strconv.Itoa(opts.concurrency))
+	}
+
+	if opts.perHostDelay < 0 || opts.perHostMax < 0 {
+		return errors.New("Invalid per host limit")
+	}
+
+	if opts.timeout <= 0 || opts.retries < 0 || opts.retryDelay < 0 {
+		return errors.New("Invalid timeout or retry settings")
+	}
+
+	for _, s := range opts.headers {
+		if _, _, err := parseHeader(s); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.proxy) > 0 {
+		if err := checkProxy(opts.proxy); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case opts.quiet && opts.verbose:
+		return errors.New("Options --quiet and --verbose are mutually exclusive")
+	case opts.quiet:
+		verbosity = levelQuiet
+	case opts.verbose:
+		verbosity = levelVerbose
+	}
+
+	if len(opts.inputs) == 0 {
+		opts.inputs.Set(filepath.Join(os.Getenv("HOME"), ".config", "opera", "Bookmarks"))
+	}
+
+	if _, ok := readers[opts.from]; !ok {
+		return fmt.Errorf("Unknown input format %q", opts.from)
+	}
+
+	if _, ok := languages[opts.lang]; !ok {
+		return fmt.Errorf("Unsupported language %q", opts.lang)
+	}
+
+	if len(opts.templateFile) > 0 {
+		opts.format = "template"
+	}
+
+	if _, ok := formats[opts.format]; !ok {
+		return fmt.Errorf("Unknown output format %q", opts.format)
+	}
+
+	if opts.maxDepth < 0 {
+		return errors.New("Invalid --max-depth: " + strconv.Itoa(opts.maxDepth))
+	}
+
+	if opts.maxPerFolder < 0 {
+		return errors.New("Invalid --max-per-folder: " + strconv.Itoa(opts.maxPerFolder))
+	}
+
+	if opts.onlyBookmarklets && opts.noBookmarklets {
+		return errors.New("Options --bookmarklets-only and --no-bookmarklets are mutually exclusive")
+	}
+
+	switch opts.refreshTitles {
+	case "", "report", "fix":
+	default:
+		return fmt.Errorf("Invalid --refresh-titles mode %q", opts.refreshTitles)
+	}
+
+	if len(opts.sortBy) > 0 {
+		if _, err := parseSortOrder(opts.sortBy, opts.collate); err != nil {
+			return err
+		}
+	}
+
+	// the old name of STDOUT is still accepted
+	if opts.outputName == "STDOUT" {
+		opts.outputName = stdout
+	}
+
+	if strings.HasSuffix(opts.outputName, ".gz") {
+		opts.compress = true
+	}
+
+	if err := opts.checkOutput(); err != nil {
+		return err
+	}
+
+	// the index holds all the links, so it cannot be left unencrypted
+	if len(opts.searchIndex) > 0 && opts.encrypt {
+		return errors.New("Option --search-index cannot be used with --encrypt")
+	}
+
+	if opts.encrypt {
+		var err error
+
+		if opts.passphrase, err = readPassphrase(opts.passFile); err != nil {
+			return err
+		}
+	}
+
+	if parseOnly {
+		return errParsed
+	}
+
+	return nil
+}
+
+// makes sure an existing output file is only replaced or appended to when requested
+func (opts *options) checkOutput() error {
+	out := &opts.output
+
+	if out.append {
+		switch {
+		case opts.format != "jsonl" && opts.format != "csv":
+			return errors.New("Only jsonl and csv output can be appended to")
+		case opts.encrypt || out.backup:
+			return errors.New("Option --output-append cannot be used with --encrypt or --backup")
+		}
+	}
+
+	// the search index is a single JSON document, so it is never appended to
+	if len(opts.searchIndex) > 0 && !out.force {
+		if info, err := os.Stat(opts.searchIndex); err == nil && info.Mode().IsRegular() {
+			return errors.New(opts.searchIndex + ": File exists, please specify --force to replace it")
+		}
+	}
+
+	if isS3URL(opts.outputName) {
+		out.client = opts.newWebClient()
+		return nil
+	}
+
+	if opts.outputName == stdout {
+		return nil
+	}
+
+	info, err := os.Stat(opts.outputName)
+
+	switch {
+	case err != nil || !info.Mode().IsRegular():
+		out.append = false
+	case out.append:
+		out.append = info.Size() > 0
+	case !out.force:
+		return errors.New(opts.outputName + ": File exists, please specify --force to replace it")
+	}
+
+	return nil
+}
+
+// checks if the file is a terminal, rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// checks if the user can be asked questions
+func interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// checks there are no positional arguments
+func noArgs(fs *gnuflag.FlagSet) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("Unexpected argument %q", fs.Arg(0))
+	}
+
+	return nil
+}
+
+// reads all input files
+func (opts *options) loadInputs() ([]*Folder, error) {
+	roots := make([]*Folder, len(opts.inputs))
+
+	for i, in := range opts.inputs {
+		logInfo("reading %s", in.name)
+
+		root, err := readers[opts.from](in.name, opts.readOptions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		root.Name = in.label
+		roots[i] = root
+
+		nf, nl := root.count()
+
+		logInfo("%s: %d folders, %d links", in.label, nf, nl)
+	}
+
+	return roots, nil
+}
+
+// writes folders in the chosen format, and sends them by email if requested
+func writeFolders(opts *options, folders []*Folder) error {
+	write := func(out StringWriter) error {
+		return formats[opts.format].Write(folders, opts, out)
+	}
+
+	if len(opts.email.to) == 0 {
+		return withWriter(opts.outputName, opts.output, opts.compress, opts.passphrase)(write)
+	}
+
+	var buff bytes.Buffer
+
+	if err := writeOutput(&buff, opts.compress, opts.passphrase, write); err != nil {
+		return err
+	}
+
+	// with email, the output file is only written if given explicitly
+	if opts.outputName != stdout {
+		err := withOutput(opts.outputName, opts.output)(func(dest io.Writer) error {
+			_, err := dest.Write(buff.Bytes())
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return opts.sendEmail(buff.Bytes())
+}
+
+// input formats
+var readers = map[string]func(string, readOptions) (*Folder, error){
+	"opera": loadTree, // also Chrome, Chromium, and other browsers of the family
+}
+
+func readerNames() string {
+	names := make([]string, 0, len(readers))
+
+	for name := range readers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// output format
+type exporter interface {
+	Name() string
+	Write(folders []*Folder, opts *options, dest StringWriter) error
+}
+
+// exporter made of a function
+type exportFunc struct {
+	name  string
+	write func([]*Folder, *options, StringWriter) error
+}
+
+func (e exportFunc) Name() string {
+	return e.name
+}
+
+func (e exportFunc) Write(folders []*Folder, opts *options, dest StringWriter) error {
+	return e.write(folders, opts, dest)
+}
+
+// output format registry
+var formats = make(map[string]exporter)
+
+func registerFormat(e exporter) {
+	formats[e.Name()] = e
+}
+
+func formatNames() string {
+	names := make([]string, 0, len(formats))
+
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// string writer
+// it's surprising there is no such interface in the standard library
+type StringWriter interface {
+	WriteString(string) (int, error)
+}
+
+// writer with sticky error
+type textWriter struct {
+	dest StringWriter
+	err  error
+}
+
+func (w *textWriter) write(s string) {
+	if w.err == nil {
+		_, w.err = w.dest.WriteString(s)
+	}
+}
+
+// function writing to the supplied StringWriter instance
+type WriterFunc func(StringWriter) error
+
+// makes a wrapper function for the output writer, encrypting if the passphrase is not empty
+func withWriter(name string, mode outputMode, compress bool, passphrase string) func(WriterFunc) error {
+	open := withOutput(name, mode)
+
+	return func(fn WriterFunc) error {
+		return open(func(dest io.Writer) error {
+			return writeOutput(dest, compress, passphrase, fn)
+		})
+	}
+}
+
+// writes the output, compressing and encrypting as requested
+func writeOutput(dest io.Writer, compress bool, passphrase string, fn WriterFunc) error {
+	if len(passphrase) == 0 {
+		return writeCompressed(dest, compress, fn)
+	}
 
-	// read raw data
-	data, err := loadRawData(inputName)
+	// the whole output is sealed at once
+	var buff bytes.Buffer
 
-	if err != nil {
-		die(err)
+	if err := writeCompressed(&buff, compress, fn); err != nil {
+		return err
 	}
 
-	// create root folder
-	var root *Folder
+	data, err := encryptData(buff.Bytes(), passphrase)
 
-	if root, err = buildTree("roots", data); err != nil {
-		die(err)
+	if err != nil {
+		return err
 	}
 
-	// printout
-	//printFolder(root, 0)
-	if err = writeFolders(outputName, root.Folders); err != nil {
-		die(err)
-	}
+	_, err = dest.Write(data)
+	return err
 }
 
-// command line parameters processor
-const stdout = "STDOUT"
-
-func parseCmdLine() (string, string) {
-	defaultInput := filepath.Join(os.Getenv("HOME"), ".config", "opera", "Bookmarks")
+// calls the writer function with optional gzip compression
+func writeCompressed(dest io.Writer, compress bool, fn WriterFunc) error {
+	if !compress {
+		return writeBuffered(dest, fn)
+	}
 
-	// parse
-	var inputName string
+	gz := gzip.NewWriter(dest)
 
-	gnuflag.StringVar(&inputName, "input", defaultInput, "Bookmarks file pathname")
-	gnuflag.StringVar(&inputName, "i", defaultInput, "Bookmarks file pathname")
+	if err := writeBuffered(gz, fn); err != nil {
+		return err
+	}
 
-	var outputName string
+	return gz.Close()
+}
 
-	gnuflag.StringVar(&outputName, "output", stdout, "Output file pathname")
-	gnuflag.StringVar(&outputName, "o", stdout, "Output file pathname")
-	gnuflag.Parse(false)
+// calls the writer function with a buffered writer on top of the given destination
+func writeBuffered(dest io.Writer, fn WriterFunc) error {
+	w := bufio.NewWriter(dest)
 
-	return inputName, outputName
-}
+	if err := fn(w); err != nil {
+		return err
+	}
 
-// writes folders as html
-func writeFolders(name string, folders []*Folder) error {
-	return withWriter(name)(func(out StringWriter) error {
-		return foldersToHTML(folders, out)
-	})
+	return w.Flush()
 }
 
-// string writer
-// it's surprising there is no such interface in the standard library
-type StringWriter interface {
-	WriteString(string) (int, error)
+// how the output file replaces the existing one, or is uploaded
+type outputMode struct {
+	backup bool       // keep the previous file as NAME.bak
+	append bool       // append to the existing file, which is not empty
+	force  bool       // the existing file may be replaced
+	client *webClient // for uploads to object storage, the default one if nil
 }
 
-// function writing to the supplied StringWriter instance
-type WriterFunc func(StringWriter) error
-
-// makes a wrapper function for the output writer
-func withWriter(name string) func(WriterFunc) error {
+// makes a wrapper function for the output file or STDOUT
+func withOutput(name string, mode outputMode) func(func(io.Writer) error) error {
 	if name == stdout {
-		return func(fn WriterFunc) error {
-			w := bufio.NewWriter(os.Stdout)
+		return func(fn func(io.Writer) error) error {
+			return fn(os.Stdout)
+		}
+	}
+
+	// object storage
+	if isS3URL(name) {
+		return func(fn func(io.Writer) error) error {
+			var buff bytes.Buffer
 
-			if err := fn(w); err != nil {
+			if err := fn(&buff); err != nil {
 				return err
 			}
 
-			return w.Flush()
+			if mode.client == nil {
+				mode.client = newWebClient()
+			}
+
+			return s3Put(mode.client, name, buff.Bytes())
+		}
+	}
+
+	// devices and pipes cannot be replaced
+	if info, err := os.Stat(name); mode.append || err == nil && !info.Mode().IsRegular() {
+		return func(fn func(io.Writer) error) (err error) {
+			var file *os.File
+
+			if file, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+				return
+			}
+
+			defer func() {
+				if e := file.Close(); e != nil && err == nil {
+					err = e
+				}
+			}()
+
+			err = fn(file)
+			return
 		}
 	}
 
-	return func(fn WriterFunc) (err error) {
+	// the output goes to a temporary file first, so that the existing file is only replaced
+	// once the output is complete
+	return func(fn func(io.Writer) error) (err error) {
 		var file *os.File
 
-		if file, err = os.Create(name); err != nil {
+		if file, err = ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp"); err != nil {
 			return
 		}
 
 		defer func() {
-			if e := file.Close(); e != nil && err == nil {
-				err = e
-			}
-
 			if err != nil {
-				os.Remove(name)
+				os.Remove(file.Name())
 			}
 		}()
 
-		err = fn(file)
+		if err = fn(file); err == nil {
+			err = file.Chmod(0644)
+		}
+
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+
+		if err != nil {
+			return
+		}
+
+		if mode.backup {
+			if err = os.Rename(name, name+".bak"); err != nil && !os.IsNotExist(err) {
+				return
+			}
+		}
+
+		err = os.Rename(file.Name(), name)
 		return
 	}
 }
 
-// read raw json data from file
-func loadRawData(name string) (interface{}, error) {
+// options for reading input files
+type readOptions struct {
+	strict    bool // checksum mismatch is an error
+	allErrors bool // report all malformed nodes
+	lenient   bool // skip malformed nodes
+	headings  bool // Markdown links go to folders by headings
+}
+
+// read bookmarks tree from file, or from STDIN if the name is "-"
+func loadTree(name string, ro readOptions) (*Folder, error) {
+	src, label := io.Reader(os.Stdin), "STDIN"
+
+	if name != stdin {
+		file, err := os.Open(name)
+
+		if err != nil {
+			return nil, err // the error message already contains the file name
+		}
+
+		defer file.Close()
+
+		src, label = file, name
+	}
+
+	dec := newTreeDecoder(bufio.NewReader(src), ro)
+	root, sum, err := dec.decode()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", label, err)
+	}
+
+	if n, summary := dec.skippedNodes(); n > 0 {
+		logWarn("%s: skipped %d malformed nodes (%s)", label, n, summary)
+	}
+
+	if len(sum) > 0 && sum != treeChecksum(root, false) && sum != treeChecksum(root, true) {
+		if ro.strict {
+			return nil, errors.New(label + ": Checksum mismatch, the file may be corrupted or edited by hand")
+		}
+
+		logWarn("%s: checksum mismatch, the file may be corrupted or edited by hand", label)
+	}
+
+	return root, nil
+}
+
+// raw json data from Bookmarks file, for writing it back
+type rawData struct {
+	name             string
+	sumValid, sumAll bool // checksum is valid, and computed over all roots
+	Checksum         string
+	Roots            interface{}
+	Version          json.RawMessage
+	Other            map[string]json.RawMessage // other top-level fields, like "sync_metadata"
+}
+
+// read raw json data from file, or from STDIN if the name is "-"
+func loadRawData(name string) (*rawData, error) {
+	if name == stdin {
+		return decodeRawData("STDIN", os.Stdin)
+	}
+
 	file, err := os.Open(name)
 
 	if err != nil {
-		return nil, err
+		return nil, err // the error message already contains the file name
 	}
 
 	defer file.Close()
 
-	var top struct {
-		Roots interface{}
+	return decodeRawData(name, file)
+}
+
+func decodeRawData(name string, src io.Reader) (*rawData, error) {
+	data := &rawData{name: name}
+
+	if err := json.NewDecoder(src).Decode(&data.Other); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	// numbers are kept as they are, for writing back
+	if roots, ok := data.Other["roots"]; ok {
+		dec := json.NewDecoder(bytes.NewReader(roots))
+
+		dec.UseNumber()
+
+		if err := dec.Decode(&data.Roots); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	if sum, ok := data.Other["checksum"]; ok {
+		if err := json.Unmarshal(sum, &data.Checksum); err != nil {
+			return nil, fmt.Errorf("%s: Invalid checksum: %s", name, err)
+		}
 	}
 
-	if err = json.NewDecoder(file).Decode(&top); err != nil {
-		return nil, err
+	data.Version = data.Other["version"]
+
+	for _, key := range []string{"roots", "checksum", "version"} {
+		delete(data.Other, key)
 	}
 
-	return top.Roots, nil
+	data.verify()
+	return data, nil
 }
 
 // build bookmarks tree
@@ -177,8 +1087,9 @@ func buildTree(key string, item interface{}) (*Folder, error) {
 
 // common data for every node
 type Node struct {
-	Name, Key       string
+	Name, Key, ID   string
 	Added, Modified time.Time
+	Meta            map[string]string // "meta_info" string values
 }
 
 // node reader
@@ -186,6 +1097,13 @@ func (node *Node) read(key string, data map[string]interface{}) (err error) {
 	// key
 	node.Key = key
 
+	// id, only used for checksum
+	if node.ID, err = readString("id", data); err != nil {
+		if _, ok := err.(KeyNotFoundError); !ok {
+			return
+		}
+	}
+
 	// name
 	if node.Name, err = readString("name", data); err != nil {
 		return
@@ -200,17 +1118,36 @@ func (node *Node) read(key string, data map[string]interface{}) (err error) {
 	if node.Modified, err = readTimeStamp("date_modified", data); err != nil {
 		if _, ok := err.(KeyNotFoundError); ok {
 			err = nil // ignore error if the key is not found
+		} else {
+			return
 		}
 	}
 
+	// optional meta info
+	node.Meta, err = readMetaInfo("meta_info", data)
+
 	// all done
 	return
 }
 
+// description from meta info, if any
+func (node *Node) description() string {
+	return node.Meta["Description"]
+}
+
+// Opera nickname of the node, if any, also kept in "meta_info"
+func (node *Node) nickname() string {
+	return node.Meta["Nickname"]
+}
+
 // "url" node
 type Link struct {
 	Node
 	URL string
+
+	// from browsing history, if available
+	Visits    int
+	LastVisit time.Time
 }
 
 func makeLink(key string, node map[string]interface{}) (*Link, error) {
@@ -233,6 +1170,11 @@ type Folder struct {
 	Node
 	Links   []*Link
 	Folders []*Folder
+
+	// sub-folders cut off by --max-depth
+	OmittedFolders, OmittedLinks int
+
+	container bool // roots, or container of roots like Opera "custom_root", not a "folder" node
 }
 
 // Folder constructor from an element from "children" list
@@ -270,6 +1212,7 @@ func makeRootFolder(key string, node map[string]interface{}) (*Folder, error) {
 			Name: key,
 			Key:  key,
 		},
+		container: true,
 	}
 
 	// read the folder
@@ -284,6 +1227,14 @@ func makeRootFolder(key string, node map[string]interface{}) (*Folder, error) {
 
 // item dispatcher
 func (root *Folder) add(key string, item interface{}) error {
+	// already built by the stream decoder
+	switch v := item.(type) {
+	case *Folder:
+		return root.addFolder(v, nil)
+	case *Link:
+		return root.addLink(v, nil)
+	}
+
 	// check node type
 	node, ok := item.(map[string]interface{})
 
@@ -291,28 +1242,39 @@ func (root *Folder) add(key string, item interface{}) error {
 		return &ParserError{key, "Unexpected node type"}
 	}
 
-	if t, ok := node["type"]; ok { // child node
-		tt, ok := t.(string)
+	if _, ok := node["type"]; ok { // child node
+		child, err := makeNode(key, node)
 
-		if !ok {
-			return &ParserError{key, "Type tag is not a string"}
+		if err != nil {
+			return err
 		}
 
-		// dispatch on node type
-		switch tt {
-		case "folder":
-			return root.addFolder(makeChildFolder(key, node))
-		case "url":
-			return root.addLink(makeLink(key, node))
-		default:
-			return &ParserError{key, fmt.Sprintf("Unknown type %q", tt)}
-		}
+		return root.add(key, child)
 	}
 
 	// root folder node
 	return root.addFolder(makeRootFolder(key, node))
 }
 
+// makes either *Folder or *Link, depending on the node type
+func makeNode(key string, node map[string]interface{}) (interface{}, error) {
+	tt, ok := node["type"].(string)
+
+	if !ok {
+		return nil, &ParserError{key, "Type tag is not a string"}
+	}
+
+	// dispatch on node type
+	switch tt {
+	case "folder":
+		return makeChildFolder(key, node)
+	case "url":
+		return makeLink(key, node)
+	default:
+		return nil, &ParserError{key, fmt.Sprintf("Unknown type %q", tt)}
+	}
+}
+
 // adders
 func (folder *Folder) addFolder(child *Folder, err error) error {
 	if err == nil {
@@ -330,6 +1292,51 @@ func (folder *Folder) addLink(child *Link, err error) error {
 	return err
 }
 
+// description of the sub-folders cut off by --max-depth, or empty string
+func (folder *Folder) omitted() string {
+	if folder.OmittedFolders == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d more links in %d sub-folders", folder.OmittedLinks, folder.OmittedFolders)
+}
+
+// finds folder by its path like "Bookmarks bar/News", starting from the children of the given roots
+func findFolder(roots []*Folder, path string) (*Folder, error) {
+	for _, root := range roots {
+		if f, err := root.Find(path); err == nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Folder %q is not found", path)
+}
+
+// calls fn for every link in the tree, together with the names of the folders on the path to the link,
+// excluding the folder itself
+func (folder *Folder) walkLinks(path []string, fn func([]string, *Link) error) error {
+	return folder.Walk(func(p []string, node interface{}) error {
+		if link, ok := node.(*Link); ok {
+			return fn(append(path[:len(path):len(path)], p...), link)
+		}
+
+		return nil
+	})
+}
+
+// number of folders and links in the tree, excluding the folder itself
+func (folder *Folder) count() (nf, nl int) {
+	nf, nl = len(folder.Folders), len(folder.Links)
+
+	for _, f := range folder.Folders {
+		cf, cl := f.count()
+		nf += cf
+		nl += cl
+	}
+
+	return
+}
+
 // data conversion error
 type KeyNotFoundError struct {
 	key string
@@ -391,26 +1398,54 @@ func readInt(key string, data map[string]interface{}, bitSize int) (val int64, e
 	return
 }
 
+func readMetaInfo(key string, data map[string]interface{}) (map[string]string, error) {
+	v, ok := data[key]
+
+	if !ok {
+		return nil, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("Tag %q is not an object", key)
+	}
+
+	meta := make(map[string]string, len(m))
+
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			meta[k] = s
+		}
+	}
+
+	return meta, nil
+}
+
 var googleEpoch = time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
 
 func readTimeStamp(key string, data map[string]interface{}) (ts time.Time, err error) {
 	var val int64
 
 	if val, err = readInt(key, data, 64); err == nil {
-		// Google timestamp is the number of microseconds since 01/01/1601 00:00.00
-		// https://stackoverflow.com/questions/37196584/correctly-converting-chrome-timestamp-to-date-using-python
-		ts = googleEpoch
-		// max duration is about 290 years so have to run the loop here:
-		const twoCenturies = 200 * 365 * 24 * 60 * 60 * 1000000 // microseconds
+		ts = googleTime(val)
+	}
 
-		for ; val >= twoCenturies; val -= twoCenturies {
-			ts = ts.Add(time.Duration(twoCenturies * 1000)) // in nanoseconds
-		}
+	return
+}
 
-		ts = ts.Add(time.Duration(val * 1000))
+func googleTime(val int64) time.Time {
+	// Google timestamp is the number of microseconds since 01/01/1601 00:00.00
+	// https://stackoverflow.com/questions/37196584/correctly-converting-chrome-timestamp-to-date-using-python
+	ts := googleEpoch
+	// max duration is about 290 years so have to run the loop here:
+	const twoCenturies = 200 * 365 * 24 * 60 * 60 * 1000000 // microseconds
+
+	for ; val >= twoCenturies; val -= twoCenturies {
+		ts = ts.Add(time.Duration(twoCenturies * 1000)) // in nanoseconds
 	}
 
-	return
+	return ts.Add(time.Duration(val * 1000))
 }
 
 // HTML generator
@@ -435,10 +1470,30 @@ func htmlTag(tag string, fn fhtml) fhtml {
 	return htmlListArgs(htmlRawText("<"+tag+">"), fn, htmlRawText("</"+tag+">"))
 }
 
+func htmlTagID(tag, id string, fn fhtml) fhtml {
+	if len(id) == 0 {
+		return htmlTag(tag, fn)
+	}
+
+	return htmlListArgs(htmlRawText("<"+tag+` id="`+html.EscapeString(id)+`">`), fn, htmlRawText("</"+tag+">"))
+}
+
 func htmlLink(link, text string) fhtml {
-	return htmlRawText(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link), html.EscapeString(text)))
+	return htmlRawText(fmt.Sprintf(`<a href="%s">%s</a>`, htmlHref(link), html.EscapeString(text)))
+}
+
+// link as attribute value; browsers drop tabs and line breaks from URLs, which breaks
+// bookmarklets with "//" comments, so those are percent-encoded
+func htmlHref(link string) string {
+	if isBookmarklet(link) {
+		link = bookmarkletEscaper.Replace(link)
+	}
+
+	return html.EscapeString(link)
 }
 
+var bookmarkletEscaper = strings.NewReplacer("\t", "%09", "\n", "%0A", "\r", "%0D")
+
 func htmlList(fns []fhtml) fhtml {
 	return func(dest StringWriter) (err error) {
 		for _, f := range fns {
@@ -455,11 +1510,53 @@ func htmlListArgs(fns ...fhtml) fhtml {
 	return htmlList(fns)
 }
 
-func folderName(folder *Folder) fhtml {
-	return htmlTag("h4", htmlText(folder.Name))
+func htmlDate(prefix string, ts time.Time, opts *options) fhtml {
+	// zero timestamp in the file maps to the epoch itself
+	if !ts.After(googleEpoch) {
+		return htmlNil
+	}
+
+	return htmlRawText(" <small>" + html.EscapeString(prefix+ts.Local().Format(opts.phrases().dateLayout)) + "</small>")
+}
+
+func folderName(folder *Folder, level int, opts *options) fhtml {
+	tag := "h" + strconv.Itoa(level)
+
+	if !opts.showDates {
+		return htmlTagID(tag, opts.anchors[folder], htmlText(folder.Name))
+	}
+
+	return htmlTagID(tag, opts.anchors[folder], htmlListArgs(htmlText(folder.Name), htmlDate(opts.phrases().modified, folder.Modified, opts)))
+}
+
+func linkItem(lnk *Link, opts *options) fhtml {
+	item := htmlLink(lnk.URL, lnk.Name)
+
+	if img := opts.faviconImg(lnk.URL); len(img) > 0 {
+		item = htmlListArgs(htmlRawText(img), item)
+	}
+
+	if opts.showDates {
+		item = htmlListArgs(item, htmlDate("", lnk.Added, opts))
+	}
+
+	if desc := lnk.description(); opts.descriptions && len(desc) > 0 {
+		item = htmlListArgs(item, htmlTag("p", htmlText(desc)))
+	}
+
+	if href := opts.snapshotHref(lnk.URL); len(href) > 0 {
+		item = htmlListArgs(item, htmlRawText(` <a class="snapshot" href="`+html.EscapeString(href)+`">`+
+			html.EscapeString(opts.phrases().saved)+"</a>"))
+	}
+
+	if opts.qr && !isBookmarklet(lnk.URL) {
+		item = htmlListArgs(item, htmlRawText(qrSVG(lnk.URL)))
+	}
+
+	return htmlTag("li", item)
 }
 
-func folderLinks(folder *Folder) fhtml {
+func folderLinks(folder *Folder, opts *options) fhtml {
 	if len(folder.Links) == 0 {
 		return htmlNil
 	}
@@ -467,13 +1564,66 @@ func folderLinks(folder *Folder) fhtml {
 	fns := make([]fhtml, len(folder.Links))
 
 	for i, lnk := range folder.Links {
-		fns[i] = htmlTag("dt", htmlLink(lnk.URL, lnk.Name))
+		fns[i] = linkItem(lnk, opts)
+	}
+
+	return htmlTag("ul", htmlList(fns))
+}
+
+// folders as nested sections, with heading level matching the folder depth
+func folderList(folders []*Folder, level int, opts *options) fhtml {
+	if len(folders) == 0 {
+		return htmlNil
+	}
+
+	// HTML has only six heading levels
+	if level > 6 {
+		level = 6
+	}
+
+	fns := make([]fhtml, len(folders))
+
+	for i, folder := range folders {
+		fns[i] = htmlListArgs(
+			htmlRawText(`<section aria-labelledby="`+html.EscapeString(opts.anchors[folder])+`">`),
+			folderName(folder, level, opts),
+			folderLinks(folder, opts),
+			omittedSummary(folder, opts),
+			folderList(folder.Folders, level+1, opts),
+			htmlRawText("</section>"),
+		)
+	}
+
+	return htmlList(fns)
+}
+
+func omittedSummary(folder *Folder, opts *options) fhtml {
+	if opts.depthSummary && folder.OmittedFolders > 0 {
+		s := fmt.Sprintf(opts.phrases().omitted, folder.OmittedLinks, folder.OmittedFolders)
+
+		return htmlTag("p", htmlTag("small", htmlText(s)))
 	}
 
-	return htmlTag("dl", htmlList(fns))
+	return htmlNil
 }
 
-func folderList(folders []*Folder) fhtml {
+// table of contents with links to the folder anchors
+func tableOfContents(folders []*Folder, opts *options) fhtml {
+	if !opts.toc {
+		return htmlNil
+	}
+
+	contents := opts.phrases().contents
+
+	return htmlListArgs(
+		htmlRawText(`<nav id="contents" aria-label="`+html.EscapeString(contents)+`">`),
+		htmlTag("h2", htmlText(contents)),
+		tocList(folders, opts),
+		htmlRawText("</nav>"),
+	)
+}
+
+func tocList(folders []*Folder, opts *options) fhtml {
 	if len(folders) == 0 {
 		return htmlNil
 	}
@@ -481,26 +1631,76 @@ func folderList(folders []*Folder) fhtml {
 	fns := make([]fhtml, len(folders))
 
 	for i, folder := range folders {
+		_, nl := folder.count()
+
 		fns[i] = htmlTag("li", htmlListArgs(
-			folderName(folder),
-			folderLinks(folder),
-			folderList(folder.Folders),
+			htmlLink("#"+opts.anchors[folder], folder.Name),
+			htmlRawText(" <small>("+strconv.Itoa(nl)+")</small>"),
+			tocList(folder.Folders, opts),
 		))
 	}
 
 	return htmlTag("ul", htmlList(fns))
 }
 
-const htmlHeader = `<!DOCTYPE HTML><html>
+// unique anchor ids for all folders, made of the folder paths
+func folderAnchors(folders []*Folder) map[*Folder]string {
+	anchors := make(map[*Folder]string)
+	used := make(map[string]bool)
+
+	var walk func([]*Folder, string)
+
+	walk = func(folders []*Folder, prefix string) {
+		for _, f := range folders {
+			base := prefix + anchorName(f.Name)
+			id := base
+
+			for i := 2; used[id]; i++ {
+				id = base + "-" + strconv.Itoa(i)
+			}
+
+			used[id], anchors[f] = true, id
+			walk(f.Folders, base+"/")
+		}
+	}
+
+	walk(folders, "")
+	return anchors
+}
+
+// lower case letters and digits of the name, separated by "-"
+func anchorName(name string) string {
+	s := strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+
+	if len(s) == 0 {
+		return "folder"
+	}
+
+	return s
+}
+
+const htmlHeader = `<!DOCTYPE HTML><html lang="%s">
 <head>
-<meta charset="utf-8"/><title>Bookmarks</title><style> ul { list-style-type: disc; } </style>
+<meta charset="utf-8"/><title>%s</title><style> ul { list-style-type: disc; } section section { margin-left: 1.5em; } </style>
 </head>
 `
 
-func foldersToHTML(folders []*Folder, dest StringWriter) error {
+func foldersToHTML(folders []*Folder, opts *options, dest StringWriter) error {
+	opts.anchors = folderAnchors(folders)
+
 	f := htmlListArgs(
-		htmlRawText(htmlHeader),
-		htmlTag("body", folderList(folders)),
+		htmlRawText(fmt.Sprintf(htmlHeader, opts.lang, html.EscapeString(opts.phrases().title))),
+		htmlTag("body", htmlListArgs(
+			htmlTag("header", htmlTag("h1", htmlText(opts.phrases().title))),
+			tableOfContents(folders, opts),
+			htmlTag("main", htmlListArgs(
+				folderList(folders, 2, opts),
+				azIndex(folders, opts),
+				domainCloud(folders, opts),
+			)),
+		)),
 		htmlRawText("</html>\n"),
 	)
 
@@ -510,9 +1710,79 @@ func foldersToHTML(folders []*Folder, dest StringWriter) error {
 // helpers
 func die(err error) {
 	os.Stderr.WriteString("ERROR: " + err.Error() + "\n")
+
+	if e, ok := err.(*exitError); ok {
+		os.Exit(e.code)
+	}
+
 	os.Exit(1)
 }
 
+// error with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// user data directory, $XDG_DATA_HOME or $HOME/.local/share, or empty string if $HOME is not set
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); len(dir) > 0 {
+		return dir
+	}
+
+	if home := os.Getenv("HOME"); len(home) > 0 {
+		return filepath.Join(home, ".local", "share")
+	}
+
+	return ""
+}
+
+// user cache directory, $XDG_CACHE_HOME or $HOME/.cache, or empty string if $HOME is not set
+func cacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); len(dir) > 0 {
+		return dir
+	}
+
+	if home := os.Getenv("HOME"); len(home) > 0 {
+		return filepath.Join(home, ".cache")
+	}
+
+	return ""
+}
+
+// logging to STDERR
+type logLevel int
+
+const (
+	levelQuiet logLevel = iota
+	levelNormal
+	levelVerbose
+)
+
+var verbosity = levelNormal
+
+func logWarn(format string, args ...interface{}) {
+	logMessage(levelNormal, "WARNING: ", format, args)
+}
+
+func logNotice(format string, args ...interface{}) {
+	logMessage(levelNormal, "", format, args)
+}
+
+func logInfo(format string, args ...interface{}) {
+	logMessage(levelVerbose, "", format, args)
+}
+
+func logMessage(level logLevel, prefix, format string, args []interface{}) {
+	if verbosity >= level {
+		os.Stderr.WriteString(prefix + fmt.Sprintf(format, args...) + "\n")
+	}
+}
+
 // debug printout
 func printFolder(folder *Folder, level int) {
 	fmt.Printf("%s(%d) Folder[%q]: %q\n",
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputListStdinOnce(t *testing.T) {
+	var list inputList
+
+	if err := list.Set("-"); err != nil {
+		t.Fatal(err)
+	}
+
+	if list[0].label != "STDIN" {
+		t.Fatalf("Unexpected label %q", list[0].label)
+	}
+
+	if err := list.Set("other=-"); err == nil {
+		t.Fatal("Second STDIN input is accepted")
+	}
+}
+
+func TestInputListLabels(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a=b", "Bookmarks")
+
+	var list inputList
+
+	for _, s := range []string{name, "Work=" + name, "a=b/Bookmarks"} {
+		if err := list.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exp := inputList{{name, name}, {"Work", name}, {"a", "b/Bookmarks"}}
+
+	if !reflect.DeepEqual(list, exp) {
+		t.Fatalf("Unexpected inputs: %q", list)
+	}
+}
+
+func TestLoadTreeErrorHasName(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+
+	if err := os.WriteFile(name, []byte(`{"roots": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadTree(name, readOptions{})
+
+	if err == nil || !strings.HasPrefix(err.Error(), name+": ") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestFindFolder(t *testing.T) {
+	news := &Folder{Node: Node{Name: "News"}}
+	root := &Folder{Folders: []*Folder{{Node: Node{Name: "Bookmarks bar"}, Folders: []*Folder{news}}}}
+
+	if f, err := findFolder([]*Folder{root}, "/Bookmarks bar/News"); err != nil || f != news {
+		t.Fatalf("Unexpected result: %v, %v", f, err)
+	}
+
+	if _, err := findFolder([]*Folder{root}, "Bookmarks bar/Sport"); err == nil {
+		t.Fatal("Missing error")
+	}
+}
+
+func TestHTMLHref(t *testing.T) {
+	cases := map[string]string{
+		"javascript:a();// comment\n\tb(\"x\")": "javascript:a();// comment%0A%09b(&#34;x&#34;)",
+		"https://example.com/?a=1&b=2":          "https://example.com/?a=1&amp;b=2",
+	}
+
+	for src, exp := range cases {
+		if res := htmlHref(src); res != exp {
+			t.Errorf("%q: got %q instead of %q", src, res, exp)
+		}
+	}
+}
+
+func TestFolderAnchors(t *testing.T) {
+	news := &Folder{Node: Node{Name: "News"}}
+	news2 := &Folder{Node: Node{Name: "news!"}}
+	bar := &Folder{Node: Node{Name: "Bookmarks bar"}, Folders: []*Folder{news, news2}}
+	other := &Folder{Node: Node{Name: "***"}, Links: []*Link{{URL: "https://example.com/"}}}
+
+	anchors := folderAnchors([]*Folder{bar, other})
+
+	exp := map[*Folder]string{
+		bar:   "bookmarks-bar",
+		news:  "bookmarks-bar/news",
+		news2: "bookmarks-bar/news-2",
+		other: "folder",
+	}
+
+	for f, id := range exp {
+		if anchors[f] != id {
+			t.Errorf("%q: got %q instead of %q", f.Name, anchors[f], id)
+		}
+	}
+
+	opts := &options{toc: true, anchors: anchors}
+
+	var buff bytes.Buffer
+
+	if err := tableOfContents([]*Folder{other}, opts)(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buff.String(); s != `<nav id="contents" aria-label="Contents"><h2>Contents</h2><ul><li><a href="#folder">***</a> <small>(1)</small></li></ul></nav>` {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	name, out := filepath.Join(dir, "Bookmarks"), filepath.Join(dir, "out.csv")
+
+	if err := os.WriteFile(name, []byte(testBookmarks("A")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := convertCmd([]string{"-q", "--from", "firefox", "--to", "csv", "-i", name}); err == nil {
+		t.Fatal("Unknown input format accepted")
+	}
+
+	if err := convertCmd([]string{"-q", "--from", "opera", "--to", "csv", "-i", name, "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(out); err != nil || !strings.Contains(string(data), "Bar,A,https://example.com/") {
+		t.Fatalf("Unexpected output: %q, %v", data, err)
+	}
+}
+
+func TestFormatRegistry(t *testing.T) {
+	if names := formatNames(); names != "alfred, csv, dot, epub, gallery, html, json, jsonl, markdown, mm, netscape, template, text" {
+		t.Fatalf("Unexpected formats: %s", names)
+	}
+}
+
+func TestHTMLLanguage(t *testing.T) {
+	folder := &Folder{
+		Node:  Node{Name: "Ordner", Modified: time.Date(2020, 3, 1, 12, 0, 0, 0, time.Local)},
+		Links: []*Link{{Node: Node{Name: "A"}, URL: "https://a/"}},
+	}
+
+	opts := newOptions()
+	opts.lang, opts.showDates, opts.toc = "de", true, true
+
+	var buff bytes.Buffer
+
+	if err := foldersToHTML([]*Folder{folder}, opts, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{`<html lang="de">`, "<title>Lesezeichen</title>", "<h2>Inhalt</h2>", "<small>geändert 01.03.2020</small>"} {
+		if !strings.Contains(buff.String(), s) {
+			t.Errorf("%q not found in output:\n%s", s, buff.String())
+		}
+	}
+}
+
+func TestHTMLSections(t *testing.T) {
+	sub := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{{Node: Node{Name: "A"}, URL: "https://a/"}}}
+	top := &Folder{Node: Node{Name: "Top"}, Folders: []*Folder{sub}}
+
+	var buff bytes.Buffer
+
+	if err := foldersToHTML([]*Folder{top}, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `<main><section aria-labelledby="top"><h2 id="top">Top</h2>` +
+		`<section aria-labelledby="top/sub"><h3 id="top/sub">Sub</h3><ul><li><a href="https://a/">A</a></li></ul></section>` +
+		`</section></main>`
+
+	if !strings.Contains(buff.String(), exp) {
+		t.Fatalf("Unexpected output:\n%s", buff.String())
+	}
+}
+
+func TestAZIndex(t *testing.T) {
+	link := func(name string) *Link {
+		return &Link{Node: Node{Name: name}, URL: "https://" + name + "/"}
+	}
+
+	top := &Folder{Links: []*Link{link("beta"), link("42")}, Folders: []*Folder{{Links: []*Link{link("Alpha"), link("ärger")}}}}
+	opts := &options{azIndex: true}
+
+	var buff bytes.Buffer
+
+	if err := azIndex([]*Folder{top}, opts)(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<p><a href="#az-0">#</a> <a href="#az-A">A</a> <a href="#az-B">B</a> <a href="#az-Ä">Ä</a> </p>`,
+		`<h3 id="az-A">A</h3><ul><li><a href="https://Alpha/">Alpha</a></li></ul>`,
+	} {
+		if !strings.Contains(buff.String(), s) {
+			t.Errorf("%q not found in output:\n%s", s, buff.String())
+		}
+	}
+}
+
+func TestDomainCloud(t *testing.T) {
+	link := func(url string) *Link {
+		return &Link{Node: Node{Name: "x"}, URL: url}
+	}
+
+	top := &Folder{Links: []*Link{link("https://www.a.com/1"), link("https://a.com/2"), link("https://b.org/"), link("javascript:x")}}
+
+	var buff bytes.Buffer
+
+	if err := domainCloud([]*Folder{top}, &options{domains: true})(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`<a href="#domain-a.com" style="font-size: 250%" title="2">a.com</a> <a href="#domain-b.org" style="font-size: 100%" title="1">b.org</a>`,
+		`<h3 id="domain-a.com">a.com</h3><ul><li><a href="https://www.a.com/1">x</a></li><li><a href="https://a.com/2">x</a></li></ul>`,
+	} {
+		if !strings.Contains(buff.String(), s) {
+			t.Errorf("%q not found in output:\n%s", s, buff.String())
+		}
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "search.json")
+	top := &Folder{
+		Node:    Node{Name: "Top"},
+		Folders: []*Folder{{Node: Node{Name: "Sub"}, Links: []*Link{{Node: Node{Name: "A"}, URL: "https://a/"}}}},
+	}
+
+	if err := writeSearchIndex(name, []*Folder{top}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `[
+  {
+    "id": "0",
+    "title": "A",
+    "url": "https://a/",
+    "folder": "Top/Sub"
+  }
+]`
+
+	if string(data) != exp {
+		t.Fatalf("Unexpected index: %s", data)
+	}
+}
+
+func TestOutputReplace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.html")
+
+	write := func(s string, mode outputMode, fail bool) error {
+		return withOutput(name, mode)(func(dest io.Writer) error {
+			if _, err := io.WriteString(dest, s); err != nil || !fail {
+				return err
+			}
+
+			return errors.New("failed")
+		})
+	}
+
+	if err := write("v1", outputMode{}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing file is kept on failure
+	if err := write("v2", outputMode{backup: true}, true); err == nil {
+		t.Fatal("Missing error")
+	}
+
+	if err := write("v3", outputMode{backup: true}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, exp := range map[string]string{name: "v3", name + ".bak": "v1"} {
+		if data, err := ioutil.ReadFile(file); err != nil || string(data) != exp {
+			t.Errorf("%s: unexpected content %q, %v", file, data, err)
+		}
+	}
+
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 2 {
+		t.Errorf("Unexpected files: %v, %v", files, err)
+	}
+}
+
+func TestOutputOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	out := filepath.Join(dir, "out.csv")
+
+	if err := ioutil.WriteFile(name, []byte(testBookmarks("v1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "-f", "csv", "-i", name, "-o", out}
+
+	if err := exportCmd(args); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exportCmd(args); err == nil {
+		t.Fatal("Existing file replaced without --force")
+	}
+
+	if err := exportCmd(append(args, "--force")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exportCmd(append(args, "--output-append")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(out)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "folder,") || lines[1] != lines[2] {
+		t.Errorf("Unexpected output:\n%s", data)
+	}
+
+	if err = exportCmd(append(args, "-f", "html", "--output-append")); err == nil {
+		t.Error("HTML output appended")
+	}
+
+	// search index
+	args = append(args, "--output-append", "--search-index", filepath.Join(dir, "search.json"))
+
+	if err = exportCmd(args); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = exportCmd(args); err == nil {
+		t.Error("Existing search index replaced without --force")
+	}
+}
+
+func TestStdoutName(t *testing.T) {
+	for _, name := range []string{"-", "STDOUT"} {
+		opts := newOptions()
+		fs := newFlagSet("export", "")
+
+		opts.outputFlags(fs)
+
+		if err := opts.parse(fs, []string{"-o", name}); err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.outputName != stdout {
+			t.Errorf("%s: unexpected output name %q", name, opts.outputName)
+		}
+	}
+}
+
+func TestRootNamesFlag(t *testing.T) {
+	opts := newOptions()
+	fs := newFlagSet("export", "")
+
+	opts.outputFlags(fs)
+
+	if err := opts.parse(fs, []string{"--root-names", "bookmark_bar=Toolbar,trash=Deleted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := stringMap{"bookmark_bar": "Toolbar", "trash": "Deleted"}
+
+	if !reflect.DeepEqual(opts.rootNames, exp) {
+		t.Fatalf("Unexpected root names: %v", opts.rootNames)
+	}
+}
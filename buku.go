@@ -0,0 +1,175 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	registerPushTarget("buku", "Write bookmarks into a buku database, with folder names as tags", pushBuku)
+}
+
+// "push buku" command
+func pushBuku(args []string) error {
+	fs := newFlagSet("push buku", "")
+	opts := newPushOptions(fs)
+
+	var dbName string
+
+	fs.StringVar(&dbName, "db", defaultBukuDB(), "buku database file (default $XDG_DATA_HOME/buku/bookmarks.db)")
+
+	var replace bool
+
+	fs.BoolVar(&replace, "replace", false, "Update bookmarks already existing in the database")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(dbName) == 0 {
+		return errors.New("Database location is unknown, please specify --db")
+	}
+
+	links, paths, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dbName), 0755); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", dbName)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	added, err := bukuInsert(db, links, paths, replace)
+
+	if err != nil {
+		return err
+	}
+
+	logInfo("%s: %d links added, %d skipped", dbName, added, len(links)-added)
+	return nil
+}
+
+// $XDG_DATA_HOME/buku/bookmarks.db, or empty string if the data directory is unknown
+func defaultBukuDB() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, "buku", "bookmarks.db")
+	}
+
+	return ""
+}
+
+// the same schema as created by buku itself
+const bukuSchema = `CREATE TABLE IF NOT EXISTS bookmarks (
+	id integer PRIMARY KEY,
+	URL text NOT NULL UNIQUE,
+	metadata text default '',
+	tags text default ',',
+	desc text default '',
+	flags integer default 0)`
+
+// inserts links in one transaction, returns the number of links added or updated
+func bukuInsert(db *sql.DB, links []*Link, paths linkPaths, replace bool) (n int, err error) {
+	if _, err = db.Exec(bukuSchema); err != nil {
+		return
+	}
+
+	var tx *sql.Tx
+
+	if tx, err = db.Begin(); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := "INSERT OR IGNORE INTO bookmarks(URL, metadata, tags) VALUES (?, ?, ?)"
+
+	if replace {
+		query = "INSERT INTO bookmarks(URL, metadata, tags) VALUES (?, ?, ?) " +
+			"ON CONFLICT(URL) DO UPDATE SET metadata = excluded.metadata, tags = excluded.tags"
+	}
+
+	var stmt *sql.Stmt
+
+	if stmt, err = tx.Prepare(query); err != nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	for _, link := range links {
+		var res sql.Result
+
+		if res, err = stmt.Exec(link.URL, link.Name, bukuTags(paths[link])); err != nil {
+			return
+		}
+
+		if c, _ := res.RowsAffected(); c > 0 {
+			n++
+		}
+	}
+
+	return
+}
+
+// buku stores lower case tags as ",tag1,tag2,"
+func bukuTags(path []string) string {
+	tags := pathTags(path)
+
+	for i, tag := range tags {
+		tags[i] = strings.ToLower(tag)
+	}
+
+	return "," + strings.Join(tags, ",") + ","
+}
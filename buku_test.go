@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestBukuInsert(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "bookmarks.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	links := []*Link{
+		{Node: Node{Name: "Go"}, URL: "https://go.dev/"},
+		{Node: Node{Name: "GitHub"}, URL: "https://github.com/"},
+	}
+
+	paths := linkPaths{links[0]: {"Bookmarks bar", "Dev"}}
+
+	if n, err := bukuInsert(db, links, paths, false); err != nil || n != 2 {
+		t.Fatalf("Unexpected result: %d, %v", n, err)
+	}
+
+	// existing links are skipped, unless replaced
+	links[0].Name = "Golang"
+
+	if n, err := bukuInsert(db, links[:1], paths, false); err != nil || n != 0 {
+		t.Fatalf("Unexpected result: %d, %v", n, err)
+	}
+
+	if n, err := bukuInsert(db, links[:1], paths, true); err != nil || n != 1 {
+		t.Fatalf("Unexpected result: %d, %v", n, err)
+	}
+
+	var title, tags string
+
+	if err = db.QueryRow("SELECT metadata, tags FROM bookmarks WHERE URL = ?", "https://go.dev/").Scan(&title, &tags); err != nil {
+		t.Fatal(err)
+	}
+
+	if title != "Golang" || tags != ",bookmarks bar,dev," {
+		t.Fatalf("Unexpected row: %q, %q", title, tags)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/juju/gnuflag"
+)
+
+// options for commands writing back to the Opera profile
+func (opts *options) writeBackFlags(fs *gnuflag.FlagSet) {
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only print the changes that would be made")
+	fs.BoolVar(&opts.yes, "yes", false, "Apply the changes (required for modifying the Bookmarks file)")
+}
+
+// prints the changes if required, and decides whether they are to be applied
+func (opts *options) confirm(changes []string) (bool, error) {
+	if opts.dryRun {
+		for _, s := range changes {
+			fmt.Println(s)
+		}
+
+		return false, nil
+	}
+
+	if !opts.yes {
+		return false, errors.New("The Bookmarks file is about to be modified: please confirm with --yes, " +
+			"or use --dry-run to see the changes")
+	}
+
+	for _, s := range changes {
+		logInfo("%s", s)
+	}
+
+	return true, nil
+}
+
+// describes the changes from one bookmarks tree to another, one line per removed ("-")
+// or added ("+") link
+func treeChanges(from, to *Folder) (changes []string) {
+	before := linkLines(from)
+	after := linkLines(to)
+	count := make(map[string]int, len(after))
+
+	for _, s := range after {
+		count[s]++
+	}
+
+	for _, s := range before {
+		if count[s] > 0 {
+			count[s]--
+		} else {
+			changes = append(changes, "- "+s)
+		}
+	}
+
+	for _, s := range after {
+		if count[s] > 0 {
+			count[s]--
+			changes = append(changes, "+ "+s)
+		}
+	}
+
+	return
+}
+
+// "folder/name <url>" for every link in the tree
+func linkLines(root *Folder) (lines []string) {
+	if root == nil {
+		return
+	}
+
+	root.walkLinks(nil, func(path []string, link *Link) error {
+		lines = append(lines, strings.Join(append(path[:len(path):len(path)], link.Name), "/")+" <"+link.URL+">")
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTreeChanges(t *testing.T) {
+	link := func(name, url string) *Link {
+		return &Link{Node: Node{Name: name}, URL: url}
+	}
+
+	from := &Folder{Folders: []*Folder{{
+		Node:  Node{Name: "Bar"},
+		Links: []*Link{link("A", "https://a/"), link("B", "https://b/"), link("A", "https://a/")},
+	}}}
+
+	to := &Folder{Folders: []*Folder{{
+		Node:  Node{Name: "Bar"},
+		Links: []*Link{link("A", "https://a/"), link("C", "https://c/")},
+	}}}
+
+	exp := []string{
+		"- Bar/B <https://b/>",
+		"- Bar/A <https://a/>",
+		"+ Bar/C <https://c/>",
+	}
+
+	if res := treeChanges(from, to); !reflect.DeepEqual(res, exp) {
+		t.Fatalf("Unexpected changes: %q", res)
+	}
+
+	if res := treeChanges(nil, to); len(res) != 2 {
+		t.Fatalf("Unexpected changes: %q", res)
+	}
+}
@@ -0,0 +1,499 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerCommand("check", "Check bookmarked web pages for dead links, optionally replacing them with Wayback Machine snapshots", checkCmd)
+}
+
+// "check" command, exits with code 1 if broken links are found, or 2 on any other error
+func checkCmd(args []string) error {
+	broken, threshold, err := runCheck(args)
+
+	switch {
+	case err != nil:
+		return &exitError{2, err}
+	case threshold > 0 && broken >= threshold:
+		return &exitError{1, fmt.Errorf("%d broken links found", broken)}
+	default:
+		return nil
+	}
+}
+
+// returns the number of broken links, and the threshold for the exit code
+func runCheck(args []string) (broken, threshold int, err error) {
+	opts := newOptions()
+	fs := newFlagSet("check", "")
+
+	opts.inputFlags(fs)
+	opts.networkFlags(fs)
+	opts.warcFlag(fs)
+	opts.writeBackFlags(fs)
+	opts.logFlags(fs)
+
+	var wayback, fix bool
+	var cacheName string
+	var maxAge time.Duration
+
+	fs.StringVar(&cacheName, "cache", defaultCheckCache(), "File caching link check results")
+	fs.DurationVar(&maxAge, "cache-max-age", 24*time.Hour, "Maximum age of cached link check results")
+
+	fs.BoolVar(&wayback, "wayback", false, "Find the closest Wayback Machine snapshot for every dead link")
+	fs.IntVar(&threshold, "fail-threshold", 1, "Exit with code 1 if at least this many links are broken (0 is never)")
+	fs.BoolVar(&fix, "fix", false,
+		"Replace dead links in the Bookmarks file with their Wayback Machine snapshots (implies --wayback)")
+
+	if err = opts.parse(fs, args); err != nil {
+		return
+	}
+
+	if err = noArgs(fs); err != nil {
+		return
+	}
+
+	if threshold < 0 {
+		err = errors.New("Invalid threshold: " + strconv.Itoa(threshold))
+		return
+	}
+
+	// the Bookmarks file to fix
+	var data *rawData
+
+	if fix {
+		if len(opts.inputs) > 1 || opts.inputs[0].name == stdin {
+			err = errors.New("With --fix only one input file is allowed, and it cannot be STDIN")
+			return
+		}
+
+		if data, err = loadRawData(opts.inputs[0].name); err != nil {
+			return
+		}
+
+		if !data.sumValid {
+			err = errors.New(opts.inputs[0].name + ": Checksum mismatch, the file may be corrupted or edited by hand")
+			return
+		}
+
+		wayback = true
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return
+	}
+
+	// check
+	links := webLinks(roots)
+
+	logInfo("checking %d links", len(links))
+
+	cache, err := loadCheckCache(cacheName, maxAge)
+
+	if err != nil {
+		return
+	}
+
+	closeWARC, err := opts.openWARC()
+
+	if err != nil {
+		return
+	}
+
+	results, err := checkLinks(opts.newWebClient(), links, opts.concurrency, wayback, cache)
+
+	if e := closeWARC(); e != nil && err == nil {
+		err = e
+	}
+
+	if e := cache.save(); e != nil && err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return
+	}
+
+	broken = len(results)
+
+	// report
+	fixes := make(map[string]string)
+	dead := 0
+
+	for _, r := range results {
+		line := r.problem() + "\t" + r.link.URL
+
+		if r.Dead {
+			dead++
+
+			if len(r.Snapshot) > 0 {
+				line += "\t" + r.Snapshot
+				fixes[r.link.URL] = r.Snapshot
+			} else if wayback {
+				line += "\t(no snapshot)"
+			}
+		}
+
+		fmt.Println(line)
+	}
+
+	logInfo("%d links checked: %d dead, %d failed to check", len(links), dead, len(results)-dead)
+
+	if !fix {
+		return
+	}
+
+	// fix
+	changes := fixRawLinks(data.Roots, fixes)
+
+	if len(changes) == 0 {
+		logNotice("nothing to fix")
+		return
+	}
+
+	var apply bool
+
+	if apply, err = opts.confirm(changes); !apply {
+		return
+	}
+
+	name := opts.inputs[0].name
+
+	if err = data.save(name); err != nil {
+		return
+	}
+
+	logNotice("replaced %d dead links in %s; Opera must not be running while the file is replaced", len(changes), name)
+
+	// fixed links are not broken any more
+	broken -= len(fixes)
+	return
+}
+
+// result of checking a link, as cached
+type checkRecord struct {
+	Status   int       `json:"status"`              // HTTP status, 0 if the host does not exist
+	Dead     bool      `json:"dead,omitempty"`      // HTTP status 404 or 410, or the host does not exist
+	FinalURL string    `json:"final_url,omitempty"` // after redirects, if different
+	ETag     string    `json:"etag,omitempty"`
+	Snapshot string    `json:"snapshot,omitempty"` // the closest Wayback Machine snapshot of a dead link
+	Checked  time.Time `json:"checked"`
+}
+
+type linkCheck struct {
+	link *Link
+	checkRecord
+	err error // any failure other than a dead link; such results are not cached
+}
+
+func (r *linkCheck) problem() string {
+	switch {
+	case r.Status > 0:
+		return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+	case r.err != nil:
+		return "error: " + r.err.Error()
+	default:
+		return "no such host"
+	}
+}
+
+// checks the links, returning the dead and failed ones in the order of the links;
+// links checked recently enough are taken from the cache
+func checkLinks(client *webClient, links []*Link, concurrency int, wayback bool, cache *checkCache) ([]*linkCheck, error) {
+	found := make(map[*Link]*linkCheck)
+
+	var mu sync.Mutex
+
+	err := forEachLink(links, concurrency, func(link *Link) error {
+		r := &linkCheck{link: link}
+		old, fresh := cache.get(link.URL)
+
+		switch {
+		case fresh:
+			r.checkRecord = *old
+		case old != nil && !old.Dead:
+			// unchanged since the last check
+			if r.checkRecord, r.err = checkLink(client, link.URL, old.ETag); r.err == nil && r.Status == http.StatusNotModified {
+				old.Checked = r.Checked
+				r.checkRecord = *old
+			}
+		default:
+			r.checkRecord, r.err = checkLink(client, link.URL, "")
+		}
+
+		if r.Dead && wayback && len(r.Snapshot) == 0 {
+			var err error
+
+			if r.Snapshot, err = waybackClosest(client, link.URL); err != nil {
+				logWarn("%s: Wayback Machine: %s", link.URL, err)
+			}
+		}
+
+		if r.err == nil {
+			cache.put(link.URL, r.checkRecord)
+		}
+
+		if r.Dead || r.err != nil {
+			mu.Lock()
+			found[link] = r
+			mu.Unlock()
+		}
+
+		return nil
+	})
+
+	var results []*linkCheck
+
+	for _, link := range links {
+		if r, ok := found[link]; ok {
+			results = append(results, r)
+		}
+	}
+
+	return results, err
+}
+
+// requests the page, conditionally if the ETag is not empty; the returned error describes any
+// problem other than the page being gone
+func checkLink(client *webClient, link, etag string) (rec checkRecord, err error) {
+	req, err := http.NewRequest("GET", link, nil)
+
+	if err != nil {
+		return
+	}
+
+	if len(etag) > 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.do(req)
+	rec.Checked = time.Now().UTC()
+
+	if err == nil {
+		resp.Body.Close()
+
+		rec.Status, rec.ETag = resp.StatusCode, resp.Header.Get("ETag")
+
+		if final := urlString(resp.Request.URL); final != link {
+			rec.FinalURL = final
+		}
+
+		return
+	}
+
+	switch e := err.(type) {
+	case *HTTPError:
+		rec.Status = e.Status
+
+		switch e.Status {
+		case http.StatusNotModified:
+			err = nil
+		case http.StatusNotFound, http.StatusGone:
+			rec.Dead, err = true, nil
+		}
+	case *url.Error:
+		if op, ok := e.Err.(*net.OpError); ok {
+			err = op.Err
+		} else {
+			err = e.Err
+		}
+
+		if dns, ok := err.(*net.DNSError); ok && dns.IsNotFound {
+			rec.Dead, err = true, nil
+		}
+	}
+
+	return
+}
+
+// cache of link check results
+type checkCache struct {
+	name    string
+	maxAge  time.Duration
+	lock    sync.Mutex
+	records map[string]checkRecord
+	dirty   bool
+}
+
+// $XDG_CACHE_HOME/opera-bookmarks/links.json, or empty string if the cache directory is unknown
+func defaultCheckCache() string {
+	if dir := cacheDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "links.json")
+	}
+
+	return ""
+}
+
+func loadCheckCache(name string, maxAge time.Duration) (*checkCache, error) {
+	cache := &checkCache{
+		name:    name,
+		maxAge:  maxAge,
+		records: make(map[string]checkRecord),
+	}
+
+	if len(name) == 0 {
+		return cache, nil
+	}
+
+	file, err := os.Open(name)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&cache.records); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return cache, nil
+}
+
+// returns the cached record, if any, and whether it is recent enough
+func (cache *checkCache) get(link string) (*checkRecord, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	rec, ok := cache.records[link]
+
+	if !ok {
+		return nil, false
+	}
+
+	return &rec, time.Since(rec.Checked) < cache.maxAge
+}
+
+func (cache *checkCache) put(link string, rec checkRecord) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.records[link] = rec
+	cache.dirty = true
+}
+
+// saves the cache, dropping links not checked for a long time
+func (cache *checkCache) save() error {
+	if !cache.dirty || len(cache.name) == 0 {
+		return nil
+	}
+
+	for link, rec := range cache.records {
+		if time.Since(rec.Checked) >= 10*cache.maxAge {
+			delete(cache.records, link)
+		}
+	}
+
+	return writeJSONFile(cache.name, cache.records)
+}
+
+// Wayback Machine availability API location, variable for testing
+var waybackAPI = "https://archive.org"
+
+// finds the closest snapshot of the URL, returns empty string if there is none
+func waybackClosest(client *webClient, link string) (string, error) {
+	req, err := http.NewRequest("GET", waybackAPI+"/wayback/available?url="+url.QueryEscape(link), nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	var result struct {
+		Snapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("Invalid response: %s", err)
+	}
+
+	if s := result.Snapshots.Closest; s.Available && strings.HasPrefix(s.Status, "2") {
+		if strings.HasPrefix(s.URL, "http://") {
+			return "https://" + s.URL[7:], nil
+		}
+
+		return s.URL, nil
+	}
+
+	return "", nil
+}
+
+// replaces link URLs in the raw tree, returning the list of changes
+func fixRawLinks(roots interface{}, fixes map[string]string) (changes []string) {
+	walkRawFolders(roots, func(path []string, node map[string]interface{}) {
+		children, _ := node["children"].([]interface{})
+
+		for _, child := range children {
+			c, ok := child.(map[string]interface{})
+
+			if !ok || rawString(c, "type") != "url" {
+				continue
+			}
+
+			if s, ok := fixes[rawString(c, "url")]; ok {
+				changes = append(changes, strings.Join(append(path, rawString(c, "name")), "/")+": "+rawString(c, "url")+" -> "+s)
+				c["url"] = s
+			}
+		}
+	})
+
+	return
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/wayback/available":
+			if u := r.URL.Query().Get("url"); strings.HasSuffix(u, "/gone") {
+				w.Write([]byte(`{"archived_snapshots": {"closest": {"available": true, "status": "200",
+					"url": "http://web.archive.org/web/20200101000000/http://example.com/gone"}}}`))
+			} else {
+				w.Write([]byte(`{"archived_snapshots": {}}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+	defer func(api string) { waybackAPI = api }(waybackAPI)
+
+	waybackAPI = srv.URL
+
+	var links []*Link
+
+	for _, p := range []string{"/ok", "/gone", "/missing", "/error"} {
+		links = append(links, &Link{URL: srv.URL + p})
+	}
+
+	results, err := checkLinks(newWebClient(), links, 2, true, &checkCache{records: make(map[string]checkRecord)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res []string
+
+	for _, r := range results {
+		res = append(res, strings.TrimPrefix(r.link.URL, srv.URL)+" "+r.problem()+" "+r.Snapshot)
+	}
+
+	exp := []string{
+		"/gone 410 Gone https://web.archive.org/web/20200101000000/http://example.com/gone",
+		"/missing 404 Not Found ",
+		"/error 500 Internal Server Error ",
+	}
+
+	if strings.Join(res, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("Unexpected results:\n%s", strings.Join(res, "\n"))
+	}
+
+	if !results[0].Dead || !results[1].Dead || results[2].Dead {
+		t.Error("Invalid dead link detection")
+	}
+}
+
+func TestFixRawLinks(t *testing.T) {
+	var roots interface{}
+
+	err := json.Unmarshal([]byte(`{"bookmark_bar": {"type": "folder", "name": "Bar", "children": [
+		{"type": "url", "name": "A", "url": "https://a/"},
+		{"type": "folder", "name": "Sub", "children": [{"type": "url", "name": "B", "url": "https://b/"}]}]}}`), &roots)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := fixRawLinks(roots, map[string]string{"https://b/": "https://web.archive.org/web/1/https://b/"})
+
+	if len(changes) != 1 || changes[0] != "Bar/Sub/B: https://b/ -> https://web.archive.org/web/1/https://b/" {
+		t.Errorf("Unexpected changes: %q", changes)
+	}
+
+	data, _ := json.Marshal(roots)
+
+	if !strings.Contains(string(data), `"url":"https://web.archive.org/web/1/https://b/"`) {
+		t.Errorf("Link not replaced: %s", data)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	var requests, conditional int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+	}))
+
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "links.json")
+	links := []*Link{{URL: srv.URL + "/a"}}
+
+	run := func(maxAge time.Duration) {
+		cache, err := loadCheckCache(name, maxAge)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = checkLinks(newWebClient(), links, 1, false, cache); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = cache.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(time.Hour)
+	run(time.Hour) // from the cache
+
+	if requests != 1 {
+		t.Fatalf("Unexpected number of requests: %d", requests)
+	}
+
+	// make the record stale
+	cache, err := loadCheckCache(name, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, _ := cache.get(links[0].URL)
+	rec.Checked = rec.Checked.Add(-2 * time.Hour)
+	cache.put(links[0].URL, *rec)
+
+	if err = cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	run(time.Hour) // stale, re-checked with the ETag
+
+	if requests != 2 || conditional != 1 {
+		t.Fatalf("Unexpected number of requests: %d (%d conditional)", requests, conditional)
+	}
+
+	if cache, err = loadCheckCache(name, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, fresh := cache.get(links[0].URL); !fresh || rec.Status != http.StatusOK || rec.ETag != `"v1"` {
+		t.Errorf("Unexpected cache record: %+v, %v", rec, fresh)
+	}
+}
+
+func TestCheckExitCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+
+	data := `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [{"type": "url", "name": "ok", "url": "` + srv.URL + `/ok", "id": "2", "date_added": "0"},
+		{"type": "url", "name": "a", "url": "` + srv.URL + `/a", "id": "3", "date_added": "0"},
+		{"type": "url", "name": "b", "url": "` + srv.URL + `/b", "id": "4", "date_added": "0"}]}}}`
+
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "-i", name, "--cache", filepath.Join(dir, "links.json")}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, 1},
+		{[]string{"--fail-threshold", "2"}, 1},
+		{[]string{"--fail-threshold", "3"}, 0},
+		{[]string{"--fail-threshold", "0"}, 0},
+		{[]string{"--fail-threshold", "-1"}, 2},
+		{[]string{"extra"}, 2},
+	}
+
+	for _, test := range tests {
+		code := 0
+
+		if err := checkCmd(append(args, test.args...)); err != nil {
+			code = -1
+
+			if e, ok := err.(*exitError); ok {
+				code = e.code
+			}
+		}
+
+		if code != test.code {
+			t.Errorf("%q: got exit code %d instead of %d", test.args, code, test.code)
+		}
+	}
+}
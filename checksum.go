@@ -0,0 +1,242 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Chromium computes the checksum over the standard roots only, while Opera may also include
+// its own roots (like "custom_root"); both variants are accepted
+var standardRoots = []string{"bookmark_bar", "other", "synced"}
+
+// checks the checksum of the Bookmarks file against its roots, remembering which way it is computed
+func (data *rawData) verify() {
+	if len(data.Checksum) == 0 {
+		data.sumValid = true
+		return
+	}
+
+	if data.Checksum == rootsChecksum(data.Roots, false) {
+		data.sumValid = true
+	} else if data.Checksum == rootsChecksum(data.Roots, true) {
+		data.sumValid, data.sumAll = true, true
+	}
+}
+
+// computes the checksum over the standard roots, and optionally over the other roots as well
+func rootsChecksum(roots interface{}, all bool) string {
+	node, _ := roots.(map[string]interface{})
+	h := md5.New()
+
+	for _, key := range standardRoots {
+		checksumNode(h, node[key])
+	}
+
+	if all {
+		// extra roots in sorted order
+		extra := make([]string, 0, len(node))
+
+		for key := range node {
+			if !isStandardRoot(key) {
+				extra = append(extra, key)
+			}
+		}
+
+		sort.Strings(extra)
+
+		for _, key := range extra {
+			checksumNode(h, node[key])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isStandardRoot(key string) bool {
+	for _, s := range standardRoots {
+		if s == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// adds the node and all its children to the checksum, the same way Chromium does it
+func checksumNode(h hash.Hash, item interface{}) {
+	node, ok := item.(map[string]interface{})
+
+	if !ok {
+		return
+	}
+
+	kind, ok := node["type"].(string)
+
+	// container of roots, like Opera "custom_root"
+	if !ok {
+		keys := make([]string, 0, len(node))
+
+		for key := range node {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			checksumNode(h, node[key])
+		}
+
+		return
+	}
+
+	id, _ := node["id"].(string)
+	name, _ := node["name"].(string)
+
+	h.Write([]byte(id))
+	h.Write(utf16Bytes(name))
+
+	if kind == "url" {
+		url, _ := node["url"].(string)
+
+		h.Write([]byte("url"))
+		h.Write([]byte(url))
+		return
+	}
+
+	h.Write([]byte("folder"))
+
+	children, _ := node["children"].([]interface{})
+
+	for _, child := range children {
+		checksumNode(h, child)
+	}
+}
+
+// UTF-16 in little endian byte order
+func utf16Bytes(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	res := make([]byte, 0, 2*len(codes))
+
+	for _, c := range codes {
+		res = append(res, byte(c), byte(c>>8))
+	}
+
+	return res
+}
+
+// computes the checksum from the tree, over the standard roots, and optionally over the other roots as well
+func treeChecksum(root *Folder, all bool) string {
+	h := md5.New()
+	nodes := childNodes(root)
+	byKey := make(map[string]interface{}, len(nodes))
+
+	for _, item := range nodes {
+		byKey[nodeOf(item).Key] = item
+	}
+
+	for _, key := range standardRoots {
+		checksumTreeNode(h, byKey[key])
+	}
+
+	if all {
+		for _, item := range nodes {
+			if !isStandardRoot(nodeOf(item).Key) {
+				checksumTreeNode(h, item)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func checksumTreeNode(h hash.Hash, item interface{}) {
+	switch node := item.(type) {
+	case *Link:
+		h.Write([]byte(node.ID))
+		h.Write(utf16Bytes(node.Name))
+		h.Write([]byte("url"))
+		h.Write([]byte(node.URL))
+	case *Folder:
+		if !node.container {
+			h.Write([]byte(node.ID))
+			h.Write(utf16Bytes(node.Name))
+			h.Write([]byte("folder"))
+		}
+
+		for _, child := range childNodes(node) {
+			checksumTreeNode(h, child)
+		}
+	}
+}
+
+// links and sub-folders in their original order: by position ("#N" key) in a folder,
+// or sorted by key in a container
+func childNodes(folder *Folder) []interface{} {
+	nodes := make([]interface{}, 0, len(folder.Links)+len(folder.Folders))
+
+	for _, link := range folder.Links {
+		nodes = append(nodes, link)
+	}
+
+	for _, f := range folder.Folders {
+		nodes = append(nodes, f)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodeOf(nodes[i]).Key, nodeOf(nodes[j]).Key
+
+		if folder.container {
+			return a < b
+		}
+
+		return nodeIndex(a) < nodeIndex(b)
+	})
+
+	return nodes
+}
+
+func nodeOf(item interface{}) *Node {
+	if link, ok := item.(*Link); ok {
+		return &link.Node
+	}
+
+	return &item.(*Folder).Node
+}
+
+func nodeIndex(key string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(key, "#"))
+	return i
+}
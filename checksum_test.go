@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	const roots = `{
+		"bookmark_bar": {"type": "folder", "id": "1", "name": "Bar", "children": [
+			{"type": "url", "id": "4", "name": "Ünï", "url": "https://example.com/"}
+		]},
+		"other": {"type": "folder", "id": "2", "name": "Other", "children": []},
+		"custom_root": {"speedDial": {"type": "folder", "id": "5", "name": "Speed Dial", "children": []}}
+	}`
+
+	var data interface{}
+
+	if err := json.Unmarshal([]byte(roots), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	// names as UTF-16LE
+	std := "1" + "B\x00a\x00r\x00" + "folder" +
+		"4" + "\xdc\x00n\x00\xef\x00" + "url" + "https://example.com/" +
+		"2" + "O\x00t\x00h\x00e\x00r\x00" + "folder"
+
+	verify := func(sum string) *rawData {
+		res := &rawData{Checksum: sum, Roots: data}
+
+		res.verify()
+		return res
+	}
+
+	sum := md5.Sum([]byte(std))
+
+	if res := verify(hex.EncodeToString(sum[:])); !res.sumValid || res.sumAll {
+		t.Error("Standard checksum is not accepted")
+	}
+
+	if verify(strings.Repeat("0", 32)).sumValid {
+		t.Error("Invalid checksum is accepted")
+	}
+
+	sum = md5.Sum([]byte(std + "5" + "S\x00p\x00e\x00e\x00d\x00 \x00D\x00i\x00a\x00l\x00" + "folder"))
+
+	if res := verify(hex.EncodeToString(sum[:])); !res.sumValid || !res.sumAll {
+		t.Error("Checksum with extra roots is not accepted")
+	}
+}
+
+func TestStrictChecksum(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+
+	if err := ioutil.WriteFile(name, []byte(`{"checksum": "00000000000000000000000000000000", "roots": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadTree(name, readOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadTree(name, readOptions{strict: true}); err == nil || !strings.HasPrefix(err.Error(), name+": Checksum mismatch") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// checksums from the raw data and from the tree are the same
+func TestTreeChecksum(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "id": "1", "name": "Bar", "date_added": "0", "children": [
+			{"type": "url", "id": "4", "name": "b", "url": "https://b/", "date_added": "0"},
+			{"type": "folder", "id": "6", "name": "F", "date_added": "0", "children": []},
+			{"type": "url", "id": "3", "name": "a", "url": "https://a/", "date_added": "0"}
+		]},
+		"other": {"type": "folder", "id": "2", "name": "Other", "date_added": "0", "children": []},
+		"custom_root": {"z": {"type": "folder", "id": "7", "name": "Z", "date_added": "0", "children": []},
+			"a": {"type": "folder", "id": "5", "name": "A", "date_added": "0", "children": []}}
+	}}`
+
+	data, err := decodeRawData("test", strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, _, err := newTreeDecoder(strings.NewReader(src), readOptions{}).decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, all := range []bool{false, true} {
+		if a, b := rootsChecksum(data.Roots, all), treeChecksum(root, all); a != b {
+			t.Errorf("all=%v: %s != %s", all, a, b)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+func init() {
+	registerCommand("decrypt", "Decrypt a file written with --encrypt option", decryptCmd)
+}
+
+// "decrypt" command
+func decryptCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("decrypt", "[FILE]")
+
+	fs.StringVar(&opts.outputName, "output", stdout, outputHelp)
+	fs.StringVar(&opts.outputName, "o", stdout, outputHelp)
+	opts.forceFlag(fs)
+	fs.StringVar(&opts.passFile, "passphrase-file", "", passphraseHelp)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	name := stdin
+
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		name = fs.Arg(0)
+	default:
+		return errors.New("Too many arguments")
+	}
+
+	pass, err := readPassphrase(opts.passFile)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := readInput(name)
+
+	if err != nil {
+		return err
+	}
+
+	if data, err = decryptData(data, pass); err != nil {
+		return err
+	}
+
+	return withOutput(opts.outputName, opts.output)(func(dest io.Writer) error {
+		_, err := dest.Write(data)
+		return err
+	})
+}
+
+const passphraseHelp = "File containing the encryption passphrase (default is $" + passphraseEnv + " environment variable)"
+
+const passphraseEnv = "OPERA_BOOKMARKS_PASSPHRASE"
+
+// reads passphrase from the given file, or from the environment
+func readPassphrase(name string) (string, error) {
+	if len(name) == 0 {
+		if pass := os.Getenv(passphraseEnv); len(pass) > 0 {
+			return pass, nil
+		}
+
+		return "", errors.New("Missing --passphrase-file option or " + passphraseEnv + " environment variable")
+	}
+
+	data, err := ioutil.ReadFile(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	// only the first line is the passphrase
+	pass := strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")
+
+	if len(pass) == 0 {
+		return "", errors.New(name + ": Empty passphrase")
+	}
+
+	return pass, nil
+}
+
+// reads the whole file, or STDIN if the name is "-"
+func readInput(name string) ([]byte, error) {
+	if name == stdin {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(name)
+}
+
+// encrypted file layout: magic, scrypt salt, GCM nonce, sealed data
+const (
+	cryptMagic    = "OPERA-BOOKMARKS-AESGCM-1\n"
+	cryptSaltSize = 16
+)
+
+// encrypts the data with a key derived from the passphrase
+func encryptData(data []byte, pass string) ([]byte, error) {
+	salt := make([]byte, cryptSaltSize)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newCipher(pass, salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, len(cryptMagic)+len(salt)+len(nonce)+len(data)+aead.Overhead())
+
+	res = append(res, cryptMagic...)
+	res = append(res, salt...)
+	res = append(res, nonce...)
+
+	return aead.Seal(res, nonce, data, []byte(cryptMagic)), nil
+}
+
+// reverse of encryptData
+func decryptData(data []byte, pass string) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(cryptMagic)) {
+		return nil, errors.New("Not an encrypted file")
+	}
+
+	data = data[len(cryptMagic):]
+
+	if len(data) < cryptSaltSize {
+		return nil, errors.New("Encrypted file is truncated")
+	}
+
+	aead, err := newCipher(pass, data[:cryptSaltSize])
+
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[cryptSaltSize:]
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("Encrypted file is truncated")
+	}
+
+	res, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(cryptMagic))
+
+	if err != nil {
+		return nil, errors.New("Wrong passphrase or corrupted file")
+	}
+
+	return res, nil
+}
+
+func newCipher(pass string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(pass), salt, 1<<15, 8, 1, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
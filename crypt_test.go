@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	plain := []byte("<html>bookmarks</html>")
+
+	data, err := encryptData(plain, "secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, plain) {
+		t.Fatal("Data is not encrypted")
+	}
+
+	res, err := decryptData(data, "secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(res, plain) {
+		t.Fatalf("Unexpected result: %q", res)
+	}
+
+	if _, err = decryptData(data, "wrong"); err == nil || err.Error() != "Wrong passphrase or corrupted file" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err = decryptData(plain, "secret"); err == nil || err.Error() != "Not an encrypted file" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestEncryptedOutput(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.html.gz")
+
+	err := withWriter(name, outputMode{}, true, "secret")(func(out StringWriter) error {
+		_, err := out.WriteString("hello")
+		return err
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decrypt, then decompress
+	passName := filepath.Join(dir, "pass")
+
+	if err = ioutil.WriteFile(passName, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	plainName := filepath.Join(dir, "out.html")
+
+	if err = decryptCmd([]string{"--passphrase-file", passName, "-o", plainName, name}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(plainName)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err = ioutil.ReadAll(gz); err != nil || string(data) != "hello" {
+		t.Fatalf("Unexpected result: %q, %v", data, err)
+	}
+
+	// the search index would not be encrypted
+	err = exportCmd([]string{"--encrypt", "--passphrase-file", passName, "-o", filepath.Join(dir, "new.html"),
+		"--search-index", filepath.Join(dir, "search.json")})
+
+	if err == nil || err.Error() != "Option --search-index cannot be used with --encrypt" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
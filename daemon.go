@@ -0,0 +1,219 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/juju/gnuflag"
+)
+
+func init() {
+	registerCommand("daemon", "Periodically run other commands, for example from a systemd service", daemonCmd)
+}
+
+// "daemon" command
+func daemonCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("daemon", "")
+
+	var steps stringList
+
+	fs.Var(&steps, "run",
+		"Command line to run, like \"backup --keep-daily 10\" or \"export -o /srv/www/bookmarks.html --force\" (may be repeated; "+
+			"the commands run in the given order)")
+
+	var every time.Duration
+
+	fs.DurationVar(&every, "every", 6*time.Hour, "Interval between runs")
+
+	var lockName string
+
+	fs.StringVar(&lockName, "lock", defaultLockFile(),
+		"Lock file preventing more than one daemon from running (default location is $XDG_DATA_HOME/"+programName+"/daemon.lock)")
+
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		return errors.New("Nothing to run, please specify --run")
+	}
+
+	if every < time.Minute {
+		return errors.New("Interval is too short: " + every.String())
+	}
+
+	if len(lockName) == 0 {
+		return errors.New("Lock file location is unknown, please specify --lock")
+	}
+
+	// a bad command line must not stop the daemon later, so all of them are checked now
+	flagErrors = gnuflag.ContinueOnError
+
+	if err := checkSteps(steps); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(lockName)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	// shutdown on signal, but only between steps
+	sig := make(chan os.Signal, 1)
+
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	level := verbosity
+	ticker := time.NewTicker(every)
+
+	defer ticker.Stop()
+
+	for {
+		for _, step := range steps {
+			select {
+			case <-sig:
+				logNotice("daemon stopped")
+				return nil
+			default:
+			}
+
+			// commands may change verbosity
+			verbosity = level
+			logInfo("running %q", step)
+
+			cmd, cmdArgs, _ := parseStep(step)
+
+			if err := cmd.run(cmdArgs); err != nil {
+				logWarn("%q: %s", step, err)
+			}
+		}
+
+		verbosity = level
+
+		select {
+		case <-ticker.C:
+		case <-sig:
+			logNotice("daemon stopped")
+			return nil
+		}
+	}
+}
+
+// splits the command line into the command and its arguments
+func parseStep(step string) (*command, []string, error) {
+	args := strings.Fields(step)
+
+	if len(args) == 0 {
+		return nil, nil, errors.New("Empty command in --run")
+	}
+
+	if cmd, ok := commands[args[0]]; ok && args[0] != "daemon" {
+		return cmd, args[1:], nil
+	}
+
+	return nil, nil, fmt.Errorf("Unknown command %q in --run", args[0])
+}
+
+// parses the command line of every step, without running the commands
+func checkSteps(steps []string) error {
+	level := verbosity
+	parseOnly = true
+
+	defer func() { verbosity, parseOnly = level, false }()
+
+	for _, step := range steps {
+		cmd, args, err := parseStep(step)
+
+		if err != nil {
+			return err
+		}
+
+		if err = cmd.run(args); err != errParsed {
+			if err == nil {
+				err = errors.New("Command line is not checked")
+			}
+
+			return fmt.Errorf("%q: %s", step, err)
+		}
+	}
+
+	return nil
+}
+
+func defaultLockFile() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "daemon.lock")
+	}
+
+	return ""
+}
+
+// takes an exclusive lock on the file, failing if it is already locked
+func lockFile(name string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+
+		if err == syscall.EWOULDBLOCK {
+			return nil, errors.New(name + ": Another daemon is already running")
+		}
+
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return func() { file.Close() }, nil
+}
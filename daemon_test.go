@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/juju/gnuflag"
+)
+
+func TestParseStep(t *testing.T) {
+	cmd, args, err := parseStep("  backup  --keep-daily 10 ")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd != commands["backup"] || len(args) != 2 || args[0] != "--keep-daily" || args[1] != "10" {
+		t.Fatalf("Unexpected result: %v", args)
+	}
+
+	for _, step := range []string{"", "daemon --every 1h", "nonsense"} {
+		if _, _, err = parseStep(step); err == nil {
+			t.Errorf("%q: no error", step)
+		}
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	flagErrors = gnuflag.ContinueOnError
+
+	defer func() { flagErrors = gnuflag.ExitOnError }()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "bookmarks.html")
+
+	if err := ioutil.WriteFile(out, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	good := []string{"export -q -o " + out + " --force", "backup --dir " + dir + " --keep-daily 10"}
+
+	if err := checkSteps(good); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatal("Command run while checking")
+	}
+
+	for _, step := range []string{"export --no-such-flag", "export -o " + out, "backup --keep-daily x", "nonsense"} {
+		if err := checkSteps(append(good, step)); err == nil {
+			t.Errorf("%q: no error", step)
+		}
+	}
+
+	if parseOnly {
+		t.Error("Parse-only mode left on")
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sub", "daemon.lock")
+	unlock, err := lockFile(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = lockFile(name); err == nil {
+		t.Fatal("File locked twice")
+	}
+
+	unlock()
+
+	if unlock, err = lockFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock()
+}
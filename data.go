@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerFormat(exportFunc{"json", foldersToJSON})
+	registerFormat(exportFunc{"jsonl", foldersToJSONLines})
+	registerFormat(exportFunc{"csv", foldersToCSV})
+	registerFormat(exportFunc{"alfred", foldersToAlfred})
+}
+
+// JSON tree
+type jsonFolder struct {
+	Name     string        `json:"name"`
+	Added    string        `json:"added,omitempty"`
+	Modified string        `json:"modified,omitempty"`
+	Links    []*jsonLink   `json:"links,omitempty"`
+	Folders  []*jsonFolder `json:"folders,omitempty"`
+}
+
+type jsonLink struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Added       string `json:"added,omitempty"`
+	Description string `json:"description,omitempty"`
+	Visits      int    `json:"visits,omitempty"`
+	LastVisit   string `json:"last_visit,omitempty"`
+}
+
+func foldersToJSON(folders []*Folder, opts *options, dest StringWriter) error {
+	enc := json.NewEncoder(asWriter(dest))
+
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(makeJSONFolders(folders))
+}
+
+func makeJSONFolders(folders []*Folder) []*jsonFolder {
+	res := make([]*jsonFolder, len(folders))
+
+	for i, f := range folders {
+		res[i] = &jsonFolder{
+			Name:     f.Name,
+			Added:    formatTime(f.Added),
+			Modified: formatTime(f.Modified),
+			Folders:  makeJSONFolders(f.Folders),
+		}
+
+		for _, link := range f.Links {
+			res[i].Links = append(res[i].Links, makeJSONLink(link))
+		}
+	}
+
+	return res
+}
+
+func makeJSONLink(link *Link) *jsonLink {
+	return &jsonLink{
+		Name:        link.Name,
+		URL:         link.URL,
+		Added:       formatTime(link.Added),
+		Description: link.description(),
+		Visits:      link.Visits,
+		LastVisit:   formatTime(link.LastVisit),
+	}
+}
+
+// JSON Lines, one object per link with its folder path
+type jsonLineLink struct {
+	Path []string `json:"path"`
+	*jsonLink
+}
+
+func foldersToJSONLines(folders []*Folder, opts *options, dest StringWriter) error {
+	enc := json.NewEncoder(asWriter(dest))
+
+	enc.SetEscapeHTML(false)
+
+	root := &Folder{Folders: folders}
+
+	return root.walkLinks(nil, func(path []string, link *Link) error {
+		if opts.projection == nil {
+			return enc.Encode(jsonLineLink{path, makeJSONLink(link)})
+		}
+
+		data, err := opts.projection.linkJSON(&queryLink{link, path})
+
+		if err == nil {
+			_, err = dest.WriteString(string(data))
+		}
+
+		return err
+	})
+}
+
+// CSV, one link per line
+func foldersToCSV(folders []*Folder, opts *options, dest StringWriter) error {
+	w := csv.NewWriter(asWriter(dest))
+
+	root := &Folder{Folders: folders}
+
+	if p := opts.projection; p != nil {
+		if !opts.output.append {
+			w.Write(p.fields)
+		}
+
+		root.walkLinks(nil, func(path []string, link *Link) error {
+			row := make([]string, len(p.fields))
+
+			for i, name := range p.fields {
+				row[i] = queryFields[name].format(&queryLink{link, path})
+			}
+
+			return w.Write(row)
+		})
+
+		w.Flush()
+		return w.Error()
+	}
+
+	if !opts.output.append {
+		w.Write([]string{"folder", "name", "url", "added", "description", "visits", "last_visit"})
+	}
+
+	root.walkLinks(nil, func(path []string, link *Link) error {
+		return w.Write([]string{
+			strings.Join(path, "/"),
+			link.Name,
+			link.URL,
+			formatTime(link.Added),
+			link.description(),
+			strconv.Itoa(link.Visits),
+			formatTime(link.LastVisit),
+		})
+	})
+
+	w.Flush()
+	return w.Error()
+}
+
+// RFC3339 time, or empty string if not set
+func formatTime(ts time.Time) string {
+	if !ts.After(googleEpoch) {
+		return ""
+	}
+
+	return ts.UTC().Format(time.RFC3339)
+}
+
+// io.Writer interface on top of StringWriter
+func asWriter(dest StringWriter) io.Writer {
+	if w, ok := dest.(io.Writer); ok {
+		return w
+	}
+
+	return stringWriterAdapter{dest}
+}
+
+type stringWriterAdapter struct {
+	StringWriter
+}
+
+func (w stringWriterAdapter) Write(p []byte) (int, error) {
+	return w.WriteString(string(p))
+}
+
+// Alfred Script Filter item
+type alfredItem struct {
+	UID          string `json:"uid"`
+	Title        string `json:"title"`
+	Subtitle     string `json:"subtitle"`
+	Arg          string `json:"arg"`
+	Match        string `json:"match"`
+	QuickLookURL string `json:"quicklookurl"`
+}
+
+// Alfred Script Filter JSON, with an item per link, matched by the name, the folder and the URL
+func foldersToAlfred(folders []*Folder, opts *options, dest StringWriter) error {
+	items := []alfredItem{}
+	root := &Folder{Folders: folders}
+
+	root.walkLinks(nil, func(path []string, link *Link) error {
+		title := foldSpaces(link.Name)
+
+		if len(title) == 0 {
+			title = link.URL
+		}
+
+		items = append(items, alfredItem{
+			UID:          link.URL,
+			Title:        title,
+			Subtitle:     link.URL,
+			Arg:          link.URL,
+			Match:        strings.Join(append(append([]string{title}, path...), linkHost(link.URL)), " "),
+			QuickLookURL: link.URL,
+		})
+
+		return nil
+	})
+
+	enc := json.NewEncoder(asWriter(dest))
+
+	enc.SetEscapeHTML(false)
+	return enc.Encode(map[string]interface{}{"items": items})
+}
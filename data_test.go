@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONLines(t *testing.T) {
+	folders := []*Folder{{
+		Node:    Node{Name: "Bar"},
+		Links:   []*Link{{Node: Node{Name: "A & B"}, URL: "https://a/"}},
+		Folders: []*Folder{{Node: Node{Name: "a/b"}, Links: []*Link{{URL: "https://b/"}}}},
+	}}
+
+	var buff bytes.Buffer
+
+	if err := foldersToJSONLines(folders, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"path":["Bar"],"name":"A & B","url":"https://a/"}
+{"path":["Bar","a/b"],"name":"","url":"https://b/"}
+`
+
+	if s := buff.String(); s != exp {
+		t.Fatalf("Unexpected result:\n%s", s)
+	}
+}
+
+func TestAlfred(t *testing.T) {
+	folders := []*Folder{{
+		Node:    Node{Name: "Bar"},
+		Links:   []*Link{{Node: Node{Name: "A & B"}, URL: "https://www.a.com/"}},
+		Folders: []*Folder{{Node: Node{Name: "Dev"}, Links: []*Link{{URL: "https://b.com/"}}}},
+	}}
+
+	var buff bytes.Buffer
+
+	if err := foldersToAlfred(folders, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"items":[` +
+		`{"uid":"https://www.a.com/","title":"A & B","subtitle":"https://www.a.com/","arg":"https://www.a.com/",` +
+		`"match":"A & B Bar a.com","quicklookurl":"https://www.a.com/"},` +
+		`{"uid":"https://b.com/","title":"https://b.com/","subtitle":"https://b.com/","arg":"https://b.com/",` +
+		`"match":"https://b.com/ Bar Dev b.com","quicklookurl":"https://b.com/"}]}
+`
+
+	if s := buff.String(); s != exp {
+		t.Fatalf("Unexpected result:\n%s", s)
+	}
+}
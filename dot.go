@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerFormat(exportFunc{"dot", foldersToDOT})
+}
+
+// Graphviz graph of the folder hierarchy, with the number of links in every folder,
+// and optionally the web sites linked from each folder
+func foldersToDOT(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &textWriter{dest: dest}
+	domains := make(map[string]string) // host -> node id
+
+	w.write("digraph bookmarks {\n\trankdir=LR;\n\tnode [shape=folder];\n")
+
+	var n int
+	var walk func(string, []*Folder)
+
+	walk = func(parent string, folders []*Folder) {
+		for _, f := range folders {
+			n++
+			id := "f" + strconv.Itoa(n)
+			label := f.Name
+
+			if len(f.Links) > 0 {
+				label += " (" + strconv.Itoa(len(f.Links)) + ")"
+			}
+
+			w.write("\t" + id + " [label=" + dotString(label) + "];\n")
+
+			if len(parent) > 0 {
+				w.write("\t" + parent + " -> " + id + ";\n")
+			}
+
+			if opts.domains {
+				dotDomains(w, id, f, domains)
+			}
+
+			walk(id, f.Folders)
+		}
+	}
+
+	walk("", folders)
+	w.write("}\n")
+	return w.err
+}
+
+// edges from the folder to the web sites it links to, with the number of links as the edge label
+func dotDomains(w *textWriter, folder string, f *Folder, domains map[string]string) {
+	count := make(map[string]int)
+
+	for _, link := range f.Links {
+		if host := linkHost(link.URL); len(host) > 0 {
+			count[host]++
+		}
+	}
+
+	hosts := make([]string, 0, len(count))
+
+	for host := range count {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		id, ok := domains[host]
+
+		if !ok {
+			id = "d" + strconv.Itoa(len(domains)+1)
+			domains[host] = id
+			w.write("\t" + id + " [label=" + dotString(host) + ", shape=ellipse];\n")
+		}
+
+		w.write("\t" + folder + " -> " + id + " [style=dashed")
+
+		if n := count[host]; n > 1 {
+			w.write(", label=" + strconv.Itoa(n))
+		}
+
+		w.write("];\n")
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+func dotString(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	sub := &Folder{Node: Node{Name: `Say "hi"`}, Links: []*Link{
+		{URL: "https://www.example.com/a"},
+		{URL: "https://example.com/b"},
+		{URL: "javascript:void(0)"},
+	}}
+	top := &Folder{Node: Node{Name: "Bar"}, Folders: []*Folder{sub}, Links: []*Link{{URL: "https://go.dev/"}}}
+
+	opts := newOptions()
+	opts.domains = true
+
+	var buff bytes.Buffer
+
+	if err := foldersToDOT([]*Folder{top}, opts, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `digraph bookmarks {
+	rankdir=LR;
+	node [shape=folder];
+	f1 [label="Bar (1)"];
+	d1 [label="go.dev", shape=ellipse];
+	f1 -> d1 [style=dashed];
+	f2 [label="Say \"hi\" (3)"];
+	f1 -> f2;
+	d2 [label="example.com", shape=ellipse];
+	f2 -> d2 [style=dashed, label=2];
+}
+`
+
+	if s := buff.String(); s != exp {
+		t.Errorf("Unexpected output:\n%s", s)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerFormat(exportFunc{"epub", foldersToEPUB})
+}
+
+// EPUB 3 book with a chapter per top-level folder
+func foldersToEPUB(folders []*Folder, opts *options, dest StringWriter) error {
+	id, err := bookID()
+
+	if err != nil {
+		return err
+	}
+
+	// XML ids cannot contain "/", or start with a digit
+	opts.anchors = folderAnchors(folders)
+
+	for f, anchor := range opts.anchors {
+		opts.anchors[f] = "f-" + strings.Replace(anchor, "/", ".", -1)
+	}
+
+	title := opts.phrases().title
+	z := zip.NewWriter(asWriter(dest))
+
+	// "mimetype" must be the first file, and not compressed
+	w, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		fn   fhtml
+	}{
+		{"META-INF/container.xml", htmlRawText(epubContainer)},
+		{"OEBPS/content.opf", epubPackage(id, title, len(folders), opts)},
+		{"OEBPS/style.css", htmlRawText(epubStyle)},
+		{"OEBPS/nav.xhtml", epubPage(title, opts, htmlListArgs(
+			htmlTag("h1", htmlText(title)),
+			htmlRawText(`<nav epub:type="toc" id="toc">`),
+			epubContents(folders, opts),
+			htmlRawText("</nav>"),
+		))},
+	}
+
+	for i, f := range folders {
+		files = append(files, struct {
+			name string
+			fn   fhtml
+		}{"OEBPS/" + epubChapter(i), epubPage(f.Name, opts, htmlListArgs(
+			htmlTagID("h1", opts.anchors[f], htmlText(f.Name)),
+			folderLinks(f, opts),
+			omittedSummary(f, opts),
+			folderList(f.Folders, 2, opts),
+		))})
+	}
+
+	var buff bytes.Buffer
+
+	for _, f := range files {
+		buff.Reset()
+
+		if err = f.fn(&buff); err != nil {
+			return err
+		}
+
+		if w, err = z.Create(f.name); err != nil {
+			return err
+		}
+
+		if _, err = w.Write(buff.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+func epubChapter(i int) string {
+	return "chapter-" + strconv.Itoa(i+1) + ".xhtml"
+}
+
+// random UUID as the book identifier
+func bookID() (string, error) {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func epubPage(title string, opts *options, body fhtml) fhtml {
+	return htmlListArgs(
+		htmlRawText(fmt.Sprintf(epubPageHeader, opts.lang, opts.lang, html.EscapeString(title))),
+		htmlTag("body", body),
+		htmlRawText("</html>\n"),
+	)
+}
+
+// table of contents, with links to chapters and their sub-folders
+func epubContents(folders []*Folder, opts *options) fhtml {
+	var list func([]*Folder, string) fhtml
+
+	list = func(folders []*Folder, page string) fhtml {
+		if len(folders) == 0 {
+			return htmlNil
+		}
+
+		fns := make([]fhtml, len(folders))
+
+		for i, f := range folders {
+			p := page
+
+			if len(p) == 0 {
+				p = epubChapter(i)
+			}
+
+			fns[i] = htmlTag("li", htmlListArgs(
+				htmlLink(p+"#"+opts.anchors[f], f.Name),
+				list(f.Folders, p),
+			))
+		}
+
+		return htmlTag("ol", htmlList(fns))
+	}
+
+	return list(folders, "")
+}
+
+// package document
+func epubPackage(id, title string, chapters int, opts *options) fhtml {
+	var items, spine bytes.Buffer
+
+	for i := 0; i < chapters; i++ {
+		name := epubChapter(i)
+
+		items.WriteString(`<item id="c` + strconv.Itoa(i+1) + `" href="` + name + `" media-type="application/xhtml+xml"/>` + "\n")
+		spine.WriteString(`<itemref idref="c` + strconv.Itoa(i+1) + `"/>` + "\n")
+	}
+
+	return htmlRawText(fmt.Sprintf(epubPackageTemplate,
+		html.EscapeString(id), html.EscapeString(title), opts.lang,
+		time.Now().UTC().Format("2006-01-02T15:04:05Z"), items.String(), spine.String()))
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`
+
+const epubPackageTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="style" href="style.css" media-type="text/css"/>
+%s</manifest>
+<spine>
+<itemref idref="nav"/>
+%s</spine>
+</package>
+`
+
+const epubPageHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%s" xml:lang="%s">
+<head>
+<meta charset="utf-8"/><title>%s</title><link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+`
+
+const epubStyle = `ul, ol { padding-left: 1.5em; }
+section section { margin-left: 1em; }
+li { margin-bottom: 0.3em; }
+li p { margin: 0.2em 0; font-size: 90%; }
+`
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEPUB(t *testing.T) {
+	sub := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{{Node: Node{Name: "A & B"}, URL: "https://a/?x=1&y=2"}}}
+	top := &Folder{Node: Node{Name: "2024 reading"}, Folders: []*Folder{sub}}
+	other := &Folder{Node: Node{Name: "Other"}, Links: []*Link{{Node: Node{Name: "C"}, URL: "https://c/"}}}
+
+	var buff bytes.Buffer
+
+	if err := foldersToEPUB([]*Folder{top, other}, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buff.Bytes()), int64(buff.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := z.File[0]; f.Name != "mimetype" || f.Method != zip.Store {
+		t.Errorf("Invalid first file: %q", f.Name)
+	}
+
+	files := make(map[string]string)
+
+	for _, f := range z.File {
+		r, err := f.Open()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name] = string(data)
+
+		// all documents must be well-formed XML
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") || strings.HasSuffix(f.Name, ".xml") {
+			d := xml.NewDecoder(bytes.NewReader(data))
+
+			for err == nil {
+				_, err = d.Token()
+			}
+
+			if err.Error() != "EOF" {
+				t.Errorf("%s: %s", f.Name, err)
+			}
+		}
+	}
+
+	exp := map[string][]string{
+		"OEBPS/content.opf":     {`href="chapter-2.xhtml"`, `<itemref idref="c2"/>`, `<dc:language>en</dc:language>`},
+		"OEBPS/nav.xhtml":       {`<a href="chapter-1.xhtml#f-2024-reading.sub">Sub</a>`, `<a href="chapter-2.xhtml#f-other">Other</a>`},
+		"OEBPS/chapter-1.xhtml": {`<h1 id="f-2024-reading">2024 reading</h1>`, `<a href="https://a/?x=1&amp;y=2">A &amp; B</a>`},
+		"OEBPS/chapter-2.xhtml": {`<a href="https://c/">C</a>`},
+	}
+
+	for name, list := range exp {
+		for _, s := range list {
+			if !strings.Contains(files[name], s) {
+				t.Errorf("%s: %q not found in:\n%s", name, s, files[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// $XDG_CACHE_HOME/opera-bookmarks/favicons, or empty string if the cache directory is unknown
+func defaultFaviconCache() string {
+	if dir := cacheDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "favicons")
+	}
+
+	return ""
+}
+
+// file name extensions of the icon types, used to restore the type from the cache
+var faviconTypes = map[string]string{
+	".ico":  "image/x-icon",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+// fetches the icons of all the web sites of the links, using the cache directory where possible;
+// returns data URIs of the icons by site origin, like "https://example.com"
+func (opts *options) fetchFavicons(roots []*Folder) (map[string]string, error) {
+	dir := opts.faviconCache
+
+	if len(dir) == 0 {
+		return nil, errors.New("Favicon cache location is unknown, please specify --favicon-cache")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// one link per site
+	var sites []*Link
+
+	seen := make(map[string]bool)
+
+	for _, link := range webLinks(roots) {
+		if origin := siteOrigin(link.URL); !seen[origin] {
+			seen[origin] = true
+			sites = append(sites, &Link{URL: origin})
+		}
+	}
+
+	client := opts.newWebClient()
+	icons := make(map[string]string, len(sites))
+
+	var lock sync.Mutex
+
+	logInfo("fetching icons of %d web sites", len(sites))
+
+	err := forEachLink(sites, opts.concurrency, func(site *Link) error {
+		uri, err := cachedFavicon(client, dir, site.URL, opts.cacheMaxAge)
+
+		if err != nil {
+			return err
+		}
+
+		if len(uri) > 0 {
+			lock.Lock()
+			icons[site.URL] = uri
+			lock.Unlock()
+		}
+
+		return nil
+	})
+
+	return icons, err
+}
+
+// "scheme://host" of the URL
+func siteOrigin(link string) string {
+	u, err := url.Parse(link)
+
+	if err != nil {
+		return ""
+	}
+
+	return u.Scheme + "://" + strings.ToLower(u.Host)
+}
+
+// icon of the site as data URI, or empty string if the site has no icon; the icon is saved
+// in the directory, while the lack of an icon is remembered for the given time
+func cachedFavicon(client *webClient, dir, origin string, maxAge time.Duration) (string, error) {
+	base := strings.NewReplacer("://", "_", ":", "_").Replace(origin)
+
+	for ext, ctype := range faviconTypes {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, base+ext)); err == nil {
+			return faviconURI(ctype, data), nil
+		}
+	}
+
+	none := filepath.Join(dir, base+".none")
+
+	if info, err := os.Stat(none); err == nil && time.Since(info.ModTime()) < maxAge {
+		return "", nil
+	}
+
+	data, ctype, err := fetchFavicon(client, origin)
+
+	if err != nil {
+		logWarn("%s: no icon: %s", origin, err)
+		return "", writeFileAtomic(none, nil)
+	}
+
+	for ext, t := range faviconTypes {
+		if t == ctype {
+			os.Remove(none)
+			return faviconURI(ctype, data), writeFileAtomic(filepath.Join(dir, base+ext), data)
+		}
+	}
+
+	return "", nil
+}
+
+func faviconURI(ctype string, data []byte) string {
+	return "data:" + ctype + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// maximum size of an icon
+const maxFaviconSize = 256 * 1024
+
+// fetches the icon given by <link rel="icon"> of the home page, or /favicon.ico
+func fetchFavicon(client *webClient, origin string) ([]byte, string, error) {
+	icon := origin + "/favicon.ico"
+
+	if data, _, home, err := fetchResource(client, origin+"/"); err == nil {
+		if href := pageIcon(string(data)); len(href) > 0 {
+			icon = resolveURL(home, href)
+		}
+	}
+
+	data, ctype, _, err := fetchResource(client, icon)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > maxFaviconSize {
+		return nil, "", errors.New("Icon is too large")
+	}
+
+	// some servers get the type of icons wrong
+	if mt, _, _ := mime.ParseMediaType(ctype); strings.HasPrefix(mt, "image/") {
+		ctype = mt
+	} else {
+		ctype, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	if ctype == "image/vnd.microsoft.icon" {
+		ctype = "image/x-icon"
+	}
+
+	if !strings.HasPrefix(ctype, "image/") {
+		return nil, "", errors.New("Not an image: " + ctype)
+	}
+
+	return data, ctype, nil
+}
+
+// href of the first <link rel="icon"> in the page
+func pageIcon(page string) string {
+	z := html.NewTokenizer(strings.NewReader(page))
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+
+			if string(name) == "body" {
+				return ""
+			}
+
+			if string(name) != "link" {
+				continue
+			}
+
+			var rel, href string
+
+			for hasAttr {
+				var k, v []byte
+
+				k, v, hasAttr = z.TagAttr()
+
+				switch string(k) {
+				case "rel":
+					rel = strings.ToLower(string(v))
+				case "href":
+					href = string(v)
+				}
+			}
+
+			if contains(strings.Fields(rel), "icon") && len(href) > 0 {
+				return href
+			}
+		}
+	}
+}
+
+// <img> with the site icon of the link, if any
+func (opts *options) faviconImg(link string) string {
+	if uri, ok := opts.favicons[siteOrigin(link)]; ok && isWebURL(link) {
+		return `<img class="favicon" src="` + uri + `" alt="" width="16" height="16"/> `
+	}
+
+	return ""
+}
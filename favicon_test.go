@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFavicons(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n0000"
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="Shortcut Icon" href="/static/i.png"></head><body></body></html>`)
+		case "/static/i.png":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, png)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	bare := httptest.NewServer(http.NotFoundHandler())
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	cache := filepath.Join(dir, "favicons")
+	out := filepath.Join(dir, "bookmarks.html")
+	data := `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [
+			{"type": "url", "name": "Site", "url": "` + site.URL + `/page", "id": "2", "date_added": "0"},
+			{"type": "url", "name": "Bare", "url": "` + bare.URL + `/page", "id": "3", "date_added": "0"}
+		]}}}`
+
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "--force", "--embed-favicons", "--favicon-cache", cache, "--retries", "0", "-i", name, "-o", out}
+	img := `<img class="favicon" src="data:image/png;base64,` + base64.StdEncoding.EncodeToString([]byte(png)) +
+		`" alt="" width="16" height="16"/> <a href="` + site.URL + `/page">Site</a>`
+
+	for i := 0; i < 2; i++ {
+		if err := exportCmd(args); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := ioutil.ReadFile(out)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(res), img) || strings.Count(string(res), `class="favicon"`) != 1 {
+			t.Fatalf("Unexpected output:\n%s", res)
+		}
+
+		// the second time the icons come from the cache
+		site.Close()
+		bare.Close()
+	}
+
+	files, err := ioutil.ReadDir(cache)
+
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Unexpected cache: %v, %v", files, err)
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".png") && !strings.HasSuffix(f.Name(), ".none") {
+			t.Errorf("Unexpected file %s", f.Name())
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(cache, strings.NewReplacer("://", "_", ":", "_").Replace(site.URL)+".png")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+func init() {
+	registerCommand("fmt", "Print the Bookmarks file with sorted keys and stable indentation, for version control", fmtCmd)
+}
+
+// "fmt" command
+func fmtCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("fmt", "")
+
+	opts.inputFlags(fs)
+	fs.StringVar(&opts.outputName, "output", stdout, outputHelp)
+	fs.StringVar(&opts.outputName, "o", stdout, outputHelp)
+	opts.forceFlag(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(opts.inputs) > 1 {
+		return errors.New("Only one input file is allowed")
+	}
+
+	src, err := readInput(opts.inputs[0].name)
+
+	if err != nil {
+		return err
+	}
+
+	return withOutput(opts.outputName, opts.output)(func(dest io.Writer) error {
+		return canonicalJSON(bytes.NewReader(src), dest)
+	})
+}
+
+// re-encodes JSON with object keys sorted and two-space indentation, keeping all values
+// (including the numbers) as they are
+func canonicalJSON(src io.Reader, dest io.Writer) error {
+	dec := json.NewDecoder(src)
+
+	dec.UseNumber()
+
+	var val interface{}
+
+	if err := dec.Decode(&val); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(dest)
+
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(val)
+}
+
+// writes the data back in Opera format, with the checksum recomputed the same way
+// as in the original file, and the version and unknown fields preserved
+func (data *rawData) save(name string) error {
+	data.Checksum = rootsChecksum(data.Roots, data.sumAll)
+
+	if len(data.Version) == 0 {
+		data.Version = json.RawMessage("1")
+	}
+
+	// all other fields are written back as they are, so Sync state is not broken
+	top := make(map[string]interface{}, len(data.Other)+3)
+
+	for key, val := range data.Other {
+		top[key] = val
+	}
+
+	top["checksum"] = data.Checksum
+	top["roots"] = data.Roots
+	top["version"] = data.Version
+
+	var buff bytes.Buffer
+
+	enc := json.NewEncoder(&buff)
+
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "   ")
+
+	if err := enc.Encode(top); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(name, buff.Bytes())
+}
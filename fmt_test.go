@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveRawData(t *testing.T) {
+	data, err := decodeRawData("test", strings.NewReader(testBookmarks("A")))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data.Version = []byte("2")
+
+	// edit the name
+	bar := data.Roots.(map[string]interface{})["bookmark_bar"].(map[string]interface{})
+	bar["children"].([]interface{})[0].(map[string]interface{})["name"] = "<B>"
+
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+
+	if err = data.save(name); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err = loadRawData(name); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Checksum) != 32 || !data.sumValid || string(data.Version) != "2" {
+		t.Fatalf("Unexpected checksum %q or version %q", data.Checksum, data.Version)
+	}
+
+	root, err := loadTree(name, readOptions{strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := linkLines(root); len(lines) != 1 || lines[0] != "Bar/<B> <https://example.com/>" {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	// the file mode is kept
+	if err = os.Chmod(name, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = data.save(name); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Unexpected file mode: %v", info.Mode())
+	}
+}
+
+func TestSaveKeepsUnknownFields(t *testing.T) {
+	const src = `{"checksum": "", "roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1",
+		"date_added": "0", "date_modified": "0", "sync_transaction_version": "42", "x_counter": 12345678901234567890,
+		"children": []}}, "sync_metadata": "c3luYw==", "version": 1}`
+
+	data, err := decodeRawData("test", strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+
+	if err = data.save(name); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ioutil.ReadFile(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{`"sync_metadata": "c3luYw=="`, `"sync_transaction_version": "42"`, `"x_counter": 12345678901234567890`} {
+		if !strings.Contains(string(res), s) {
+			t.Errorf("Missing %s in:\n%s", s, res)
+		}
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	const src = `{"version": 1, "roots": {"other": {"name": "<O>", "date_added": "13100000000000000", "id": 12345678901234567890},
+		"bookmark_bar": {"children": [], "type": "folder"}}, "checksum": "x", "sync_metadata": "abc"}`
+
+	const exp = `{
+  "checksum": "x",
+  "roots": {
+    "bookmark_bar": {
+      "children": [],
+      "type": "folder"
+    },
+    "other": {
+      "date_added": "13100000000000000",
+      "id": 12345678901234567890,
+      "name": "<O>"
+    }
+  },
+  "sync_metadata": "abc",
+  "version": 1
+}
+`
+
+	var buff bytes.Buffer
+
+	if err := canonicalJSON(strings.NewReader(src), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if buff.String() != exp {
+		t.Fatalf("Unexpected output:\n%s", buff.String())
+	}
+}
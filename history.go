@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+)
+
+// adds visit counts and last visit times from the browser History database
+func addHistory(name string, roots []*Folder) error {
+	visits, err := loadHistory(name)
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	var found int
+
+	for _, root := range roots {
+		for _, link := range root.allLinks() {
+			if v, ok := visits[link.URL]; ok {
+				link.Visits, link.LastVisit = v.count, googleTime(v.last)
+				found++
+			}
+		}
+	}
+
+	logInfo("%s: %d links with history", name, found)
+	return nil
+}
+
+type visitInfo struct {
+	count int
+	last  int64
+}
+
+// reads visit information for all URLs
+func loadHistory(name string) (map[string]visitInfo, error) {
+	// the database is locked while the browser is running, but opening it
+	// read-only as immutable still works
+	db, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: name}).EscapedPath()+"?mode=ro&immutable=1")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query("SELECT url, visit_count, last_visit_time FROM urls")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	visits := make(map[string]visitInfo)
+
+	for rows.Next() {
+		var link string
+		var v visitInfo
+
+		if err = rows.Scan(&link, &v.count, &v.last); err != nil {
+			return nil, err
+		}
+
+		visits[link] = v
+	}
+
+	return visits, rows.Err()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddHistory(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "History")
+	db, err := sql.Open("sqlite3", name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR,
+		visit_count INTEGER DEFAULT 0 NOT NULL, last_visit_time INTEGER NOT NULL);
+		INSERT INTO urls (url, visit_count, last_visit_time) VALUES ('https://go.dev/', 7, 13300000000000000);`)
+
+	db.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := &Folder{Links: []*Link{{URL: "https://go.dev/"}, {URL: "https://github.com/"}}}
+
+	if err = addHistory(name, []*Folder{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	if l := root.Links[0]; l.Visits != 7 || !l.LastVisit.Equal(googleTime(13300000000000000)) {
+		t.Fatalf("Unexpected history: %d, %s", l.Visits, l.LastVisit)
+	}
+
+	if l := root.Links[1]; l.Visits != 0 || !l.LastVisit.IsZero() {
+		t.Fatalf("Unexpected history: %d, %s", l.Visits, l.LastVisit)
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	root := &Folder{
+		Links: []*Link{
+			{Node: Node{Name: "b"}, Visits: 1},
+			{Node: Node{Name: "C"}, Visits: 3},
+			{Node: Node{Name: "a"}, Visits: 2},
+		},
+		Folders: []*Folder{{Node: Node{Name: "y"}}, {Node: Node{Name: "X"}}},
+	}
+
+	order, err := parseSortOrder("name", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order.apply(root)
+
+	if root.Links[0].Name != "a" || root.Links[2].Name != "C" || root.Folders[0].Name != "X" {
+		t.Fatal("Not sorted by name")
+	}
+
+	if order, err = parseSortOrder("-visits", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	order.apply(root)
+
+	if root.Links[0].Visits != 3 || root.Links[2].Visits != 1 || root.Folders[0].Name != "X" {
+		t.Fatal("Not sorted by visits")
+	}
+
+	if _, err = parseSortOrder("size", ""); err == nil {
+		t.Fatal("Unknown key accepted")
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerCommand("native-host", "Serve a browser extension via native messaging (started by the browser)", nativeHostCmd)
+}
+
+// name of the native messaging host, as registered with the browser
+const nativeHostName = "opera_bookmarks"
+
+// "native-host" command; the browser starts the program with the extension origin as the only argument
+func nativeHostCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("native-host", "[ORIGIN]")
+
+	var manifest, inbox string
+	var window int
+
+	opts.inputFlags(fs)
+	fs.StringVar(&manifest, "manifest", "", "Print the host manifest for the extension with this id, and exit")
+	fs.StringVar(&inbox, "inbox", defaultInbox(),
+		"File to append saved pages to, for \"import --from netscape\" (default is $XDG_DATA_HOME/"+programName+"/inbox.html)")
+	fs.IntVar(&window, "parent-window", 0, "Parent window handle, passed by the browser on Windows (ignored)")
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if len(manifest) > 0 {
+		return printHostManifest(manifest)
+	}
+
+	if fs.NArg() > 1 {
+		return errors.New("Too many arguments")
+	}
+
+	if len(opts.inputs) > 1 {
+		return errors.New("Only one input file is allowed")
+	}
+
+	host := &nativeHost{name: opts.inputs[0].name, inbox: inbox, ro: opts.readOptions}
+
+	return host.serve(os.Stdin, os.Stdout)
+}
+
+func defaultInbox() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "inbox.html")
+	}
+
+	return ""
+}
+
+// prints the manifest to put into NativeMessagingHosts directory of the browser profile
+func printHostManifest(id string) error {
+	path, err := os.Executable()
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"name":            nativeHostName,
+		"description":     "Opera bookmarks",
+		"path":            path,
+		"type":            "stdio",
+		"allowed_origins": []string{"chrome-extension://" + id + "/"},
+	}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
+
+// native messaging host, serving requests until the browser closes the pipe
+type nativeHost struct {
+	name, inbox string // Bookmarks file, and file of saved pages
+	ro          readOptions
+}
+
+// request from the extension
+type hostRequest struct {
+	Action string `json:"action"` // "query", "save" or "ping"
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"` // maximum number of links found, default 100
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+}
+
+type hostResponse struct {
+	OK    bool              `json:"ok"`
+	Error string            `json:"error,omitempty"`
+	Links []json.RawMessage `json:"links,omitempty"`
+}
+
+// maximum size of a message either way (the browser accepts no more from the host)
+const maxHostMessage = 1024 * 1024
+
+// messages are JSON, prefixed with 32-bit length in little-endian byte order (which is
+// the native one on all the platforms the browsers run on)
+func (host *nativeHost) serve(src io.Reader, dest io.Writer) error {
+	r := bufio.NewReader(src)
+
+	for {
+		var size uint32
+
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		if size > maxHostMessage {
+			// skip the message, keeping the stream in sync
+			if _, err := io.CopyN(ioutil.Discard, r, int64(size)); err != nil {
+				return err
+			}
+
+			if err := writeHostMessage(dest, &hostResponse{Error: "Message is too long"}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		msg := make([]byte, size)
+
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return err
+		}
+
+		var req hostRequest
+		var resp hostResponse
+
+		if err := json.Unmarshal(msg, &req); err != nil {
+			return fmt.Errorf("Invalid message: %s", err)
+		}
+
+		if err := host.handle(&req, &resp); err != nil {
+			resp = hostResponse{Error: err.Error()}
+		} else {
+			resp.OK = true
+		}
+
+		if err := writeHostMessage(dest, &resp); err != nil {
+			return err
+		}
+	}
+}
+
+// sends the response, replacing one that is too long with an error
+func writeHostMessage(dest io.Writer, resp *hostResponse) error {
+	data, err := json.Marshal(resp)
+
+	if err != nil {
+		return err
+	}
+
+	if len(data) > maxHostMessage {
+		if data, err = json.Marshal(&hostResponse{Error: "Too many links, please set a lower limit"}); err != nil {
+			return err
+		}
+	}
+
+	if err = binary.Write(dest, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+
+	_, err = dest.Write(data)
+	return err
+}
+
+func (host *nativeHost) handle(req *hostRequest, resp *hostResponse) error {
+	switch req.Action {
+	case "ping":
+		return nil
+	case "query":
+		links, err := host.query(req.Query, req.Limit)
+
+		resp.Links = links
+		return err
+	case "save":
+		return host.save(req.URL, req.Title, time.Now())
+	default:
+		return fmt.Errorf("Unknown action %q", req.Action)
+	}
+}
+
+// reads the Bookmarks file (which is safe while the browser is running), and runs the query
+func (host *nativeHost) query(s string, limit int) ([]json.RawMessage, error) {
+	q, err := parseQuery(s, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(q.fields) == 0 {
+		q.fields = []string{"name", "url", "folder"}
+	}
+
+	if limit <= 0 {
+		limit = 100
+	}
+
+	root, err := loadTree(host.name, host.ro)
+
+	if err != nil {
+		return nil, err
+	}
+
+	renameRoots(root, nil)
+	q.apply(root)
+
+	links := []json.RawMessage{}
+
+	err = root.walkLinks(nil, func(path []string, link *Link) error {
+		if len(links) == limit {
+			return nil
+		}
+
+		data, err := q.linkJSON(&queryLink{link, path})
+
+		if err == nil {
+			links = append(links, json.RawMessage(data))
+		}
+
+		return err
+	})
+
+	return links, err
+}
+
+// appends the page to the inbox, in Netscape bookmark file format, to be imported when the browser is closed
+func (host *nativeHost) save(url, title string, now time.Time) error {
+	if !isWebURL(url) {
+		return fmt.Errorf("Invalid URL %q", url)
+	}
+
+	if len(host.inbox) == 0 {
+		return errors.New("Inbox location is unknown")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(host.inbox), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(host.inbox, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString("<DT><A HREF=\"" + htmlHref(url) + "\" ADD_DATE=\"" + strconv.FormatInt(now.Unix(), 10) + "\">" +
+		html.EscapeString(foldSpaces(title)) + "</A>\n")
+
+	if e := file.Close(); err == nil {
+		err = e
+	}
+
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNativeHost(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+
+	if err := ioutil.WriteFile(name, []byte(testBookmarks("v1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	host := &nativeHost{name: name, inbox: filepath.Join(dir, "inbox", "inbox.html")}
+
+	var src, dest bytes.Buffer
+
+	for _, req := range []string{
+		`{"action": "ping"}`,
+		`{"action": "query", "query": "host == example.com"}`,
+		`{"action": "query", "query": "host == == example.com"}`,
+		`{"action": "save", "url": "https://go.dev/", "title": "Go <lang>"}`,
+		`{"action": "save", "url": "file:///etc/passwd"}`,
+		`{"action": "delete"}`,
+		`{"action": "ping", "query": "` + strings.Repeat("x", maxHostMessage) + `"}`,
+		`{"action": "ping"}`,
+	} {
+		binary.Write(&src, binary.LittleEndian, uint32(len(req)+1))
+		src.WriteString(req + " ")
+	}
+
+	if err := host.serve(&src, &dest); err != nil {
+		t.Fatal(err)
+	}
+
+	var res []string
+
+	for dest.Len() > 0 {
+		var size uint32
+
+		if err := binary.Read(&dest, binary.LittleEndian, &size); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp hostResponse
+
+		if err := json.Unmarshal(dest.Next(int(size)), &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		s := "false"
+
+		if resp.OK {
+			s = "true"
+		}
+
+		for _, link := range resp.Links {
+			s += " " + string(link)
+		}
+
+		res = append(res, s)
+	}
+
+	exp := []string{
+		"true",
+		`true {"name":"v1","url":"https://example.com/","folder":"Bar"}`,
+		"false",
+		"true",
+		"false",
+		"false",
+		"false",
+		"true",
+	}
+
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("Unexpected responses: %q", res)
+	}
+
+	root, err := loadNetscape(host.inbox, readOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := linkLines(root); !reflect.DeepEqual(lines, []string{"Bookmarks/Go <lang> <https://go.dev/>"}) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+}
@@ -0,0 +1,589 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/gnuflag"
+	"golang.org/x/net/html"
+)
+
+func init() {
+	registerCommand("import", "Add bookmarks from a file in another format, like a list of URLs, to the Bookmarks file", importCmd)
+	readers["urls"] = loadURLs
+}
+
+// options common to "import" and "add"
+type importOptions struct {
+	*options
+	folder []string
+	titles bool
+}
+
+func newImportOptions(fs *gnuflag.FlagSet) *importOptions {
+	opts := &importOptions{options: newOptions()}
+
+	opts.inputFlags(fs)
+	opts.networkFlags(fs)
+	opts.writeBackFlags(fs)
+	opts.logFlags(fs)
+
+	fs.Var((*pathValue)(&opts.folder), "folder",
+		"Folder to add the bookmarks to, like \"Bookmarks bar/Imported\" (made under \"Other bookmarks\" unless found)")
+	fs.BoolVar(&opts.titles, "fetch-titles", false, "Fetch pages of unnamed bookmarks for their titles")
+	fs.StringVar(&opts.pageCache, "page-cache", opts.pageCache, "File caching information fetched from pages")
+
+	opts.folder = []string{"Imported"}
+	return opts
+}
+
+func (opts *importOptions) parse(fs *gnuflag.FlagSet, args []string) error {
+	if err := opts.options.parse(fs, args); err != nil {
+		return err
+	}
+
+	if len(opts.inputs) > 1 || opts.inputs[0].name == stdin {
+		return errors.New("Only one input file is allowed, and it cannot be STDIN")
+	}
+
+	if len(opts.folder) == 0 {
+		return errors.New("Invalid --folder")
+	}
+
+	return nil
+}
+
+// "import" command
+func importCmd(args []string) error {
+	fs := newFlagSet("import", "FILE...")
+	opts := newImportOptions(fs)
+
+	opts.from = "urls"
+
+	fs.StringVar(&opts.from, "from", opts.from, "Format of the imported files: "+readerNames())
+	fs.BoolVar(&opts.headings, "headings", false, "Put links of Markdown files in folders by their headings")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("No files to import")
+	}
+
+	src := &Folder{container: true}
+
+	for _, arg := range fs.Args() {
+		root, err := readers[opts.from](arg, opts.readOptions)
+
+		if err != nil {
+			return err
+		}
+
+		src.Folders = append(src.Folders, root)
+	}
+
+	return opts.add(src)
+}
+
+// adds the links of the tree to the Bookmarks file
+func (opts *importOptions) add(src *Folder) error {
+	if opts.titles {
+		unnamed := new(Folder)
+
+		for _, link := range src.allLinks() {
+			if len(strings.TrimSpace(link.Name)) == 0 {
+				unnamed.Links = append(unnamed.Links, link)
+			}
+		}
+
+		pages, err := opts.fetchPages([]*Folder{unnamed})
+
+		if err != nil {
+			return err
+		}
+
+		refreshTitles([]*Folder{unnamed}, pages, true)
+	}
+
+	for _, link := range src.allLinks() {
+		if len(strings.TrimSpace(link.Name)) == 0 {
+			link.Name = urlTitle(link.URL)
+		}
+	}
+
+	name := opts.inputs[0].name
+	data, err := loadRawData(name)
+
+	if err != nil {
+		return err
+	}
+
+	if !data.sumValid {
+		return errors.New(name + ": Checksum mismatch, the file may be corrupted or edited by hand")
+	}
+
+	from, err := buildTree("roots", data.Roots)
+
+	if err != nil {
+		return err
+	}
+
+	if err = importFolder(data.Roots, opts.folder, src, time.Now()); err != nil {
+		return err
+	}
+
+	to, err := buildTree("roots", data.Roots)
+
+	if err != nil {
+		return err
+	}
+
+	changes := treeChanges(from, to)
+
+	if len(changes) == 0 {
+		logNotice("nothing to add")
+		return nil
+	}
+
+	if apply, err := opts.confirm(changes); !apply {
+		return err
+	}
+
+	if err = data.save(name); err != nil {
+		return err
+	}
+
+	logNotice("added %d links to %s; Opera must not be running while the file is replaced", len(changes), name)
+	return nil
+}
+
+// folder path flag, like "Bookmarks bar/News"
+type pathValue []string
+
+func (p *pathValue) String() string {
+	return strings.Join(*p, "/")
+}
+
+func (p *pathValue) Set(s string) error {
+	*p = splitPath(s)
+	return nil
+}
+
+// folder names of the path like "Bookmarks bar/News"
+func splitPath(s string) (names []string) {
+	for _, name := range strings.Split(s, "/") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	return
+}
+
+// adds the links of the tree to the folder of the raw tree, keeping their sub-folders, and skipping
+// the links already in the bookmarks; the folder is made under "other" root if the path
+// does not start with a root folder name
+func importFolder(roots interface{}, path []string, src *Folder, now time.Time) error {
+	b := &rawBuilder{now: googleTimeStamp(now)}
+	seen := make(map[string]bool)
+	var target map[string]interface{}
+
+	walkRawFolders(roots, func(p []string, node map[string]interface{}) {
+		b.maxID(node)
+
+		if target == nil && len(p) == 1 && p[0] == path[0] {
+			target, path = node, path[1:]
+		}
+
+		children, _ := node["children"].([]interface{})
+
+		for _, child := range children {
+			if rawString(child, "type") == "url" {
+				seen[rawString(child, "url")] = true
+			}
+		}
+	})
+
+	if target == nil {
+		rm, _ := roots.(map[string]interface{})
+
+		if target, _ = rm["other"].(map[string]interface{}); target == nil {
+			return errors.New("Root folder \"other\" is not found")
+		}
+	}
+
+	for _, name := range path {
+		target = b.subFolder(target, name)
+	}
+
+	var skipped int
+
+	// the top folders of the imported tree are not kept
+	var add func(*Folder)
+
+	add = func(f *Folder) {
+		if f.container {
+			for _, sub := range f.Folders {
+				add(sub)
+			}
+
+			return
+		}
+
+		skipped += b.merge(target, f, seen)
+	}
+
+	add(src)
+
+	if skipped > 0 {
+		logInfo("skipped %d links already in the bookmarks", skipped)
+	}
+
+	return nil
+}
+
+// finds the sub-folder by its name, or makes a new one
+func (b *rawBuilder) subFolder(node map[string]interface{}, name string) map[string]interface{} {
+	children, _ := node["children"].([]interface{})
+
+	for _, child := range children {
+		if c, ok := child.(map[string]interface{}); ok && rawString(c, "type") == "folder" && rawString(c, "name") == name {
+			return c
+		}
+	}
+
+	sub := b.newNode("folder", name)
+	sub["date_modified"] = b.now
+	sub["children"] = []interface{}{}
+	node["children"] = append(children, sub)
+	node["date_modified"] = b.now
+
+	return sub
+}
+
+// adds the contents of the folder to the raw folder, merging the sub-folders of the same name,
+// and returns the number of links skipped as already seen
+func (b *rawBuilder) merge(node map[string]interface{}, f *Folder, seen map[string]bool) (skipped int) {
+	for _, link := range f.Links {
+		if seen[link.URL] {
+			skipped++
+			continue
+		}
+
+		seen[link.URL] = true
+
+		child := b.newNode("url", link.Name)
+		child["url"] = link.URL
+
+		if !link.Added.IsZero() {
+			child["date_added"] = googleTimeStamp(link.Added)
+		}
+
+		children, _ := node["children"].([]interface{})
+		node["children"] = append(children, child)
+		node["date_modified"] = b.now
+	}
+
+	for _, sub := range f.Folders {
+		skipped += b.merge(b.subFolder(node, sub.Name), sub, seen)
+	}
+
+	return
+}
+
+// reads a list of URLs, one per line, skipping empty lines and "#" comments, into "URLs" folder
+func loadURLs(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := parseURLs(bytes.NewReader(src))
+
+	if err != nil {
+		if name == stdin {
+			name = "STDIN"
+		}
+
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return &Folder{Folders: []*Folder{top}, container: true}, nil
+}
+
+func parseURLs(src io.Reader) (*Folder, error) {
+	top := &Folder{Node: Node{Name: "URLs", Key: "urls"}}
+	scanner := bufio.NewScanner(src)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if u, err := url.Parse(line); err != nil || len(u.Scheme) == 0 {
+			return nil, fmt.Errorf("Line %d: Invalid URL %q", n, line)
+		}
+
+		top.Links = append(top.Links, &Link{URL: line})
+	}
+
+	return top, scanner.Err()
+}
+
+// link with tags, from a bookmarking service
+type taggedLink struct {
+	title, url, desc string
+	added            time.Time
+	path             []string // names of the folders, if any
+	tags             []string
+}
+
+// makes a tree of a single folder with the sub-folders of the links, where the links go to a sub-folder
+// per tag, if any; links with more than one tag are put in every tag folder; the folders are sorted by name
+func taggedTree(name, key string, items []taggedLink) *Folder {
+	top := &Folder{Node: Node{Name: name, Key: key}}
+
+	for _, item := range items {
+		f := top.subFolder(item.path)
+
+		if len(item.tags) == 0 {
+			f.Links = append(f.Links, item.link())
+		}
+
+		for _, tag := range item.tags {
+			sub := f.subFolder([]string{tag})
+			sub.Links = append(sub.Links, item.link())
+		}
+	}
+
+	sortFolderNames(top)
+	return &Folder{Folders: []*Folder{top}, container: true}
+}
+
+func sortFolderNames(folder *Folder) {
+	sort.SliceStable(folder.Folders, func(i, j int) bool {
+		return lessName(&folder.Folders[i].Node, &folder.Folders[j].Node)
+	})
+
+	for _, f := range folder.Folders {
+		sortFolderNames(f)
+	}
+}
+
+func (item *taggedLink) link() *Link {
+	link := &Link{Node: Node{Name: item.title, Added: item.added}, URL: item.url}
+
+	if len(item.desc) > 0 {
+		link.Meta = map[string]string{"Description": item.desc}
+	}
+
+	return link
+}
+
+// splits the list of tags, dropping empty and repeated ones
+func splitTags(s, sep string) (tags []string) {
+	for _, tag := range strings.Split(s, sep) {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return
+}
+
+// removes the tags repeating the folder names, as written by "netscape" output format
+func dropFolderTags(tags, path []string) []string {
+	res := tags[:0]
+
+next:
+	for _, tag := range tags {
+		for _, name := range path {
+			if tag == name || tag == strings.Join(strings.Fields(name), "_") {
+				continue next
+			}
+		}
+
+		res = append(res, tag)
+	}
+
+	return res
+}
+
+// Unix time in seconds, or zero time
+func unixTime(s string) time.Time {
+	if ts, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil && ts > 0 {
+		return time.Unix(ts, 0)
+	}
+
+	return time.Time{}
+}
+
+// reads CSV file with a header line, calling the function for every record with non-empty
+// required column; the function gets the fields by their (case insensitive) column names
+func readCSVTable(src io.Reader, required string, fn func(field func(string) string)) error {
+	r := csv.NewReader(src)
+
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+
+	if err != nil {
+		return err
+	}
+
+	cols := make(map[string]int, len(header))
+
+	for i, s := range header {
+		cols[strings.ToLower(strings.TrimSpace(s))] = i
+	}
+
+	if _, ok := cols[required]; !ok {
+		return fmt.Errorf("Missing %q column", required)
+	}
+
+	for {
+		rec, err := r.Read()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return rec[i]
+			}
+
+			return ""
+		}
+
+		if len(field(required)) > 0 {
+			fn(field)
+		}
+	}
+}
+
+// links of Netscape bookmark file, with <H3> folders, and ADD_DATE, TAGS (comma separated)
+// and <DD> description of the links
+func parseNetscapeHTML(src io.Reader) (items []taggedLink) {
+	z := html.NewTokenizer(src)
+
+	// names of the enclosing folders, with empty names for the lists outside any folder
+	var path []string
+	var folder string // the folder of the next list
+
+	// text of the current element
+	var buff bytes.Buffer
+	var text *string
+
+	for {
+		tt := z.Next()
+
+		if tt == html.TextToken {
+			buff.Write(z.Text())
+			continue
+		}
+
+		if text != nil {
+			*text, text = foldSpaces(buff.String()), nil
+		}
+
+		buff.Reset()
+
+		switch tt {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+
+			switch string(name) {
+			case "h3":
+				text = &folder
+			case "dl":
+				path, folder = append(path, folder), ""
+			case "dd":
+				if n := len(items); n > 0 && len(items[n-1].desc) == 0 {
+					text = &items[n-1].desc
+				}
+			case "a":
+				item := taggedLink{}
+
+				for hasAttr {
+					var k, v []byte
+
+					k, v, hasAttr = z.TagAttr()
+
+					switch string(k) {
+					case "href":
+						item.url = string(v)
+					case "add_date":
+						item.added = unixTime(string(v))
+					case "tags":
+						item.tags = splitTags(string(v), ",")
+					}
+				}
+
+				for _, s := range path {
+					if len(s) > 0 {
+						item.path = append(item.path, s)
+					}
+				}
+
+				item.tags = dropFolderTags(item.tags, item.path)
+
+				if len(item.url) > 0 {
+					items = append(items, item)
+					text = &items[len(items)-1].title
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "dl" && len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Page " + r.URL.Path[1:] + "</title></head></html>"))
+	}))
+
+	defer srv.Close()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	list := filepath.Join(dir, "urls.txt")
+
+	if err := ioutil.WriteFile(name, []byte(testBookmarks("v1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := "# links\n" + srv.URL + "/a\n\nhttps://example.com/\n" + srv.URL + "/b\n"
+
+	if err := ioutil.WriteFile(list, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "-i", name, "--page-cache", filepath.Join(dir, "pages.json")}
+
+	if err := importCmd(append(args, list)); err == nil {
+		t.Fatal("Changed without confirmation")
+	}
+
+	// no "other" root in the file
+	if err := importCmd(append(args, "--yes", list)); err == nil {
+		t.Fatal("Missing error")
+	}
+
+	if err := importCmd(append(args, "--yes", "--folder", "Bar/Imported", "--fetch-titles", list)); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := loadTree(name, readOptions{strict: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"Bar/v1 <https://example.com/>",
+		"Bar/Imported/Page a <" + srv.URL + "/a>",
+		"Bar/Imported/Page b <" + srv.URL + "/b>",
+	}
+
+	if lines := linkLines(root); !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	// nothing new
+	if err := importCmd(append(args, "--yes", "--folder", "Bar/Imported", list)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseURLs(strings.NewReader("example.com\n")); err == nil {
+		t.Error("Missing error")
+	}
+}
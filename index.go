@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A-Z index of all links by name, with a navigation bar of letter anchors
+func azIndex(folders []*Folder, opts *options) fhtml {
+	if !opts.azIndex {
+		return htmlNil
+	}
+
+	var links []*Link
+
+	for _, f := range folders {
+		links = append(links, f.allLinks()...)
+	}
+
+	sort.SliceStable(links, func(i, j int) bool { return lessName(&links[i].Node, &links[j].Node) })
+
+	// group by the first letter
+	var letters []string
+
+	groups := make(map[string][]fhtml)
+
+	for _, link := range links {
+		letter := indexLetter(link.Name)
+
+		if _, ok := groups[letter]; !ok {
+			letters = append(letters, letter)
+		}
+
+		groups[letter] = append(groups[letter], linkItem(link, opts))
+	}
+
+	sort.Strings(letters)
+
+	nav := make([]fhtml, len(letters))
+	lists := make([]fhtml, len(letters))
+
+	for i, letter := range letters {
+		id := "az-" + letter
+
+		if letter == "#" {
+			id = "az-0" // "#" is not allowed in URL fragment
+		}
+
+		nav[i] = htmlListArgs(htmlLink("#"+id, letter), htmlRawText(" "))
+		lists[i] = htmlListArgs(htmlTagID("h3", id, htmlText(letter)), htmlTag("ul", htmlList(groups[letter])))
+	}
+
+	title := opts.phrases().index
+
+	return htmlListArgs(
+		htmlRawText(`<section aria-labelledby="az">`),
+		htmlTagID("h2", "az", htmlText(title)),
+		htmlRawText(`<nav aria-label="`+html.EscapeString(title)+`">`),
+		htmlTag("p", htmlList(nav)),
+		htmlRawText("</nav>"),
+		htmlList(lists),
+		htmlRawText("</section>"),
+	)
+}
+
+// upper case first letter of the name, or "#" if it does not start with a letter
+func indexLetter(name string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+
+	if !unicode.IsLetter(r) {
+		return "#"
+	}
+
+	return string(unicode.ToUpper(r))
+}
+
+// weighted list of host names, linking to the lists of links per host
+func domainCloud(folders []*Folder, opts *options) fhtml {
+	if !opts.domains {
+		return htmlNil
+	}
+
+	byHost := make(map[string][]*Link)
+
+	for _, f := range folders {
+		for _, link := range f.allLinks() {
+			if host := linkHost(link.URL); len(host) > 0 {
+				byHost[host] = append(byHost[host], link)
+			}
+		}
+	}
+
+	hosts := make([]string, 0, len(byHost))
+	max := 1
+
+	for host, links := range byHost {
+		hosts = append(hosts, host)
+
+		if len(links) > max {
+			max = len(links)
+		}
+	}
+
+	sort.Strings(hosts)
+
+	cloud := make([]fhtml, len(hosts))
+	lists := make([]fhtml, len(hosts))
+
+	for i, host := range hosts {
+		n := len(byHost[host])
+
+		// font size from 100% to 250%, on logarithmic scale
+		size := 100
+
+		if max > 1 {
+			size += int(150 * math.Log(float64(n)) / math.Log(float64(max)))
+		}
+
+		cloud[i] = htmlRawText(fmt.Sprintf(`<a href="#domain-%s" style="font-size: %d%%" title="%d">%s</a> `,
+			html.EscapeString(host), size, n, html.EscapeString(host)))
+
+		items := make([]fhtml, n)
+
+		for j, link := range byHost[host] {
+			items[j] = linkItem(link, opts)
+		}
+
+		lists[i] = htmlListArgs(htmlTagID("h3", "domain-"+host, htmlText(host)), htmlTag("ul", htmlList(items)))
+	}
+
+	title := opts.phrases().domains
+
+	return htmlListArgs(
+		htmlRawText(`<section aria-labelledby="domains">`),
+		htmlTagID("h2", "domains", htmlText(title)),
+		htmlRawText(`<nav aria-label="`+html.EscapeString(title)+`">`),
+		htmlTag("p", htmlList(cloud)),
+		htmlRawText("</nav>"),
+		htmlList(lists),
+		htmlRawText("</section>"),
+	)
+}
+
+// host name of a web link without "www.", or empty string
+func linkHost(s string) string {
+	if !isWebURL(s) {
+		return ""
+	}
+
+	u, err := url.Parse(s)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// document of the search index, in the form lunr.js and similar libraries can build their index from
+type searchDocument struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Folder      string `json:"folder"`
+	Description string `json:"description,omitempty"`
+}
+
+// writes the documents of all links in the folders, for a search index
+func writeSearchIndex(name string, folders []*Folder) error {
+	docs := []*searchDocument{}
+	root := &Folder{Folders: folders}
+
+	root.walkLinks(nil, func(path []string, link *Link) error {
+		docs = append(docs, &searchDocument{
+			ID:          strconv.Itoa(len(docs)),
+			Title:       link.Name,
+			URL:         link.URL,
+			Folder:      strings.Join(path, "/"),
+			Description: link.description(),
+		})
+
+		return nil
+	})
+
+	return writeJSONFile(name, docs)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerPushTarget("instapaper", "Add bookmarks from the chosen folders to Instapaper", pushInstapaper)
+}
+
+// Instapaper simple API endpoint, variable for testing
+var instapaperAPI = "https://www.instapaper.com/api/add"
+
+// "push instapaper" command
+func pushInstapaper(args []string) error {
+	fs := newFlagSet("push instapaper", "")
+	opts := newPushOptions(fs)
+
+	var username, password string
+
+	fs.StringVar(&username, "username", "", "Instapaper user name or email (default $INSTAPAPER_USERNAME)")
+	fs.StringVar(&password, "password", "", "Instapaper password (default $INSTAPAPER_PASSWORD)")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(opts.folders) == 0 {
+		return errors.New("Please choose at least one --folder to add to Instapaper")
+	}
+
+	if err := credential(&username, "username", "INSTAPAPER_USERNAME"); err != nil {
+		return err
+	}
+
+	if len(password) == 0 {
+		password = os.Getenv("INSTAPAPER_PASSWORD") // accounts without password are allowed
+	}
+
+	links, _, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	client := opts.newWebClient()
+
+	var failed int
+	var mu sync.Mutex
+
+	err = forEachLink(links, opts.concurrency, func(link *Link) error {
+		err := instapaperAdd(client, username, password, link)
+
+		switch e := err.(type) {
+		case nil:
+			logInfo("%s: added to Instapaper", link.URL)
+		case *HTTPError:
+			if e.Status == http.StatusForbidden {
+				return errors.New("Instapaper: invalid user name or password")
+			}
+
+			logWarn("%s: %s", link.URL, err)
+
+			mu.Lock()
+			failed++
+			mu.Unlock()
+		default:
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed to add %d out of %d links to Instapaper", failed, len(links))
+	}
+
+	return nil
+}
+
+// adds one link to Instapaper
+func instapaperAdd(client *webClient, username, password string, link *Link) error {
+	params := url.Values{
+		"url":   {link.URL},
+		"title": {link.Name},
+	}
+
+	req, err := http.NewRequest("POST", instapaperAPI, strings.NewReader(params.Encode()))
+
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstapaperAdd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.FormValue("url") != "https://example.com/" || r.FormValue("title") != "Example" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer srv.Close()
+	defer func(api string) { instapaperAPI = api }(instapaperAPI)
+
+	instapaperAPI = srv.URL
+
+	link := &Link{Node: Node{Name: "Example"}, URL: "https://example.com/"}
+
+	if err := instapaperAdd(newWebClient(), "me", "secret", link); err != nil {
+		t.Fatal(err)
+	}
+
+	err := instapaperAdd(newWebClient(), "me", "wrong", link)
+
+	if e, ok := err.(*HTTPError); !ok || e.Status != http.StatusForbidden {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// fixed text of HTML output
+type phrases struct {
+	title, contents, index, domains string
+	modified, saved                 string
+	omitted                         string // format of the number of links and folders cut off by --max-depth
+	dateLayout                      string
+}
+
+var languages = map[string]*phrases{
+	"en": {"Bookmarks", "Contents", "Index", "Domains", "modified ", "saved copy", "%d more links in %d sub-folders", "2006-01-02"},
+	"de": {"Lesezeichen", "Inhalt", "Register", "Domains", "geändert ", "gespeicherte Kopie", "%d weitere Links in %d Unterordnern", "02.01.2006"},
+	"es": {"Marcadores", "Contenido", "Índice", "Dominios", "modificado ", "copia guardada", "%d enlaces más en %d subcarpetas", "02/01/2006"},
+	"fr": {"Signets", "Sommaire", "Index", "Domaines", "modifié ", "copie enregistrée", "%d autres liens dans %d sous-dossiers", "02/01/2006"},
+	"it": {"Segnalibri", "Sommario", "Indice", "Domini", "modificato ", "copia salvata", "altri %d link in %d sottocartelle", "02/01/2006"},
+	"pl": {"Zakładki", "Spis treści", "Indeks", "Domeny", "zmieniono ", "zapisana kopia", "jeszcze %d linków w %d podfolderach", "02.01.2006"},
+	"ru": {"Закладки", "Содержание", "Указатель", "Домены", "изменено ", "сохранённая копия", "ещё %d ссылок в %d подпапках", "02.01.2006"},
+}
+
+func languageNames() string {
+	names := make([]string, 0, len(languages))
+
+	for name := range languages {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// phrases in the chosen language, English by default
+func (opts *options) phrases() *phrases {
+	if p, ok := languages[opts.lang]; ok {
+		return p
+	}
+
+	return languages["en"]
+}
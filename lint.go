@@ -0,0 +1,477 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand("lint", "Report duplicates, empty folders, untitled links and other bookmark hygiene issues", lintCmd)
+}
+
+// "lint" command
+func lintCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("lint", "")
+
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+
+	var asJSON bool
+	var stale string
+
+	limits := lintLimits{now: time.Now()}
+
+	fs.IntVar(&limits.trashDays, "trash-days", 30, "Report links in Trash added more than this many days ago")
+	fs.IntVar(&limits.maxURL, "max-url-length", 2000, "Report URLs longer than this")
+	fs.StringVar(&stale, "stale", "", "Report links added longer ago than this, like \"3y\", \"6mo\" or \"90d\", "+
+		"and not visited since, if --history is given")
+	fs.StringVar(&opts.history, "history", "", "Browser History file to take the last visit times from")
+	fs.BoolVar(&limits.nearDuplicates, "near-duplicates", false,
+		"Also report links with similar URLs or names on the same site, with a confidence score")
+	fs.BoolVar(&asJSON, "json", false, "Print issues as JSON Lines, one object per issue")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if limits.trashDays < 0 || limits.maxURL < 1 {
+		return errors.New("Invalid --trash-days or --max-url-length")
+	}
+
+	if len(stale) > 0 {
+		var err error
+
+		if limits.staleBefore, err = parseAge(stale, limits.now); err != nil {
+			return err
+		}
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if len(opts.history) > 0 {
+		if err = addHistory(opts.history, roots); err != nil {
+			return err
+		}
+
+		limits.history = true
+	}
+
+	var issues []*lintIssue
+
+	for _, root := range roots {
+		issues = append(issues, lintTree(root, limits)...)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+
+		enc.SetEscapeHTML(false)
+
+		for _, issue := range issues {
+			if err = enc.Encode(issue); err != nil {
+				return err
+			}
+		}
+	} else {
+		printLintReport(issues)
+	}
+
+	logInfo("found %d issues", len(issues))
+	return nil
+}
+
+// kinds of issues, in the report order
+const (
+	lintDuplicate   = "duplicate"
+	lintNearDup     = "near-duplicate"
+	lintEmptyFolder = "empty-folder"
+	lintNoTitle     = "no-title"
+	lintBadURL      = "invalid-url"
+	lintScheme      = "unusual-scheme"
+	lintLongURL     = "long-url"
+	lintOldTrash    = "old-trash"
+	lintStale       = "stale"
+)
+
+var lintKinds = []string{lintDuplicate, lintNearDup, lintEmptyFolder, lintNoTitle, lintBadURL, lintScheme, lintLongURL, lintOldTrash, lintStale}
+
+// hygiene issue of a link or a folder
+type lintIssue struct {
+	Kind    string   `json:"kind"`
+	Path    []string `json:"path"` // folder path, including the folder itself for folder issues
+	Name    string   `json:"name,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Score   float64  `json:"score,omitempty"` // near-duplicate confidence
+}
+
+func (issue *lintIssue) String() string {
+	s := strings.Join(issue.Path, "/")
+
+	if len(issue.URL) > 0 {
+		s += "/" + issue.Name + " <" + issue.URL + ">"
+	}
+
+	if len(issue.Message) > 0 {
+		s += ": " + issue.Message
+	}
+
+	return s
+}
+
+type lintLimits struct {
+	trashDays, maxURL int
+	now               time.Time
+	staleBefore       time.Time // links added and not visited since are stale, unless zero
+	history           bool      // visit times are known
+	nearDuplicates    bool
+}
+
+// URL schemes expected in bookmarks
+var usualSchemes = map[string]bool{
+	"http": true, "https": true, "ftp": true, "file": true, "mailto": true,
+	"javascript": true, "opera": true, "chrome": true, "about": true,
+}
+
+type linter struct {
+	limits lintLimits
+	issues []*lintIssue
+	seen   map[string][]string // URL -> path of the first link with it
+	unique []lintLink          // links with unique URLs, for finding near-duplicates
+}
+
+type lintLink struct {
+	*Link
+	path []string
+}
+
+// checks the tree read from a Bookmarks file
+func lintTree(root *Folder, limits lintLimits) []*lintIssue {
+	l := &linter{limits: limits, seen: make(map[string][]string)}
+
+	for _, f := range root.Folders {
+		l.folder(f, []string{f.Name}, false)
+	}
+
+	if limits.nearDuplicates {
+		l.nearDuplicates()
+	}
+
+	// group by kind, keeping the order of appearance
+	var res []*lintIssue
+
+	for _, kind := range lintKinds {
+		for _, issue := range l.issues {
+			if issue.Kind == kind {
+				res = append(res, issue)
+			}
+		}
+	}
+
+	return res
+}
+
+func (l *linter) add(kind string, path []string, link *Link, msg string) {
+	issue := &lintIssue{Kind: kind, Path: path, Message: msg}
+
+	if link != nil {
+		issue.Name, issue.URL = link.Name, link.URL
+	}
+
+	l.issues = append(l.issues, issue)
+}
+
+func (l *linter) folder(folder *Folder, path []string, trash bool) {
+	// roots may well be empty
+	if len(path) > 1 && !trash && len(folder.Links) == 0 && len(folder.Folders) == 0 {
+		l.add(lintEmptyFolder, path, nil, "")
+	}
+
+	for _, link := range folder.Links {
+		if trash {
+			if days := int(l.limits.now.Sub(link.Added).Hours() / 24); days > l.limits.trashDays {
+				l.add(lintOldTrash, path, link, "added "+strconv.Itoa(days)+" days ago")
+			}
+		} else {
+			l.link(link, path)
+		}
+	}
+
+	for _, f := range folder.Folders {
+		// Opera keeps deleted bookmarks in "custom_root/trash"
+		l.folder(f, append(path[:len(path):len(path)], f.Name), trash || folder.container && f.Key == "trash")
+	}
+}
+
+func (l *linter) link(link *Link, path []string) {
+	if len(strings.TrimSpace(link.Name)) == 0 {
+		l.add(lintNoTitle, path, link, "")
+	}
+
+	if first, ok := l.seen[link.URL]; ok {
+		l.add(lintDuplicate, path, link, "also in "+strings.Join(first, "/"))
+	} else {
+		l.seen[link.URL] = path
+		l.unique = append(l.unique, lintLink{link, path})
+	}
+
+	if u, err := url.Parse(link.URL); err != nil || len(u.Scheme) == 0 {
+		l.add(lintBadURL, path, link, "")
+	} else if !usualSchemes[strings.ToLower(u.Scheme)] {
+		l.add(lintScheme, path, link, u.Scheme)
+	}
+
+	if n := len(link.URL); n > l.limits.maxURL {
+		l.add(lintLongURL, path, link, strconv.Itoa(n)+" characters")
+	}
+
+	if before := l.limits.staleBefore; !before.IsZero() && link.Added.After(googleEpoch) && link.Added.Before(before) {
+		msg := "added " + link.Added.Local().Format("2006-01-02")
+
+		switch {
+		case !l.limits.history:
+			l.add(lintStale, path, link, msg)
+		case link.Visits == 0:
+			l.add(lintStale, path, link, msg+", never visited")
+		case link.LastVisit.Before(before):
+			l.add(lintStale, path, link, msg+", last visited "+link.LastVisit.Local().Format("2006-01-02"))
+		}
+	}
+}
+
+// time the given age like "3y", "1y6mo", "2w" or "90d" before now; Go durations like "36h" are also accepted
+func parseAge(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+
+	invalid := fmt.Errorf("Invalid age %q, expected a value like \"3y\", \"6mo\", \"2w\" or \"90d\"", s)
+	ts := now
+
+	for rest := s; len(rest) > 0; {
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+
+		if i <= 0 {
+			return time.Time{}, invalid
+		}
+
+		n, _ := strconv.Atoi(rest[:i])
+		rest = rest[i:]
+
+		unit := strings.TrimLeftFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		unit = rest[:len(rest)-len(unit)]
+		rest = rest[len(unit):]
+
+		switch unit {
+		case "y":
+			ts = ts.AddDate(-n, 0, 0)
+		case "mo":
+			ts = ts.AddDate(0, -n, 0)
+		case "w":
+			ts = ts.AddDate(0, 0, -7*n)
+		case "d":
+			ts = ts.AddDate(0, 0, -n)
+		default:
+			return time.Time{}, invalid
+		}
+	}
+
+	if !ts.Before(now) {
+		return time.Time{}, invalid
+	}
+
+	return ts, nil
+}
+
+// prints issues grouped by kind
+func printLintReport(issues []*lintIssue) {
+	for i, issue := range issues {
+		if i == 0 || issues[i-1].Kind != issue.Kind {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			fmt.Println(issue.Kind + ":")
+		}
+
+		fmt.Println("    " + issue.String())
+	}
+}
+
+// reports links similar to some link before them, on the same site: with URLs differing
+// only in the scheme, "www.", trailing slash, fragment or query, or with very similar names
+func (l *linter) nearDuplicates() {
+	var all []*nearItem
+
+	hosts := make(map[string][]*nearItem)
+
+	for _, link := range l.unique {
+		u, err := url.Parse(link.URL)
+
+		if err != nil || len(u.Host) == 0 {
+			continue
+		}
+
+		host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+		page := host + strings.TrimSuffix(u.EscapedPath(), "/")
+
+		it := &nearItem{
+			lintLink: link,
+			host:     host,
+			index:    len(hosts[host]),
+			page:     page,
+			query:    page + "?" + u.RawQuery,
+			name:     strings.ToLower(foldSpaces(link.Name)),
+		}
+
+		it.full = it.query + "#" + u.Fragment
+		hosts[host] = append(hosts[host], it)
+		all = append(all, it)
+	}
+
+	for _, it := range all {
+		// the best match among the preceding links
+		var best *nearItem
+		var score float64
+
+		for _, prev := range hosts[it.host][:it.index] {
+			if s := nearScore(prev, it); s > score {
+				best, score = prev, s
+			}
+		}
+
+		if best != nil {
+			l.issues = append(l.issues, &lintIssue{
+				Kind:    lintNearDup,
+				Path:    it.path,
+				Name:    it.Name,
+				URL:     it.URL,
+				Message: fmt.Sprintf("similar to %s/%s <%s> (confidence %.2f)", strings.Join(best.path, "/"), best.Name, best.URL, score),
+				Score:   score,
+			})
+		}
+	}
+}
+
+// link with its normalized URL: lower case host without "www.", and path without trailing slash,
+// followed by the query and the fragment
+type nearItem struct {
+	lintLink
+	host              string
+	index             int // among the links to the same host
+	page, query, full string
+	name              string
+}
+
+// confidence of two links on the same site being duplicates, 0 if they are not
+func nearScore(a, b *nearItem) float64 {
+	switch {
+	case a.full == b.full:
+		return 0.95 // scheme, "www." or trailing slash
+	case a.query == b.query:
+		return 0.9 // fragment
+	case a.page == b.page:
+		return 0.6 // query, may well be different pages
+	}
+
+	// similar names, like "Go blog" and "The Go Blog"
+	if len(a.name) < 5 || len(b.name) < 5 {
+		return 0
+	}
+
+	if s := similarity(a.name, b.name); s >= 0.9 {
+		return 0.7 * s
+	}
+
+	return 0
+}
+
+// 1 minus the edit distance between the strings relative to the length of the longer one
+func similarity(a, b string) float64 {
+	x, y := []rune(a), []rune(b)
+
+	if len(x) < len(y) {
+		x, y = y, x
+	}
+
+	if len(x) == 0 {
+		return 1
+	}
+
+	// Levenshtein distance, with a single row
+	row := make([]int, len(y)+1)
+
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		prev := row[0]
+		row[0] = i
+
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+
+			cur := row[j]
+			row[j] = minInt(minInt(row[j]+1, row[j-1]+1), prev+cost)
+			prev = cur
+		}
+	}
+
+	return 1 - float64(row[len(y)])/float64(len(x))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "date_added": "0", "children": [
+			{"type": "url", "name": "A", "url": "https://a/", "date_added": "0"},
+			{"type": "url", "name": " ", "url": "data:text/html,hi", "date_added": "0"},
+			{"type": "folder", "name": "Empty", "date_added": "0", "children": []},
+			{"type": "folder", "name": "Sub", "date_added": "0", "children": [
+				{"type": "url", "name": "A2", "url": "https://a/", "date_added": "0"},
+				{"type": "url", "name": "Long", "url": "https://b/0123456789", "date_added": "0"}
+			]}
+		]},
+		"other": {"type": "folder", "name": "Other", "date_added": "0", "children": []},
+		"custom_root": {"trash": {"type": "folder", "name": "Trash", "date_added": "0", "children": [
+			{"type": "url", "name": "Old", "url": "https://a/", "date_added": "13100000000000000"},
+			{"type": "url", "name": "New", "url": "https://n/", "date_added": "13340000000000000"}
+		]}}
+	}}`
+
+	root, _, err := newTreeDecoder(strings.NewReader(src), readOptions{}).decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	issues := lintTree(root, lintLimits{trashDays: 30, maxURL: 19, now: now})
+
+	var res []string
+
+	for _, issue := range issues {
+		res = append(res, issue.Kind+" "+issue.String())
+	}
+
+	exp := []string{
+		"duplicate Bar/Sub/A2 <https://a/>: also in Bar",
+		"empty-folder Bar/Empty",
+		"no-title Bar/  <data:text/html,hi>",
+		"unusual-scheme Bar/  <data:text/html,hi>: data",
+		"long-url Bar/Sub/Long <https://b/0123456789>: 20 characters",
+		"old-trash custom_root/Trash/Old <https://a/>: added 2784 days ago",
+	}
+
+	if strings.Join(res, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("Unexpected issues:\n%s", strings.Join(res, "\n"))
+	}
+}
+
+func TestLintStale(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		ts, _ := time.Parse("2006-01-02", s)
+		return ts
+	}
+
+	bar := &Folder{Node: Node{Name: "Bar"}, Links: []*Link{
+		{Node: Node{Name: "Old", Added: day("2020-01-01")}, URL: "https://old/"},
+		{Node: Node{Name: "Visited", Added: day("2020-01-01")}, URL: "https://visited/", Visits: 5, LastVisit: day("2024-05-01")},
+		{Node: Node{Name: "Forgotten", Added: day("2020-01-01")}, URL: "https://forgotten/", Visits: 1, LastVisit: day("2020-02-01")},
+		{Node: Node{Name: "New", Added: day("2023-01-01")}, URL: "https://new/"},
+		{Node: Node{Name: "Unknown"}, URL: "https://unknown/"},
+	}}
+
+	root := &Folder{Folders: []*Folder{bar}}
+
+	before, err := parseAge("3y", now)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, history := range []bool{false, true} {
+		var res []string
+
+		for _, issue := range lintTree(root, lintLimits{maxURL: 100, now: now, staleBefore: before, history: history}) {
+			res = append(res, issue.Name+": "+issue.Message)
+		}
+
+		exp := []string{"Old: added 2020-01-01", "Visited: added 2020-01-01", "Forgotten: added 2020-01-01"}
+
+		if history {
+			exp = []string{"Old: added 2020-01-01, never visited", "Forgotten: added 2020-01-01, last visited 2020-02-01"}
+		}
+
+		if strings.Join(res, "\n") != strings.Join(exp, "\n") {
+			t.Errorf("history %v: unexpected issues:\n%s", history, strings.Join(res, "\n"))
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]string{
+		"3y":    "2021-06-01 12:00",
+		"1y6mo": "2022-12-01 12:00",
+		"2w":    "2024-05-18 12:00",
+		"90d":   "2024-03-03 12:00",
+		"36h":   "2024-05-31 00:00",
+	}
+
+	for s, exp := range tests {
+		if ts, err := parseAge(s, now); err != nil || ts.Format("2006-01-02 15:04") != exp {
+			t.Errorf("%s: got %s, %v instead of %s", s, ts, err, exp)
+		}
+	}
+
+	for _, s := range []string{"", "y", "3", "3x", "-3y", "0d"} {
+		if _, err := parseAge(s, now); err == nil {
+			t.Errorf("Invalid age %q accepted", s)
+		}
+	}
+}
+
+func TestLintNearDuplicates(t *testing.T) {
+	bar := &Folder{Node: Node{Name: "Bar"}, Links: []*Link{
+		{Node: Node{Name: "Go"}, URL: "https://go.dev/doc/"},
+		{Node: Node{Name: "Go docs"}, URL: "http://www.go.dev/doc"},
+		{Node: Node{Name: "Go FAQ"}, URL: "https://go.dev/doc#faq"},
+		{Node: Node{Name: "Search"}, URL: "https://go.dev/doc?q=x"},
+		{Node: Node{Name: "The Go Blog"}, URL: "https://go.dev/blog"},
+		{Node: Node{Name: "The Go Blogs"}, URL: "https://go.dev/blog/all"},
+		{Node: Node{Name: "The Go Blog"}, URL: "https://example.com/blog"},
+		{Node: Node{Name: "Go"}, URL: "https://go.dev/doc/"},
+	}}
+
+	root := &Folder{Folders: []*Folder{bar}}
+
+	var res []string
+
+	for _, issue := range lintTree(root, lintLimits{maxURL: 100, nearDuplicates: true}) {
+		if issue.Kind == lintNearDup {
+			res = append(res, issue.Name+": "+issue.Message)
+		}
+	}
+
+	exp := []string{
+		"Go docs: similar to Bar/Go <https://go.dev/doc/> (confidence 0.95)",
+		"Go FAQ: similar to Bar/Go <https://go.dev/doc/> (confidence 0.90)",
+		"Search: similar to Bar/Go <https://go.dev/doc/> (confidence 0.60)",
+		"The Go Blogs: similar to Bar/The Go Blog <https://go.dev/blog> (confidence 0.64)",
+	}
+
+	if strings.Join(res, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(res, "\n"))
+	}
+
+	if s := similarity("kitten", "sitting"); s < 0.57 || s > 0.572 {
+		t.Errorf("Unexpected similarity: %f", s)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/juju/gnuflag"
+)
+
+// options for sending the output by email
+type emailOptions struct {
+	to               stringList
+	from, subject    string
+	server           string
+	attach           bool
+	username, secret string
+}
+
+func (opts *options) emailFlags(fs *gnuflag.FlagSet) {
+	fs.Var(&opts.email.to, "email-to", "Send the output to this email address (may be repeated)")
+	fs.StringVar(&opts.email.from, "email-from", "", "Sender email address (default is the first --email-to address)")
+	fs.StringVar(&opts.email.subject, "email-subject", "", "Email subject (default is the page title and the date)")
+	fs.StringVar(&opts.email.server, "smtp", "localhost:25",
+		"SMTP server host:port; credentials are taken from $SMTP_USERNAME and $SMTP_PASSWORD")
+	fs.BoolVar(&opts.email.attach, "email-attach", false,
+		"Send the output as an attachment, instead of the email body (implied by formats other than HTML)")
+}
+
+// sends the output by email
+func (opts *options) sendEmail(data []byte) error {
+	email := &opts.email
+
+	if len(email.from) == 0 {
+		email.from = email.to[0]
+	}
+
+	if len(email.subject) == 0 {
+		email.subject = opts.phrases().title + " " + time.Now().Format(opts.phrases().dateLayout)
+	}
+
+	email.username, email.secret = os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")
+
+	host, _, err := net.SplitHostPort(email.server)
+
+	if err != nil {
+		return fmt.Errorf("Invalid SMTP server address %q: %s", email.server, err)
+	}
+
+	var auth smtp.Auth
+
+	if len(email.username) > 0 {
+		auth = smtp.PlainAuth("", email.username, email.secret, host)
+	}
+
+	msg, err := opts.emailMessage(data, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	if err = smtp.SendMail(email.server, auth, email.from, email.to, msg); err != nil {
+		return err
+	}
+
+	logInfo("sent email to %s", strings.Join(email.to, ", "))
+	return nil
+}
+
+// composes MIME message with the output either as HTML body, or as an attachment
+func (opts *options) emailMessage(data []byte, now time.Time) ([]byte, error) {
+	email := &opts.email
+
+	for _, addr := range append([]string{email.from}, email.to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, errors.New("Invalid email address " + addr)
+		}
+	}
+
+	var msg bytes.Buffer
+
+	msg.WriteString("From: " + email.from + "\r\n")
+	msg.WriteString("To: " + strings.Join(email.to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", email.subject) + "\r\n")
+	msg.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	if !email.attach && opts.format == "html" && !opts.compress && !opts.encrypt {
+		msg.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+		msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&msg, data)
+		return msg.Bytes(), nil
+	}
+
+	// attachment
+	name := programName + "." + opts.format
+
+	if opts.compress {
+		name += ".gz"
+	}
+
+	if opts.outputName != stdout {
+		name = filepath.Base(opts.outputName)
+	}
+
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+
+	if len(ctype) == 0 || opts.encrypt {
+		ctype = "application/octet-stream"
+	}
+
+	const boundary = "=_" + programName + "_boundary"
+
+	msg.WriteString("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\r\n\r\n")
+	msg.WriteString("--" + boundary + "\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(email.subject + "\r\n")
+	msg.WriteString("--" + boundary + "\r\n")
+	msg.WriteString("Content-Type: " + ctype + "\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+	msg.WriteString("Content-Disposition: attachment; filename=\"" + mime.QEncoding.Encode("utf-8", name) + "\"\r\n\r\n")
+	writeBase64Lines(&msg, data)
+	msg.WriteString("--" + boundary + "--\r\n")
+
+	return msg.Bytes(), nil
+}
+
+// base64 encoding with lines of 76 characters, as required by MIME
+func writeBase64Lines(dest *bytes.Buffer, data []byte) {
+	s := base64.StdEncoding.EncodeToString(data)
+
+	for len(s) > 76 {
+		dest.WriteString(s[:76] + "\r\n")
+		s = s[76:]
+	}
+
+	dest.WriteString(s + "\r\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailMessage(t *testing.T) {
+	opts := newOptions()
+	opts.format = "html"
+	opts.email.to = stringList{"me@example.com"}
+	opts.email.from = "bm@example.com"
+	opts.email.subject = "Закладки"
+
+	body := strings.Repeat("<p>bookmarks</p>", 10)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	// inline HTML
+	data, err := opts.emailMessage([]byte(body), now)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subj, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subj != "Закладки" {
+		t.Errorf("Unexpected subject: %q, %v", subj, err)
+	}
+
+	if s := msg.Header.Get("Content-Type"); s != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type: %q", s)
+	}
+
+	if s := readBase64(t, msg.Body); s != body {
+		t.Errorf("Unexpected body: %q", s)
+	}
+
+	// attachment
+	opts.format = "csv"
+
+	if data, err = opts.emailMessage([]byte("a,b\n"), now); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg, err = mail.ReadMessage(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := multipart.NewReader(msg.Body, "="+"_"+programName+"_boundary")
+
+	if _, err = r.NextPart(); err != nil {
+		t.Fatal(err)
+	}
+
+	part, err := r.NextPart()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := part.FileName(); name != programName+".csv" {
+		t.Errorf("Unexpected file name: %q", name)
+	}
+
+	if s := readBase64(t, part); s != "a,b\n" {
+		t.Errorf("Unexpected attachment: %q", s)
+	}
+
+	// header injection
+	opts.email.to = stringList{"me@example.com\r\nBcc: you@example.com"}
+
+	if _, err = opts.emailMessage(nil, now); err == nil {
+		t.Error("Invalid address accepted")
+	}
+}
+
+func readBase64(t *testing.T, r io.Reader) string {
+	data, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, r))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	registerFormat(exportFunc{"markdown", foldersToMarkdown})
+	readers["markdown"] = loadMarkdown
+}
+
+// Markdown document with a heading per folder and a list of links under it
+func foldersToMarkdown(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &textWriter{dest: dest}
+
+	w.write("# Bookmarks\n")
+	markdownFolders(w, folders, opts, 2)
+	return w.err
+}
+
+func markdownFolders(w *textWriter, folders []*Folder, opts *options, level int) {
+	// Markdown has only six heading levels
+	heading := strings.Repeat("#", level)
+
+	if level > 6 {
+		heading = "######"
+	}
+
+	for _, folder := range folders {
+		w.write("\n" + heading + " " + markdownText(folder.Name) + "\n")
+
+		if len(folder.Links) > 0 {
+			w.write("\n")
+		}
+
+		for _, link := range folder.Links {
+			name := link.Name
+
+			if len(name) == 0 {
+				name = link.URL
+			}
+
+			w.write("- [" + markdownText(name) + "](" + markdownURL(link.URL) + ")\n")
+
+			if desc := link.description(); len(desc) > 0 {
+				w.write("  " + markdownText(desc) + "\n")
+			}
+		}
+
+		if s := folder.omitted(); opts.depthSummary && len(s) > 0 {
+			w.write("\n_" + markdownText(s) + "_\n")
+		}
+
+		markdownFolders(w, folder.Folders, opts, level+1)
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_", "`", "\\`",
+	"<", "&lt;", ">", "&gt;", "#", "\\#", "\n", " ", "\r", " ",
+)
+
+func markdownText(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+var markdownURLEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20", "<", "%3C", ">", "%3E",
+	"\t", "%09", "\n", "%0A", "\r", "%0D")
+
+func markdownURL(s string) string {
+	return markdownURLEscaper.Replace(s)
+}
+
+// reads the links of Markdown document, like [title](url) or <url>, into a folder named after the file,
+// optionally with a sub-folder per heading
+func loadMarkdown(name string, ro readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	label := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+
+	if name == stdin {
+		label = "Markdown"
+	}
+
+	top, err := parseMarkdown(bytes.NewReader(src), label, ro.headings)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return &Folder{Folders: []*Folder{top}, container: true}, nil
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)(\s+#+)?$`)
+	markdownLink    = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^\s()<>]+)>?(?:\s+"[^"]*")?\s*\)|<([a-zA-Z][a-zA-Z0-9+.-]*://[^\s<>]+)>`)
+)
+
+func parseMarkdown(src io.Reader, name string, headings bool) (*Folder, error) {
+	top := &Folder{Node: Node{Name: name}}
+	scanner := bufio.NewScanner(src)
+
+	// folders of the enclosing headings, by level
+	stack := []*Folder{top}
+	levels := []int{0}
+	fenced := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if s := strings.TrimSpace(line); strings.HasPrefix(s, "```") || strings.HasPrefix(s, "~~~") {
+			fenced = !fenced
+			continue
+		}
+
+		if fenced {
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(line); m != nil && headings {
+			level := len(m[1])
+
+			for levels[len(levels)-1] >= level {
+				stack, levels = stack[:len(stack)-1], levels[:len(levels)-1]
+			}
+
+			f := &Folder{Node: Node{Name: foldSpaces(markdownLink.ReplaceAllString(m[2], "$2$4"))}}
+			parent := stack[len(stack)-1]
+
+			parent.Folders = append(parent.Folders, f)
+			stack, levels = append(stack, f), append(levels, level)
+			continue
+		}
+
+		for _, m := range markdownLink.FindAllStringSubmatch(line, -1) {
+			link := &Link{Node: Node{Name: foldSpaces(m[2])}, URL: m[3] + m[4]}
+
+			// images and relative links are skipped
+			if u, err := url.Parse(link.URL); m[1] == "!" || err != nil || len(u.Scheme) == 0 {
+				continue
+			}
+
+			folder := stack[len(stack)-1]
+			folder.Links = append(folder.Links, link)
+		}
+	}
+
+	pruneFolders(top)
+	return top, scanner.Err()
+}
+
+// removes sub-folders without links
+func pruneFolders(folder *Folder) {
+	folders := folder.Folders[:0]
+
+	for _, f := range folder.Folders {
+		if pruneFolders(f); len(f.Links) > 0 || len(f.Folders) > 0 {
+			folders = append(folders, f)
+		}
+	}
+
+	folder.Folders = folders
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	folders := []*Folder{{
+		Node: Node{Name: "Bar"},
+		Links: []*Link{{
+			Node: Node{Name: "Go [lang]", Meta: map[string]string{"Description": "The *Go* site"}},
+			URL:  "https://go.dev/(x)",
+		}},
+		Folders: []*Folder{{Node: Node{Name: "Sub"}}},
+	}}
+
+	var buf strings.Builder
+
+	if err := foldersToMarkdown(folders, newOptions(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "# Bookmarks\n\n## Bar\n\n- [Go \\[lang\\]](https://go.dev/%28x%29)\n  The \\*Go\\* site\n\n### Sub\n"
+
+	if s := buf.String(); s != exp {
+		t.Fatalf("Unexpected output:\n%s", s)
+	}
+}
+
+func TestMarkdownImport(t *testing.T) {
+	const src = `Intro with [Go](https://go.dev/) and <https://example.com/x>.
+
+# Tools ##
+
+See ![logo](https://img.com/a.png) and [notes](notes.md).
+
+## Editors
+
+- [Vim](https://www.vim.org/ "Vim editor"), [Emacs]( <https://www.gnu.org/software/emacs/> )
+
+` + "```" + `
+[Not a link](https://code.com/)
+` + "```" + `
+
+## Empty
+
+# [Reading](https://read.com/)
+
+[Book](https://book.com/)
+`
+
+	tests := []struct {
+		headings bool
+		exp      []string
+	}{
+		{false, []string{
+			"Notes/Go <https://go.dev/>",
+			"Notes/ <https://example.com/x>",
+			"Notes/Vim <https://www.vim.org/>",
+			"Notes/Emacs <https://www.gnu.org/software/emacs/>",
+			"Notes/Reading <https://read.com/>",
+			"Notes/Book <https://book.com/>",
+		}},
+		{true, []string{
+			"Notes/Go <https://go.dev/>",
+			"Notes/ <https://example.com/x>",
+			"Notes/Tools/Editors/Vim <https://www.vim.org/>",
+			"Notes/Tools/Editors/Emacs <https://www.gnu.org/software/emacs/>",
+			"Notes/Reading/Book <https://book.com/>",
+		}},
+	}
+
+	for _, test := range tests {
+		top, err := parseMarkdown(strings.NewReader(src), "Notes", test.headings)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lines := linkLines(&Folder{Folders: []*Folder{top}}); !reflect.DeepEqual(lines, test.exp) {
+			t.Errorf("headings=%v: unexpected links: %q", test.headings, lines)
+		}
+	}
+}
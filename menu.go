@@ -0,0 +1,155 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	registerCommand("menu", "List bookmarks for rofi or dmenu, or print or open the bookmark selected", menuCmd)
+}
+
+// separator of the name and the URL in menu lines
+const menuSeparator = " — "
+
+// "menu" command
+func menuCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("menu", "")
+
+	var selectLine, open bool
+
+	opts.inputFlags(fs)
+	opts.treeFlags(fs)
+	fs.BoolVar(&selectLine, "select", false, "Read the selected line from STDIN, and print its URL")
+	fs.BoolVar(&open, "open", false, "With --select, open the URL in the default browser instead of printing it")
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if selectLine {
+		url, err := menuSelection(os.Stdin)
+
+		if err != nil || !open {
+			if err == nil {
+				_, err = fmt.Println(url)
+			}
+
+			return err
+		}
+
+		return openURL(url)
+	}
+
+	if open {
+		return errors.New("Option --open requires --select")
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	for _, root := range roots {
+		for _, link := range root.allLinks() {
+			w.WriteString(menuLine(link))
+		}
+	}
+
+	return w.Flush()
+}
+
+// "name — url" line, with the URL in place of empty name
+func menuLine(link *Link) string {
+	name := foldSpaces(link.Name)
+
+	if len(name) == 0 {
+		name = link.URL
+	}
+
+	return name + menuSeparator + strings.Join(strings.Fields(link.URL), "%20") + "\n"
+}
+
+// URL from the line selected
+func menuSelection(src io.Reader) (string, error) {
+	line, err := bufio.NewReader(src).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	line = strings.TrimSpace(line)
+
+	if len(line) == 0 {
+		return "", errors.New("Nothing selected")
+	}
+
+	if i := strings.LastIndex(line, menuSeparator); i >= 0 {
+		line = line[i+len(menuSeparator):]
+	}
+
+	return line, nil
+}
+
+// commands opening a URL in the default browser, tried in order
+var openCommands = [][]string{
+	{"xdg-open"},
+	{"open"},
+	{"rundll32", "url.dll,FileProtocolHandler"},
+}
+
+func openURL(url string) error {
+	for _, cmd := range openCommands {
+		if path, err := exec.LookPath(cmd[0]); err == nil {
+			return exec.Command(path, append(cmd[1:len(cmd):len(cmd)], url)...).Run()
+		}
+	}
+
+	return errors.New("No command found to open " + url)
+}
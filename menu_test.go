@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMenu(t *testing.T) {
+	links := []*Link{
+		{Node: Node{Name: "Go — the  language"}, URL: "https://go.dev/"},
+		{URL: "https://example.com/a b"},
+	}
+
+	for _, link := range links {
+		url, err := menuSelection(strings.NewReader(menuLine(link)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url != strings.Replace(link.URL, " ", "%20", -1) {
+			t.Errorf("Unexpected URL %q", url)
+		}
+	}
+
+	if s := menuLine(links[0]); s != "Go — the language — https://go.dev/\n" {
+		t.Errorf("Unexpected line %q", s)
+	}
+
+	if url, err := menuSelection(strings.NewReader("https://typed.com/")); err != nil || url != "https://typed.com/" {
+		t.Errorf("Unexpected selection %q, %v", url, err)
+	}
+
+	if _, err := menuSelection(strings.NewReader("\n")); err == nil {
+		t.Error("Missing error")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+func init() {
+	registerFormat(exportFunc{"mm", foldersToMindMap})
+}
+
+// FreeMind (and Freeplane) mind map, with a branch per folder and a leaf node per link;
+// folders below the top level are folded
+func foldersToMindMap(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &textWriter{dest: dest}
+
+	w.write(`<map version="1.0.1">` + "\n")
+	w.write(`<node TEXT="` + xmlEscape(opts.phrases().title) + `">` + "\n")
+	mindMapFolders(w, folders, 1)
+	w.write("</node>\n</map>\n")
+	return w.err
+}
+
+func mindMapFolders(w *textWriter, folders []*Folder, level int) {
+	indent := strings.Repeat("\t", level)
+
+	for _, f := range folders {
+		w.write(indent + `<node TEXT="` + xmlEscape(f.Name) + `"`)
+
+		if level > 1 && (len(f.Links) > 0 || len(f.Folders) > 0) {
+			w.write(` FOLDED="true"`)
+		}
+
+		w.write(">\n")
+		mindMapFolders(w, f.Folders, level+1)
+
+		for _, link := range f.Links {
+			name := link.Name
+
+			if len(name) == 0 {
+				name = link.URL
+			}
+
+			w.write(indent + "\t" + `<node TEXT="` + xmlEscape(name) + `" LINK="` + xmlEscape(link.URL) + `"/>` + "\n")
+		}
+
+		w.write(indent + "</node>\n")
+	}
+}
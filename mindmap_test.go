@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMindMap(t *testing.T) {
+	sub := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{{Node: Node{Name: `"A" & <B>`}, URL: "https://a/?x=1&y=2"}}}
+	top := &Folder{Node: Node{Name: "Bar"}, Folders: []*Folder{sub}, Links: []*Link{{URL: "https://go.dev/"}}}
+
+	var buff bytes.Buffer
+
+	if err := foldersToMindMap([]*Folder{top}, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `<map version="1.0.1">
+<node TEXT="Bookmarks">
+	<node TEXT="Bar">
+		<node TEXT="Sub" FOLDED="true">
+			<node TEXT="&#34;A&#34; &amp; &lt;B&gt;" LINK="https://a/?x=1&amp;y=2"/>
+		</node>
+		<node TEXT="https://go.dev/" LINK="https://go.dev/"/>
+	</node>
+</node>
+</map>
+`
+
+	if s := buff.String(); s != exp {
+		t.Errorf("Unexpected output:\n%s", s)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerFormat(exportFunc{"netscape", foldersToNetscape})
+	readers["netscape"] = loadNetscape
+}
+
+// reads Netscape bookmark file, like Delicious export, into "Bookmarks" folder, with the links
+// in their folders, and in a sub-folder per tag
+func loadNetscape(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return taggedTree("Bookmarks", "netscape", parseNetscapeHTML(bytes.NewReader(src))), nil
+}
+
+// Netscape bookmark file, as accepted by browsers and by bookmark services like Shaarli or linkding;
+// folder names on the path to each link are listed in its TAGS attribute, and
+// the description from meta info goes to the <DD> element
+func foldersToNetscape(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &netscapeWriter{textWriter{dest: dest}}
+
+	w.write(netscapeHeader)
+	w.folders(folders, nil, 1)
+	w.write("</DL><p>\n")
+
+	return w.err
+}
+
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`
+
+type netscapeWriter struct {
+	textWriter
+}
+
+func (w *netscapeWriter) folders(folders []*Folder, path []string, level int) {
+	indent := strings.Repeat("    ", level)
+
+	for _, folder := range folders {
+		p := append(path[:len(path):len(path)], folder.Name)
+
+		w.write(indent + "<DT><H3" + netscapeDate("ADD_DATE", folder.Added) + netscapeDate("LAST_MODIFIED", folder.Modified) +
+			">" + html.EscapeString(folder.Name) + "</H3>\n" + indent + "<DL><p>\n")
+
+		tags := netscapeTags(p)
+
+		for _, link := range folder.Links {
+			w.write(indent + "    <DT><A HREF=\"" + htmlHref(link.URL) + "\"" + netscapeDate("ADD_DATE", link.Added) +
+				tags + ">" + html.EscapeString(link.Name) + "</A>\n")
+
+			if desc := link.description(); len(desc) > 0 {
+				w.write(indent + "    <DD>" + html.EscapeString(desc) + "\n")
+			}
+		}
+
+		w.folders(folder.Folders, p, level+1)
+		w.write(indent + "</DL><p>\n")
+	}
+}
+
+func netscapeDate(attr string, ts time.Time) string {
+	if !ts.After(googleEpoch) {
+		return ""
+	}
+
+	return " " + attr + "=\"" + strconv.FormatInt(ts.Unix(), 10) + "\""
+}
+
+// comma separated tags without spaces, the way Shaarli expects them
+func netscapeTags(path []string) string {
+	tags := pathTags(path)
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	for i, tag := range tags {
+		tags[i] = strings.Join(strings.Fields(tag), "_")
+	}
+
+	return " TAGS=\"" + html.EscapeString(strings.Join(tags, ",")) + "\""
+}
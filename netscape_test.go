@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetscape(t *testing.T) {
+	added := time.Unix(1500000000, 0).UTC()
+	folders := []*Folder{{
+		Node:  Node{Name: "Bookmarks bar"},
+		Links: []*Link{{Node: Node{Name: "Go & Co", Added: added}, URL: "https://go.dev/?a=1&b=2"}},
+		Folders: []*Folder{{
+			Node:  Node{Name: "Dev tools", Added: added},
+			Links: []*Link{{Node: Node{Name: "GitHub", Meta: map[string]string{"Description": "Code <hosting>"}}, URL: "https://github.com/"}},
+		}},
+	}}
+
+	var buf strings.Builder
+
+	if err := foldersToNetscape(folders, newOptions(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s := buf.String()
+
+	for _, exp := range []string{
+		`<DT><A HREF="https://go.dev/?a=1&amp;b=2" ADD_DATE="1500000000" TAGS="Bookmarks_bar">Go &amp; Co</A>`,
+		`<DT><H3 ADD_DATE="1500000000">Dev tools</H3>`,
+		`<DT><A HREF="https://github.com/" TAGS="Bookmarks_bar,Dev_tools">GitHub</A>
+            <DD>Code &lt;hosting&gt;
+`,
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %s in:\n%s", exp, s)
+		}
+	}
+
+	// read back, without the tags made of the folder names
+	root := taggedTree("Bookmarks", "netscape", parseNetscapeHTML(strings.NewReader(s)))
+	lines := linkLines(root)
+
+	if exp := []string{
+		"Bookmarks/Bookmarks bar/Go & Co <https://go.dev/?a=1&b=2>",
+		"Bookmarks/Bookmarks bar/Dev tools/GitHub <https://github.com/>",
+	}; !reflect.DeepEqual(lines, exp) {
+		t.Errorf("Unexpected links: %q", lines)
+	}
+}
+
+func TestDeliciousImport(t *testing.T) {
+	const src = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<!-- This is an automatically generated file.
+It will be read and overwritten.
+Do Not Edit! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p><DT><A HREF="http://www.example.com/" LAST_VISIT="1200000000" ADD_DATE="1200000000" PRIVATE="0" TAGS="web,design">Example</A>
+<DD>Old &amp; good
+<DT><A HREF="http://golang.org/" ADD_DATE="1300000000" PRIVATE="1" TAGS="">Go</A>
+</DL><p>`
+
+	root := taggedTree("Bookmarks", "netscape", parseNetscapeHTML(strings.NewReader(src)))
+	lines := linkLines(root)
+
+	if exp := []string{
+		"Bookmarks/Go <http://golang.org/>",
+		"Bookmarks/design/Example <http://www.example.com/>",
+		"Bookmarks/web/Example <http://www.example.com/>",
+	}; !reflect.DeepEqual(lines, exp) {
+		t.Errorf("Unexpected links: %q", lines)
+	}
+
+	if link := root.Folders[0].Folders[1].Links[0]; link.description() != "Old & good" || link.Added.Unix() != 1200000000 {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+}
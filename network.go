@@ -0,0 +1,493 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// default number of concurrent network workers
+const defaultConcurrency = 4
+
+// default timeout for a single HTTP request
+const defaultTimeout = time.Minute
+
+// HTTP client shared by all network operations
+type webClient struct {
+	client     *http.Client
+	limiter    *hostLimiter // may be nil
+	userAgent  string
+	header     http.Header // extra request headers
+	retries    int
+	retryDelay time.Duration // doubled after every retry
+	warc       *warcWriter   // may be nil
+}
+
+func newWebClient() *webClient {
+	return &webClient{
+		client:    &http.Client{Timeout: defaultTimeout},
+		userAgent: programName,
+	}
+}
+
+// web client configured from the command line
+func (opts *options) newWebClient() *webClient {
+	wc := newWebClient()
+
+	wc.client.Timeout = opts.timeout
+	wc.retries, wc.retryDelay = opts.retries, opts.retryDelay
+	wc.warc = opts.warc
+
+	if len(opts.proxy) > 0 {
+		wc.client.Transport = proxyTransport(opts.proxy)
+	}
+
+	if len(opts.userAgent) > 0 {
+		wc.userAgent = opts.userAgent
+	}
+
+	if len(opts.headers) > 0 {
+		wc.header = make(http.Header)
+
+		for _, s := range opts.headers {
+			k, v, _ := parseHeader(s)
+			wc.header.Add(k, v)
+		}
+	}
+
+	if opts.perHostDelay > 0 || opts.perHostMax > 0 {
+		wc.limiter = &hostLimiter{
+			delay: opts.perHostDelay,
+			max:   opts.perHostMax,
+			hosts: make(map[string]*hostSlot),
+		}
+	}
+
+	return wc
+}
+
+// performs the request; any non-2xx response is an error
+func (wc *webClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", wc.userAgent)
+
+	// headers set by the request itself, like API authorization, take precedence
+	for k, v := range wc.header {
+		if _, ok := req.Header[k]; !ok || k == "User-Agent" {
+			req.Header[k] = v
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := wc.send(req)
+
+		if err == nil || attempt >= wc.retries || !retryable(err) {
+			return resp, err
+		}
+
+		// the body must be sent again
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return nil, err
+			}
+
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		delay := wc.retryDelay << uint(attempt)
+
+		if e, ok := err.(*HTTPError); ok && e.retryAfter > delay {
+			delay = e.retryAfter
+		}
+
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+
+		logInfo("%s, retrying in %s", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// makes a single attempt of the request
+func (wc *webClient) send(req *http.Request) (*http.Response, error) {
+	release := wc.limiter.acquire(req.URL.Host)
+	resp, err := wc.client.Do(req)
+
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if wc.warc != nil {
+		wc.warc.record(resp)
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+
+		e := &HTTPError{Status: resp.StatusCode, URL: urlString(resp.Request.URL)}
+
+		if n, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && n > 0 {
+			e.retryAfter = time.Duration(n) * time.Second
+		}
+
+		return nil, e
+	}
+
+	return resp, nil
+}
+
+// upper limit of the delay before a retry
+const maxRetryDelay = time.Minute
+
+// checks if the request may succeed when repeated
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case *HTTPError:
+		switch e.Status {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	case *url.Error:
+		if op, ok := e.Err.(*net.OpError); ok {
+			if dns, ok := op.Err.(*net.DNSError); ok && dns.IsNotFound {
+				return false
+			}
+		}
+
+		// network failures and timeouts, but not redirect loops and the like
+		_, ok := e.Err.(net.Error)
+		return ok
+	}
+
+	return false
+}
+
+// posts the value as JSON and decodes the JSON response into the result, unless it is nil
+func (wc *webClient) postJSON(url string, header http.Header, value, result interface{}) error {
+	return wc.sendJSON("POST", url, header, value, result)
+}
+
+func (wc *webClient) sendJSON(method, url string, header http.Header, value, result interface{}) error {
+	body, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := wc.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("Invalid response from %s: %s", url, err)
+	}
+
+	return nil
+}
+
+// transport sending all requests through the proxy, except for the hosts listed in $NO_PROXY
+func proxyTransport(proxy string) *http.Transport {
+	config := httpproxy.FromEnvironment()
+
+	config.HTTPProxy, config.HTTPSProxy = proxy, proxy
+
+	proxyFunc := config.ProxyFunc()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+
+	return transport
+}
+
+// validates proxy URL like "http://proxy:3128" or "socks5://localhost:9050"
+func checkProxy(s string) error {
+	u, err := url.Parse(s)
+
+	if err != nil || len(u.Host) == 0 || !contains([]string{"http", "https", "socks5"}, u.Scheme) {
+		return fmt.Errorf("Invalid proxy %q, expected http://, https:// or socks5:// URL", s)
+	}
+
+	return nil
+}
+
+// parses header like "Accept-Language: en"
+func parseHeader(s string) (name, value string, err error) {
+	i := strings.IndexByte(s, ':')
+
+	if i <= 0 || strings.ContainsAny(s[:i], " \t") {
+		return "", "", fmt.Errorf("Invalid HTTP header %q, expected \"Name: value\"", s)
+	}
+
+	return http.CanonicalHeaderKey(s[:i]), strings.TrimSpace(s[i+1:]), nil
+}
+
+// limits the number of concurrent requests to the same host, and the interval between them
+type hostLimiter struct {
+	delay time.Duration
+	max   int // no limit if 0
+	lock  sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	sem  chan struct{}
+	lock sync.Mutex
+	next time.Time // the earliest start of the next request
+}
+
+// waits until a request to the host can be made, and returns the function to call when it is done
+func (hl *hostLimiter) acquire(host string) func() {
+	if hl == nil {
+		return func() {}
+	}
+
+	hl.lock.Lock()
+
+	slot, ok := hl.hosts[host]
+
+	if !ok {
+		slot = &hostSlot{sem: make(chan struct{}, hl.max)}
+		hl.hosts[host] = slot
+	}
+
+	hl.lock.Unlock()
+
+	if hl.max > 0 {
+		slot.sem <- struct{}{}
+	}
+
+	if hl.delay > 0 {
+		slot.lock.Lock()
+
+		now := time.Now()
+		wait := slot.next.Sub(now)
+
+		if wait > 0 {
+			now = slot.next
+		}
+
+		slot.next = now.Add(hl.delay)
+		slot.lock.Unlock()
+
+		time.Sleep(wait)
+	}
+
+	return func() {
+		if hl.max > 0 {
+			<-slot.sem
+		}
+	}
+}
+
+// response body releasing the host slot when closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	b.once.Do(b.release)
+	return err
+}
+
+// HTTP error status
+type HTTPError struct {
+	Status     int
+	URL        string
+	retryAfter time.Duration // from "Retry-After" header
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP status %d (%s) from %s", e.Status, http.StatusText(e.Status), e.URL)
+}
+
+// calls fn for each link using up to n concurrent workers;
+// stops handing out new links after the first error, which is then returned
+// once all the workers have finished
+func forEachLink(links []*Link, n int, fn func(*Link) error) error {
+	if n < 1 {
+		n = 1
+	}
+
+	queue := make(chan *Link)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for link := range queue {
+				if err := fn(link); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(done)
+					})
+				}
+			}
+		}()
+	}
+
+loop:
+	for _, link := range links {
+		select {
+		case queue <- link:
+		case <-done:
+			break loop
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// like URL.String(), but also handles URLs with opaque paths
+func urlString(u *url.URL) string {
+	if len(u.Opaque) > 0 && !strings.HasPrefix(u.Opaque, "//") {
+		return u.Scheme + "://" + u.Host + u.Opaque
+	}
+
+	return u.String()
+}
+
+// only http(s) links can be processed by network operations
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+// checks for "javascript:" URL
+func isBookmarklet(s string) bool {
+	return len(s) >= 11 && strings.EqualFold(s[:11], "javascript:")
+}
+
+// links with unique web URLs, in the order of appearance
+func webLinks(roots []*Folder) []*Link {
+	var all []*Link
+
+	for _, root := range roots {
+		all = append(all, root.allLinks()...)
+	}
+
+	return uniqueWebLinks(all)
+}
+
+func uniqueWebLinks(all []*Link) []*Link {
+	var links []*Link
+	var dups, other int
+
+	seen := make(map[string]bool)
+
+	for _, link := range all {
+		switch {
+		case seen[link.URL]:
+			dups++
+		case isBookmarklet(link.URL):
+			logInfo("skipped bookmarklet %q", link.Name)
+			other++
+		case !isWebURL(link.URL):
+			logInfo("skipped non-web link %q", link.URL)
+			other++
+		default:
+			seen[link.URL] = true
+			links = append(links, link)
+		}
+	}
+
+	if dups > 0 || other > 0 {
+		logInfo("skipped %d duplicate and %d non-web links", dups, other)
+	}
+
+	return links
+}
+
+// all links in the tree, depth first
+func (folder *Folder) allLinks() []*Link {
+	var links []*Link
+
+	folder.Walk(func(_ []string, node interface{}) error {
+		if link, ok := node.(*Link); ok {
+			links = append(links, link)
+		}
+
+		return nil
+	})
+
+	return links
+}
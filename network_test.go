@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachLinkAll(t *testing.T) {
+	links := makeTestLinks(100)
+
+	var count int32
+
+	err := forEachLink(links, 4, func(*Link) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 100 {
+		t.Fatalf("Processed %d links instead of 100", count)
+	}
+}
+
+func TestForEachLinkStopsOnError(t *testing.T) {
+	links := makeTestLinks(1000)
+	errTest := errors.New("test error")
+
+	var count, running int32
+
+	err := forEachLink(links, 4, func(link *Link) error {
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		atomic.AddInt32(&count, 1)
+		time.Sleep(time.Millisecond)
+
+		if link.Key == "#10" {
+			return errTest
+		}
+
+		return nil
+	})
+
+	if err != errTest {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// all workers must have finished by now
+	if n := atomic.LoadInt32(&running); n != 0 {
+		t.Fatalf("%d workers still running", n)
+	}
+
+	n := atomic.LoadInt32(&count)
+
+	if n >= int32(len(links)) {
+		t.Fatalf("Processing has not stopped: %d links", n)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if m := atomic.LoadInt32(&count); m != n {
+		t.Fatalf("Links processed after return: %d -> %d", n, m)
+	}
+}
+
+func TestWebLinks(t *testing.T) {
+	root := &Folder{
+		Links: []*Link{
+			{URL: "https://example.com/"},
+			{URL: "javascript:alert(1)"},
+			{URL: "https://example.com/"},
+		},
+		Folders: []*Folder{
+			{Links: []*Link{{URL: "http://example.org/"}, {URL: "file:///etc/passwd"}}},
+		},
+	}
+
+	links := webLinks([]*Folder{root})
+
+	if len(links) != 2 || links[0].URL != "https://example.com/" || links[1].URL != "http://example.org/" {
+		t.Fatalf("Unexpected links: %v", links)
+	}
+}
+
+func makeTestLinks(n int) []*Link {
+	links := make([]*Link, n)
+
+	for i := range links {
+		links[i] = &Link{Node: Node{Key: "#" + strconv.Itoa(i)}}
+	}
+
+	return links
+}
+
+func TestPerHostLimits(t *testing.T) {
+	var running, maxRunning int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}))
+
+	defer srv.Close()
+
+	opts := newOptions()
+	opts.perHostMax = 1
+	opts.perHostDelay = 20 * time.Millisecond
+
+	client := opts.newWebClient()
+	links := makeTestLinks(4)
+
+	for _, link := range links {
+		link.URL = srv.URL + "/"
+	}
+
+	start := time.Now()
+
+	err := forEachLink(links, 4, func(link *Link) error {
+		_, err := checkLink(client, link.URL, "")
+		return err
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if maxRunning != 1 {
+		t.Errorf("%d concurrent requests to the same host", maxRunning)
+	}
+
+	if d := time.Since(start); d < 60*time.Millisecond {
+		t.Errorf("Requests are not delayed: %s", d)
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	var header http.Header
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+
+	defer srv.Close()
+
+	opts := newOptions()
+	opts.userAgent = "Mozilla/5.0"
+	opts.headers = stringList{"accept-language: de", "Authorization: Basic xyz"}
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer abc")
+
+	resp, err := opts.newWebClient().do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	exp := map[string]string{"User-Agent": "Mozilla/5.0", "Accept-Language": "de", "Authorization": "Bearer abc"}
+
+	for k, v := range exp {
+		if s := header.Get(k); s != v {
+			t.Errorf("%s: got %q instead of %q", k, s, v)
+		}
+	}
+
+	for _, s := range []string{"Accept", ": x", "Accept Language: en"} {
+		if _, _, err := parseHeader(s); err == nil {
+			t.Errorf("Invalid header %q accepted", s)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "intranet.example.com")
+
+	transport := proxyTransport("socks5://localhost:9050")
+
+	for host, exp := range map[string]string{
+		"https://example.com/":          "socks5://localhost:9050",
+		"http://intranet.example.com/x": "",
+	} {
+		req, _ := http.NewRequest("GET", host, nil)
+		u, err := transport.Proxy(req)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var s string
+
+		if u != nil {
+			s = u.String()
+		}
+
+		if s != exp {
+			t.Errorf("%s: got proxy %q instead of %q", host, s, exp)
+		}
+	}
+
+	for _, s := range []string{"localhost:3128", "ftp://proxy", "http://"} {
+		if checkProxy(s) == nil {
+			t.Errorf("Invalid proxy %q accepted", s)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "data" {
+			t.Errorf("Unexpected body: %q", body)
+		}
+
+		switch attempts++; r.URL.Path {
+		case "/flaky":
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+
+	opts := newOptions()
+	opts.retries = 2
+	opts.retryDelay = time.Millisecond
+
+	client := opts.newWebClient()
+
+	post := func(path string) error {
+		req, _ := http.NewRequest("POST", srv.URL+path, strings.NewReader("data"))
+		resp, err := client.do(req)
+
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	if err := post("/flaky"); err != nil || attempts != 3 {
+		t.Errorf("Unexpected result: %v after %d attempts", err, attempts)
+	}
+
+	// not a transient error
+	attempts = 0
+
+	if err := post("/missing"); err == nil || attempts != 1 {
+		t.Errorf("Unexpected result: %v after %d attempts", err, attempts)
+	}
+
+	// retries exhausted
+	attempts = 0
+
+	if err := post("/down"); err == nil || attempts != 3 {
+		t.Errorf("Unexpected result: %v after %d attempts", err, attempts)
+	}
+}
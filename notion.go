@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerPushTarget("notion", "Create or update one Notion database row per bookmark", pushNotion)
+}
+
+// Notion API endpoint, variable for testing
+var notionAPI = "https://api.notion.com/v1"
+
+const notionVersion = "2022-06-28"
+
+// Notion allows about 3 requests per second
+const notionDelay = 350 * time.Millisecond
+
+// "push notion" command
+func pushNotion(args []string) error {
+	fs := newFlagSet("push notion", "")
+	opts := newPushOptions(fs)
+
+	var token, database string
+
+	fs.StringVar(&token, "token", "", "Notion integration token (default $NOTION_TOKEN)")
+	fs.StringVar(&database, "database", "", "Id of the database with properties \"Name\" (title), \"URL\" (URL), "+
+		"\"Folder\" (text) and \"Added\" (date) (default $NOTION_DATABASE)")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if err := credential(&token, "token", "NOTION_TOKEN"); err != nil {
+		return err
+	}
+
+	if err := credential(&database, "database", "NOTION_DATABASE"); err != nil {
+		return err
+	}
+
+	links, paths, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	nt := &notion{
+		database: database,
+		client:   opts.newWebClient(),
+		header: http.Header{
+			"Authorization":  {"Bearer " + token},
+			"Notion-Version": {notionVersion},
+		},
+		ticker: time.NewTicker(notionDelay),
+	}
+
+	defer nt.ticker.Stop()
+
+	for _, link := range links {
+		if err = nt.put(link, paths[link]); err != nil {
+			return fmt.Errorf("%s: %s", link.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// Notion API client
+type notion struct {
+	database string
+	client   *webClient
+	header   http.Header
+	ticker   *time.Ticker
+}
+
+func (nt *notion) send(method, path string, value, result interface{}) error {
+	<-nt.ticker.C
+	return nt.client.sendJSON(method, notionAPI+path, nt.header, value, result)
+}
+
+// creates a new row for the link, or updates the existing one
+func (nt *notion) put(link *Link, path []string) error {
+	// look up existing row
+	query := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"property": "URL",
+			"url":      map[string]string{"equals": link.URL},
+		},
+		"page_size": 1,
+	}
+
+	var found struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+
+	if err := nt.send("POST", "/databases/"+nt.database+"/query", query, &found); err != nil {
+		return err
+	}
+
+	// properties
+	props := map[string]interface{}{
+		"Name":   map[string]interface{}{"title": notionText(link.Name)},
+		"URL":    map[string]interface{}{"url": link.URL},
+		"Folder": map[string]interface{}{"rich_text": notionText(strings.Join(path, "/"))},
+	}
+
+	if link.Added.After(googleEpoch) {
+		props["Added"] = map[string]interface{}{
+			"date": map[string]string{"start": link.Added.UTC().Format(time.RFC3339)},
+		}
+	}
+
+	// update
+	if len(found.Results) > 0 {
+		logInfo("%s: updating", link.URL)
+		return nt.send("PATCH", "/pages/"+found.Results[0].ID, map[string]interface{}{"properties": props}, nil)
+	}
+
+	// create
+	logInfo("%s: creating", link.URL)
+
+	page := map[string]interface{}{
+		"parent":     map[string]string{"database_id": nt.database},
+		"properties": props,
+	}
+
+	return nt.send("POST", "/pages", page, nil)
+}
+
+// rich text array with a single plain text element
+func notionText(s string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "text",
+			"text": map[string]string{"content": s},
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotionPut(t *testing.T) {
+	var requests []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Notion-Version") != notionVersion {
+			t.Error("Missing Notion-Version header")
+		}
+
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch r.URL.Path {
+		case "/databases/db/query":
+			var q struct {
+				Filter struct {
+					URL struct{ Equals string }
+				}
+			}
+
+			json.NewDecoder(r.Body).Decode(&q)
+
+			if q.Filter.URL.Equals == "https://example.com/old" {
+				w.Write([]byte(`{"results": [{"id": "page1"}]}`))
+			} else {
+				w.Write([]byte(`{"results": []}`))
+			}
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+
+	defer srv.Close()
+	defer func(api string) { notionAPI = api }(notionAPI)
+
+	notionAPI = srv.URL
+
+	nt := &notion{
+		database: "db",
+		client:   newWebClient(),
+		header:   http.Header{"Notion-Version": {notionVersion}},
+		ticker:   time.NewTicker(time.Millisecond),
+	}
+
+	defer nt.ticker.Stop()
+
+	if err := nt.put(&Link{URL: "https://example.com/old"}, []string{"A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nt.put(&Link{URL: "https://example.com/new"}, []string{"A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"POST /databases/db/query", "PATCH /pages/page1", "POST /databases/db/query", "POST /pages"}
+
+	if len(requests) != len(exp) {
+		t.Fatalf("Unexpected requests: %v", requests)
+	}
+
+	for i := range exp {
+		if requests[i] != exp[i] {
+			t.Fatalf("Unexpected requests: %v", requests)
+		}
+	}
+}
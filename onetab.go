@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	readers["onetab"] = loadOneTab
+}
+
+// reads OneTab export, with a "URL | Title" line per tab and empty lines between the tab groups,
+// into "OneTab" folder with a sub-folder per group
+func loadOneTab(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := parseOneTab(bytes.NewReader(src))
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	top := &Folder{Node: Node{Name: "OneTab", Key: "onetab"}}
+
+	for i, links := range groups {
+		top.Folders = append(top.Folders, &Folder{Node: Node{Name: "Group " + strconv.Itoa(i+1)}, Links: links})
+	}
+
+	return &Folder{Folders: []*Folder{top}, container: true}, nil
+}
+
+func parseOneTab(src io.Reader) (groups [][]*Link, err error) {
+	scanner := bufio.NewScanner(src)
+	var links []*Link
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 {
+			if len(links) > 0 {
+				groups, links = append(groups, links), nil
+			}
+
+			continue
+		}
+
+		link := &Link{URL: line}
+
+		if i := strings.Index(line, " | "); i >= 0 {
+			link.URL, link.Name = strings.TrimSpace(line[:i]), foldSpaces(line[i+3:])
+		}
+
+		links = append(links, link)
+	}
+
+	if len(links) > 0 {
+		groups = append(groups, links)
+	}
+
+	return groups, scanner.Err()
+}
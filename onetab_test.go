@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOneTab(t *testing.T) {
+	const src = "https://a.com/ | A | B\r\n" +
+		"https://b.com/\n" +
+		"\n\n" +
+		"https://c.com/ | C\n"
+
+	groups, err := parseOneTab(strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res [][]string
+
+	for _, links := range groups {
+		var names []string
+
+		for _, link := range links {
+			names = append(names, link.URL+" "+link.Name)
+		}
+
+		res = append(res, names)
+	}
+
+	exp := [][]string{
+		{"https://a.com/ A | B", "https://b.com/ "},
+		{"https://c.com/ C"},
+	}
+
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("Unexpected groups: %q", res)
+	}
+}
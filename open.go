@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerCommand("open", "Open the bookmark best matching the query in the default browser", openCmd)
+}
+
+// maximum number of bookmarks to choose from
+const maxPickerItems = 10
+
+// "open" command
+func openCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("open", "QUERY...")
+
+	var print, first bool
+
+	opts.inputFlags(fs)
+	opts.treeFlags(fs)
+	fs.BoolVar(&print, "print", false, "Print the URL instead of opening it")
+	fs.BoolVar(&first, "first", false, "Take the first of equally good matches, instead of asking")
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("Missing query")
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	var all []*Link
+
+	for _, root := range roots {
+		all = append(all, root.allLinks()...)
+	}
+
+	matches := fuzzyMatches(all, fs.Args())
+
+	if len(matches) == 0 {
+		return errors.New("No bookmarks match " + strconv.Quote(strings.Join(fs.Args(), " ")))
+	}
+
+	link := matches[0].link
+
+	// ask on ambiguity, unless there is no one to ask
+	if len(matches) > 1 && matches[1].score == matches[0].score && !first {
+		if !interactive() {
+			logInfo("several bookmarks match equally well, taking the first one")
+		} else if link, err = pickLink(matches, os.Stdin, os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	if print {
+		_, err = fmt.Println(link.URL)
+		return err
+	}
+
+	return openURL(link.URL)
+}
+
+type fuzzyMatch struct {
+	link  *Link
+	score int
+}
+
+// links matching all the terms by their names or URLs, the best match first; links with the same URL
+// are only listed once
+func fuzzyMatches(links []*Link, terms []string) (res []fuzzyMatch) {
+	seen := make(map[string]bool)
+
+	for _, link := range links {
+		if seen[link.URL] {
+			continue
+		}
+
+		score := 0
+
+		for _, term := range terms {
+			s := fuzzyScore(term, link.Name)
+
+			if u := fuzzyScore(term, link.URL); u > s {
+				s = u
+			}
+
+			if s == 0 {
+				score = 0
+				break
+			}
+
+			score += s
+		}
+
+		if score > 0 {
+			seen[link.URL] = true
+			res = append(res, fuzzyMatch{link, score})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].score > res[j].score })
+	return
+}
+
+// score of the pattern matching the text as a subsequence, ignoring case, or 0 if it does not match;
+// consecutive characters, and characters at the start of words, score higher
+func fuzzyScore(pattern, text string) (best int) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+
+	if len(p) == 0 {
+		return 0
+	}
+
+	for start := range t {
+		if t[start] != p[0] {
+			continue
+		}
+
+		score, k, prev := 0, 0, -2
+
+		for i := start; i < len(t) && k < len(p); i++ {
+			if t[i] != p[k] {
+				continue
+			}
+
+			score++
+
+			if i == prev+1 {
+				score += 2
+			}
+
+			if i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1]) {
+				score += 3
+			}
+
+			prev = i
+			k++
+		}
+
+		if k == len(p) && score > best {
+			best = score
+		}
+	}
+
+	return
+}
+
+// lets the user choose one of the best matches
+func pickLink(matches []fuzzyMatch, src io.Reader, dest io.Writer) (*Link, error) {
+	if len(matches) > maxPickerItems {
+		matches = matches[:maxPickerItems]
+	}
+
+	for i, m := range matches {
+		fmt.Fprintf(dest, "%2d) %s\n    %s\n", i+1, foldSpaces(m.link.Name), m.link.URL)
+	}
+
+	fmt.Fprint(dest, "Open: ")
+
+	line, err := bufio.NewReader(src).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+
+	if err != nil || n < 1 || n > len(matches) {
+		return nil, errors.New("Nothing selected")
+	}
+
+	return matches[n-1].link, nil
+}
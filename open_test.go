@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatches(t *testing.T) {
+	links := []*Link{
+		{Node: Node{Name: "Go documentation"}, URL: "https://go.dev/doc/"},
+		{Node: Node{Name: "Google"}, URL: "https://www.google.com/"},
+		{Node: Node{Name: "GitHub"}, URL: "https://github.com/"},
+		{Node: Node{Name: "Go docs, again"}, URL: "https://go.dev/doc/"},
+	}
+
+	tests := []struct {
+		terms []string
+		exp   []string
+	}{
+		{[]string{"godoc"}, []string{"https://go.dev/doc/"}},
+		{[]string{"go", "doc"}, []string{"https://go.dev/doc/"}},
+		{[]string{"gh"}, []string{"https://github.com/"}},
+		{[]string{"com"}, []string{"https://www.google.com/", "https://github.com/"}},
+		{[]string{"xyz"}, nil},
+	}
+
+	for _, test := range tests {
+		var urls []string
+
+		for _, m := range fuzzyMatches(links, test.terms) {
+			urls = append(urls, m.link.URL)
+		}
+
+		if strings.Join(urls, " ") != strings.Join(test.exp, " ") {
+			t.Errorf("%q: unexpected matches %q", test.terms, urls)
+		}
+	}
+
+	if fuzzyScore("gh", "GitHub") <= fuzzyScore("gh", "Google") {
+		t.Error("Word start does not score higher")
+	}
+}
+
+func TestPickLink(t *testing.T) {
+	matches := []fuzzyMatch{
+		{&Link{Node: Node{Name: "A"}, URL: "https://a.com/"}, 5},
+		{&Link{Node: Node{Name: "B"}, URL: "https://b.com/"}, 5},
+	}
+
+	var out bytes.Buffer
+
+	link, err := pickLink(matches, strings.NewReader("2\n"), &out)
+
+	if err != nil || link.URL != "https://b.com/" {
+		t.Fatalf("Unexpected link: %v, %v", link, err)
+	}
+
+	if !strings.Contains(out.String(), " 1) A\n    https://a.com/\n") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+
+	if _, err = pickLink(matches, strings.NewReader("3\n"), &out); err == nil {
+		t.Error("Missing error")
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand("organize", "Move the links of a folder into sub-folders per web site or category", organizeCmd)
+}
+
+// "organize" command
+func organizeCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("organize", "")
+
+	opts.inputFlags(fs)
+	opts.outputFlags(fs)
+	opts.writeBackFlags(fs)
+	opts.logFlags(fs)
+
+	var folder, rulesFile string
+	var byDomain bool
+
+	fs.StringVar(&folder, "folder", "", "Folder to organize, like \"Bookmarks bar/Unsorted\"")
+	fs.BoolVar(&byDomain, "by-domain", false, "Make a sub-folder per web site, or per category with --rules")
+	fs.StringVar(&rulesFile, "rules", "",
+		"File of \"DOMAIN = CATEGORY\" lines; links from other sites are left in place")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if !byDomain {
+		return errors.New("Nothing to do, please specify --by-domain")
+	}
+
+	if len(strings.Trim(folder, "/")) == 0 {
+		return errors.New("Please specify --folder")
+	}
+
+	if len(opts.inputs) > 1 || opts.inputs[0].name == stdin {
+		return errors.New("Only one input file is allowed, and it cannot be STDIN")
+	}
+
+	var rules map[string]string
+
+	if len(rulesFile) > 0 {
+		file, err := os.Open(rulesFile)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		if rules, err = parseDomainRules(file); err != nil {
+			return fmt.Errorf("%s: %s", rulesFile, err)
+		}
+	}
+
+	name := opts.inputs[0].name
+	data, err := loadRawData(name)
+
+	if err != nil {
+		return err
+	}
+
+	// the result is either exported, or written back
+	export := opts.outputName != stdout
+
+	if !export && !data.sumValid {
+		return errors.New(name + ": Checksum mismatch, the file may be corrupted or edited by hand")
+	}
+
+	var node map[string]interface{}
+
+	walkRawFolders(data.Roots, func(path []string, n map[string]interface{}) {
+		if node == nil && strings.Join(path, "/") == strings.Trim(folder, "/") {
+			node = n
+		}
+	})
+
+	if node == nil {
+		return fmt.Errorf("Folder %q is not found", folder)
+	}
+
+	b := &rawBuilder{now: googleTimeStamp(time.Now())}
+
+	walkRawFolders(data.Roots, func(_ []string, n map[string]interface{}) {
+		b.maxID(n)
+	})
+
+	changes := organizeByDomain(b, node, func(url string) string {
+		if rules == nil {
+			return linkHost(url)
+		}
+
+		return domainCategory(rules, linkHost(url))
+	})
+
+	if export {
+		root, err := buildTree("roots", data.Roots)
+
+		if err != nil {
+			return err
+		}
+
+		root.Name = opts.inputs[0].label
+
+		if err = opts.transform([]*Folder{root}); err != nil {
+			return err
+		}
+
+		return writeFolders(opts, root.Folders)
+	}
+
+	if len(changes) == 0 {
+		logNotice("nothing to organize")
+		return nil
+	}
+
+	if apply, err := opts.confirm(changes); !apply {
+		return err
+	}
+
+	if err = data.save(name); err != nil {
+		return err
+	}
+
+	logNotice("organized %s; Opera must not be running while the file is replaced", name)
+	return nil
+}
+
+// moves the links of the folder into sub-folders named by the given function, reusing
+// the existing sub-folders of the same name; links with empty sub-folder name are left in place
+func organizeByDomain(b *rawBuilder, node map[string]interface{}, group func(string) string) (changes []string) {
+	children, _ := node["children"].([]interface{})
+	folders := make(map[string]map[string]interface{})
+	var kept, added []interface{}
+
+	for _, child := range children {
+		if c, ok := child.(map[string]interface{}); ok && rawString(c, "type") == "folder" {
+			if _, ok := folders[rawString(c, "name")]; !ok {
+				folders[rawString(c, "name")] = c
+			}
+		}
+	}
+
+	for _, child := range children {
+		c, ok := child.(map[string]interface{})
+		name := ""
+
+		if ok && rawString(c, "type") == "url" {
+			name = group(rawString(c, "url"))
+		}
+
+		if len(name) == 0 {
+			kept = append(kept, child)
+			continue
+		}
+
+		sub, ok := folders[name]
+
+		if !ok {
+			sub = b.newNode("folder", name)
+			sub["children"] = []interface{}{}
+			folders[name] = sub
+			added = append(added, sub)
+		}
+
+		sub["children"] = append(sub["children"].([]interface{}), c)
+		sub["date_modified"] = b.now
+		changes = append(changes, "moved "+rawString(c, "name")+" <"+rawString(c, "url")+"> to "+name)
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	// new folders go after the existing ones, by name
+	sort.Slice(added, func(i, j int) bool {
+		return strings.ToLower(rawString(added[i], "name")) < strings.ToLower(rawString(added[j], "name"))
+	})
+
+	for _, sub := range added {
+		changes = append(changes, "new folder "+rawString(sub, "name"))
+	}
+
+	var res []interface{}
+
+	for i, item := range kept {
+		if rawString(item, "type") != "folder" {
+			res = append(append(res, added...), kept[i:]...)
+			added = nil
+			break
+		}
+
+		res = append(res, item)
+	}
+
+	node["children"] = append(res, added...)
+	node["date_modified"] = b.now
+	return
+}
+
+// reads "DOMAIN = CATEGORY" lines, skipping empty lines and "#" comments
+func parseDomainRules(src io.Reader) (map[string]string, error) {
+	rules := make(map[string]string)
+	scanner := bufio.NewScanner(src)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+
+		if i < 0 {
+			return nil, fmt.Errorf("Line %d: Missing \"=\"", n)
+		}
+
+		domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(line[:i])), "www.")
+		category := strings.TrimSpace(line[i+1:])
+
+		if len(domain) == 0 || len(category) == 0 {
+			return nil, fmt.Errorf("Line %d: Empty domain or category", n)
+		}
+
+		rules[domain] = category
+	}
+
+	return rules, scanner.Err()
+}
+
+// category of the host, from the rule for the host itself or its closest parent domain
+func domainCategory(rules map[string]string, host string) string {
+	for len(host) > 0 {
+		if category, ok := rules[host]; ok {
+			return category
+		}
+
+		i := strings.Index(host, ".")
+
+		if i < 0 {
+			break
+		}
+
+		host = host[i+1:]
+	}
+
+	return ""
+}
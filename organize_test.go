@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrganize(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0", "children": [
+			{"type": "folder", "name": "example.com", "id": "2", "date_added": "0", "date_modified": "0", "children": []},
+			{"type": "url", "name": "a", "url": "https://www.example.com/a", "id": "3", "date_added": "0"},
+			{"type": "url", "name": "b", "url": "https://go.dev/b", "id": "4", "date_added": "0"},
+			{"type": "url", "name": "c", "url": "javascript:void(0)", "id": "5", "date_added": "0"},
+			{"type": "url", "name": "d", "url": "https://blog.go.dev/d", "id": "6", "date_added": "0"}
+		]}
+	}}`
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-q", "-i", name, "--by-domain", "--folder", "Bar"}
+
+	if err := organizeCmd(args); err == nil {
+		t.Fatal("Changed without confirmation")
+	}
+
+	// export leaves the file as it is
+	out := filepath.Join(dir, "out.txt")
+
+	if err := organizeCmd(append(args, "-o", out, "-f", "text")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ioutil.ReadFile(name); err != nil || string(data) != src {
+		t.Fatalf("Modified by export: %v", err)
+	}
+
+	if data, err := ioutil.ReadFile(out); err != nil || !strings.Contains(string(data), "go.dev") {
+		t.Fatalf("Unexpected export: %q, %v", data, err)
+	}
+
+	if err := organizeCmd(append(args, "--yes")); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := rawChildren(t, name); !reflect.DeepEqual(res, map[string][]string{
+		"Bar":             {"example.com", "blog.go.dev", "go.dev", "c"},
+		"Bar/example.com": {"a"},
+		"Bar/blog.go.dev": {"d"},
+		"Bar/go.dev":      {"b"},
+	}) {
+		t.Fatalf("Unexpected result: %v", res)
+	}
+
+	// rules
+	rules := filepath.Join(dir, "rules")
+
+	if err := ioutil.WriteFile(rules, []byte("# comment\ngo.dev = Go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := organizeCmd(append(args, "--yes", "--rules", rules)); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := rawChildren(t, name); !reflect.DeepEqual(res, map[string][]string{
+		"Bar":    {"example.com", "Go", "a", "c"},
+		"Bar/Go": {"b", "d"},
+	}) {
+		t.Fatalf("Unexpected result: %v", res)
+	}
+}
+
+func TestParseDomainRules(t *testing.T) {
+	if _, err := parseDomainRules(strings.NewReader("example.com Code")); err == nil {
+		t.Error("Missing error")
+	}
+
+	rules, err := parseDomainRules(strings.NewReader("www.Example.com = Code\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, exp := range map[string]string{"example.com": "Code", "a.example.com": "Code", "xexample.com": ""} {
+		if s := domainCategory(rules, host); s != exp {
+			t.Errorf("%s: %q instead of %q", host, s, exp)
+		}
+	}
+}
+
+// children names per folder of the Bookmarks file, checking the checksum
+func rawChildren(t *testing.T, name string) map[string][]string {
+	data, err := loadRawData(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !data.sumValid {
+		t.Fatal("Invalid checksum")
+	}
+
+	res := make(map[string][]string)
+
+	walkRawFolders(data.Roots, func(path []string, node map[string]interface{}) {
+		p := strings.Join(path, "/")
+
+		for _, child := range node["children"].([]interface{}) {
+			res[p] = append(res[p], rawString(child, "name"))
+		}
+	})
+
+	return res
+}
@@ -0,0 +1,296 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// information extracted from a web page
+type pageInfo struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Fetched     time.Time `json:"fetched"`
+	Version     int       `json:"v"`
+}
+
+// entries with older versions are refetched
+const pageInfoVersion = 1
+
+// maximum number of bytes read from a page
+const maxPageSize = 1 << 20
+
+// fetches the page and extracts the information
+func fetchPage(client *webClient, link string) (*pageInfo, error) {
+	req, err := http.NewRequest("GET", link, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), resp.Header.Get("Content-Type"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := parsePage(body)
+
+	info.Fetched = time.Now().UTC()
+	info.Version = pageInfoVersion
+	return info, nil
+}
+
+// extracts the information from the <head> element
+func parsePage(src io.Reader) *pageInfo {
+	info := new(pageInfo)
+	z := html.NewTokenizer(src)
+
+	var ogDescription string
+
+	defer func() {
+		if len(info.Description) == 0 {
+			info.Description = ogDescription
+		}
+	}()
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return info
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch name, hasAttr := z.TagName(); string(name) {
+			case "title":
+				if z.Next() == html.TextToken {
+					info.Title = foldSpaces(string(z.Text()))
+				}
+			case "meta":
+				attrs := make(map[string]string)
+
+				for hasAttr {
+					var k, v []byte
+
+					k, v, hasAttr = z.TagAttr()
+					attrs[string(k)] = string(v)
+				}
+
+				switch {
+				case strings.EqualFold(attrs["name"], "description"):
+					info.Description = foldSpaces(attrs["content"])
+				case attrs["property"] == "og:description":
+					ogDescription = foldSpaces(attrs["content"])
+				}
+			case "body":
+				return info
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return info
+			}
+		}
+	}
+}
+
+// replaces every run of white space with a single space
+func foldSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cache of page information
+type pageCache struct {
+	name   string
+	maxAge time.Duration
+	lock   sync.Mutex
+	pages  map[string]*pageInfo
+	dirty  bool
+}
+
+// $XDG_CACHE_HOME/opera-bookmarks/pages.json, or empty string if the cache directory is unknown
+func defaultPageCache() string {
+	if dir := cacheDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "pages.json")
+	}
+
+	return ""
+}
+
+func loadPageCache(name string, maxAge time.Duration) (*pageCache, error) {
+	cache := &pageCache{
+		name:   name,
+		maxAge: maxAge,
+		pages:  make(map[string]*pageInfo),
+	}
+
+	if len(name) == 0 {
+		return cache, nil
+	}
+
+	file, err := os.Open(name)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&cache.pages); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return cache, nil
+}
+
+func (cache *pageCache) get(link string) *pageInfo {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if info, ok := cache.pages[link]; ok && info.Version == pageInfoVersion && time.Since(info.Fetched) < cache.maxAge {
+		return info
+	}
+
+	return nil
+}
+
+func (cache *pageCache) put(link string, info *pageInfo) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.pages[link] = info
+	cache.dirty = true
+}
+
+// saves the cache, dropping expired entries
+func (cache *pageCache) save() error {
+	if !cache.dirty || len(cache.name) == 0 {
+		return nil
+	}
+
+	for link, info := range cache.pages {
+		if time.Since(info.Fetched) >= cache.maxAge {
+			delete(cache.pages, link)
+		}
+	}
+
+	return writeJSONFile(cache.name, cache.pages)
+}
+
+// fetches information for all web links, using the cache where possible;
+// links that cannot be fetched are reported and skipped
+func (opts *options) fetchPages(roots []*Folder) (map[string]*pageInfo, error) {
+	cache, err := loadPageCache(opts.pageCache, opts.cacheMaxAge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links := webLinks(roots)
+	client := opts.newWebClient()
+
+	logInfo("fetching %d pages", len(links))
+
+	err = forEachLink(links, opts.concurrency, func(link *Link) error {
+		if cache.get(link.URL) != nil {
+			return nil
+		}
+
+		info, err := fetchPage(client, link.URL)
+
+		if err != nil {
+			logWarn("%s: %s", link.URL, err)
+			return nil
+		}
+
+		cache.put(link.URL, info)
+		return nil
+	})
+
+	if e := cache.save(); e != nil && err == nil {
+		err = e
+	}
+
+	return cache.pages, err
+}
+
+// sets fetched page descriptions for links without their own descriptions
+func addDescriptions(roots []*Folder, pages map[string]*pageInfo) {
+	for _, root := range roots {
+		for _, link := range root.allLinks() {
+			if info, ok := pages[link.URL]; ok && len(info.Description) > 0 && len(link.description()) == 0 {
+				if link.Meta == nil {
+					link.Meta = make(map[string]string)
+				}
+
+				link.Meta["Description"] = info.Description
+			}
+		}
+	}
+}
+
+// compares bookmark names with page titles, and replaces the names if requested
+func refreshTitles(roots []*Folder, pages map[string]*pageInfo, fix bool) {
+	for _, root := range roots {
+		for _, link := range root.allLinks() {
+			info, ok := pages[link.URL]
+
+			if !ok || len(info.Title) == 0 || foldSpaces(link.Name) == info.Title {
+				continue
+			}
+
+			if fix {
+				logInfo("%s: %q -> %q", link.URL, link.Name, info.Title)
+				link.Name = info.Title
+			} else {
+				logNotice("%s: title %q does not match bookmark name %q", link.URL, info.Title, link.Name)
+			}
+		}
+	}
+}
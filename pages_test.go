@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePage(t *testing.T) {
+	info := parsePage(strings.NewReader(`<!DOCTYPE html><html><head>
+		<meta charset="utf-8"><title>
+		  Some   &amp; title
+		</title></head><body><title>Wrong</title></body></html>`))
+
+	if info.Title != "Some & title" {
+		t.Fatalf("Unexpected title %q", info.Title)
+	}
+}
+
+func TestFetchPagesCached(t *testing.T) {
+	var hits int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Write([]byte("<html><head><title>\xcf\xf0\xe8\xe2\xe5\xf2</title></head></html>"))
+	}))
+
+	defer srv.Close()
+
+	opts := newOptions()
+	opts.pageCache = filepath.Join(t.TempDir(), "pages.json")
+	opts.refreshTitles = "fix"
+
+	roots := []*Folder{{Links: []*Link{{Node: Node{Name: "Old"}, URL: srv.URL + "/page"}}}}
+
+	for i := 0; i < 2; i++ {
+		pages, err := opts.fetchPages(roots)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refreshTitles(roots, pages, true)
+	}
+
+	if name := roots[0].Links[0].Name; name != "Привет" {
+		t.Fatalf("Unexpected name %q", name)
+	}
+
+	if hits != 1 {
+		t.Fatalf("Page fetched %d times", hits)
+	}
+
+	// expired
+	opts.cacheMaxAge = time.Nanosecond
+
+	if _, err := opts.fetchPages(roots); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits != 2 {
+		t.Fatalf("Page fetched %d times", hits)
+	}
+}
+
+func TestParsePageDescription(t *testing.T) {
+	info := parsePage(strings.NewReader(`<html><head>
+		<meta property="og:description" content="From OpenGraph"/>
+		<meta name="Description" content=" The  page ">
+		</head></html>`))
+
+	if info.Description != "The page" {
+		t.Fatalf("Unexpected description %q", info.Description)
+	}
+
+	info = parsePage(strings.NewReader(`<head><meta property="og:description" content="From OpenGraph"></head>`))
+
+	if info.Description != "From OpenGraph" {
+		t.Fatalf("Unexpected description %q", info.Description)
+	}
+}
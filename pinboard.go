@@ -0,0 +1,246 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerPushTarget("pinboard", "Add bookmarks to Pinboard, with folder names as tags", pushPinboard)
+	readers["pinboard"] = loadPinboard
+}
+
+// Pinboard API endpoint, variable for testing
+var pinboardAPI = "https://api.pinboard.in/v1"
+
+// Pinboard allows one call every 3 seconds
+const pinboardDelay = 3 * time.Second
+
+// "push pinboard" command
+func pushPinboard(args []string) error {
+	fs := newFlagSet("push pinboard", "")
+	opts := newPushOptions(fs)
+
+	var token string
+
+	fs.StringVar(&token, "token", "", "Pinboard API token in the form user:TOKEN (default $PINBOARD_TOKEN)")
+
+	var replace bool
+
+	fs.BoolVar(&replace, "replace", false, "Replace bookmarks already existing in Pinboard")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if err := credential(&token, "token", "PINBOARD_TOKEN"); err != nil {
+		return err
+	}
+
+	links, paths, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	client := opts.newWebClient()
+	ticker := time.NewTicker(pinboardDelay)
+
+	defer ticker.Stop()
+
+	var failed int
+
+	for i, link := range links {
+		if i > 0 {
+			<-ticker.C
+		}
+
+		if err = pinboardAdd(client, token, link, paths[link], replace); err != nil {
+			if _, ok := err.(*pinboardError); !ok {
+				return err
+			}
+
+			logWarn("%s: %s", link.URL, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed to add %d out of %d links to Pinboard", failed, len(links))
+	}
+
+	return nil
+}
+
+// error reported by Pinboard for a single bookmark
+type pinboardError struct {
+	code string
+}
+
+func (e *pinboardError) Error() string {
+	return "Pinboard: " + e.code
+}
+
+// adds one link to Pinboard
+func pinboardAdd(client *webClient, token string, link *Link, path []string, replace bool) error {
+	tags := pathTags(path)
+
+	// Pinboard tags are separated by spaces
+	for i, tag := range tags {
+		tags[i] = strings.Join(strings.Fields(tag), "_")
+	}
+
+	params := url.Values{
+		"auth_token":  {token},
+		"format":      {"json"},
+		"url":         {link.URL},
+		"description": {link.Name},
+		"tags":        {strings.Join(tags, " ")},
+		"dt":          {link.Added.UTC().Format(time.RFC3339)},
+		"replace":     {"no"},
+	}
+
+	if len(link.Name) == 0 {
+		params.Set("description", link.URL) // required parameter
+	}
+
+	if replace {
+		params.Set("replace", "yes")
+	}
+
+	req, err := http.NewRequest("GET", pinboardAPI+"/posts/add?"+params.Encode(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	var res struct {
+		ResultCode string `json:"result_code"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("Invalid response from Pinboard: %s", err)
+	}
+
+	if res.ResultCode != "done" {
+		return &pinboardError{res.ResultCode}
+	}
+
+	logInfo("%s: added to Pinboard", link.URL)
+	return nil
+}
+
+// reads Pinboard JSON backup (as from "posts/all" API call) into "Pinboard" folder
+func loadPinboard(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parsePinboard(bytes.NewReader(src))
+
+	if err != nil {
+		if name == stdin {
+			name = "STDIN"
+		}
+
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return taggedTree("Pinboard", "pinboard", items), nil
+}
+
+func parsePinboard(src io.Reader) ([]taggedLink, error) {
+	var posts []struct {
+		Href        string `json:"href"`
+		Description string `json:"description"` // title
+		Extended    string `json:"extended"`
+		Time        string `json:"time"`
+		Tags        string `json:"tags"` // separated by spaces
+	}
+
+	if err := json.NewDecoder(src).Decode(&posts); err != nil {
+		return nil, err
+	}
+
+	items := make([]taggedLink, 0, len(posts))
+
+	for _, post := range posts {
+		if len(post.Href) == 0 {
+			continue
+		}
+
+		item := taggedLink{
+			title: foldSpaces(post.Description),
+			url:   post.Href,
+			desc:  strings.TrimSpace(post.Extended),
+		}
+
+		if len(post.Time) > 0 {
+			ts, err := time.Parse(time.RFC3339, post.Time)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s: Invalid time %q", post.Href, post.Time)
+			}
+
+			item.added = ts
+		}
+
+		for _, tag := range strings.Fields(post.Tags) {
+			if !contains(item.tags, tag) {
+				item.tags = append(item.tags, tag)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
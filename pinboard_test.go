@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinboardAdd(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		if r.URL.Path != "/posts/add" || q.Get("auth_token") != "user:token" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+
+		if tags := q.Get("tags"); tags != "Bookmarks_bar Dev" {
+			t.Errorf("Unexpected tags: %q", tags)
+		}
+
+		if dt := q.Get("dt"); dt != "2020-01-01T10:00:00Z" {
+			t.Errorf("Unexpected date: %q", dt)
+		}
+
+		if q.Get("url") == "https://example.com/dup" {
+			w.Write([]byte(`{"result_code": "item already exists"}`))
+		} else {
+			w.Write([]byte(`{"result_code": "done"}`))
+		}
+	}))
+
+	defer srv.Close()
+	defer func(api string) { pinboardAPI = api }(pinboardAPI)
+
+	pinboardAPI = srv.URL
+
+	link := &Link{
+		Node: Node{Name: "Example", Added: time.Date(2020, time.January, 1, 10, 0, 0, 0, time.UTC)},
+		URL:  "https://example.com/",
+	}
+
+	path := []string{"Bookmarks bar", "Dev"}
+
+	if err := pinboardAdd(newWebClient(), "user:token", link, path, false); err != nil {
+		t.Fatal(err)
+	}
+
+	link.URL = "https://example.com/dup"
+
+	if _, ok := pinboardAdd(newWebClient(), "user:token", link, path, false).(*pinboardError); !ok {
+		t.Fatal("Missing Pinboard error")
+	}
+}
+
+func TestPinboardImport(t *testing.T) {
+	const src = `[
+		{"href": "https://a.com/", "description": "A", "extended": "About A", "time": "2020-01-02T03:04:05Z",
+			"shared": "yes", "toread": "no", "tags": "go news go"},
+		{"href": "https://b.com/", "description": "B", "extended": "", "time": "2020-01-03T00:00:00Z", "tags": ""}
+	]`
+
+	items, err := parsePinboard(strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := taggedTree("Pinboard", "pinboard", items)
+	lines := linkLines(root)
+
+	sort.Strings(lines)
+
+	exp := []string{
+		"Pinboard/B <https://b.com/>",
+		"Pinboard/go/A <https://a.com/>",
+		"Pinboard/news/A <https://a.com/>",
+	}
+
+	if !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	link := root.Folders[0].Folders[0].Links[0]
+
+	if link.description() != "About A" || !link.Added.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+
+	if _, err = parsePinboard(strings.NewReader(`[{"href": "https://a.com/", "time": "yesterday"}]`)); err == nil {
+		t.Error("Missing error")
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	registerPushTarget("pocket", "Add bookmarks to Pocket, with folder names as tags", pushPocket)
+	readers["pocket"] = loadPocket
+}
+
+// Pocket API endpoint, variable for testing
+var pocketAPI = "https://getpocket.com/v3/send"
+
+// maximum number of actions in one Pocket request
+const pocketBatchSize = 100
+
+// "push pocket" command
+func pushPocket(args []string) error {
+	fs := newFlagSet("push pocket", "")
+	opts := newPushOptions(fs)
+
+	var consumerKey, accessToken string
+
+	fs.StringVar(&consumerKey, "consumer-key", "", "Pocket application consumer key (default $POCKET_CONSUMER_KEY)")
+	fs.StringVar(&accessToken, "access-token", "", "Pocket user access token (default $POCKET_ACCESS_TOKEN)")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if err := credential(&consumerKey, "consumer-key", "POCKET_CONSUMER_KEY"); err != nil {
+		return err
+	}
+
+	if err := credential(&accessToken, "access-token", "POCKET_ACCESS_TOKEN"); err != nil {
+		return err
+	}
+
+	links, paths, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	client := opts.newWebClient()
+
+	for len(links) > 0 {
+		n := len(links)
+
+		if n > pocketBatchSize {
+			n = pocketBatchSize
+		}
+
+		if err = pocketAdd(client, consumerKey, accessToken, links[:n], paths); err != nil {
+			return err
+		}
+
+		links = links[n:]
+	}
+
+	return nil
+}
+
+type pocketAction struct {
+	Action string `json:"action"`
+	URL    string `json:"url"`
+	Title  string `json:"title,omitempty"`
+	Tags   string `json:"tags,omitempty"`
+	Time   int64  `json:"time,omitempty"`
+}
+
+// adds a batch of links to Pocket
+func pocketAdd(client *webClient, consumerKey, accessToken string, links []*Link, paths linkPaths) error {
+	req := struct {
+		ConsumerKey string         `json:"consumer_key"`
+		AccessToken string         `json:"access_token"`
+		Actions     []pocketAction `json:"actions"`
+	}{
+		ConsumerKey: consumerKey,
+		AccessToken: accessToken,
+		Actions:     make([]pocketAction, len(links)),
+	}
+
+	for i, link := range links {
+		req.Actions[i] = pocketAction{
+			Action: "add",
+			URL:    link.URL,
+			Title:  link.Name,
+			Tags:   strings.Join(pathTags(paths[link]), ","),
+			Time:   link.Added.Unix(),
+		}
+	}
+
+	var resp struct {
+		Status        int           `json:"status"`
+		ActionResults []interface{} `json:"action_results"`
+	}
+
+	err := client.postJSON(pocketAPI, http.Header{"X-Accept": {"application/json"}}, &req, &resp)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != 1 {
+		return fmt.Errorf("Pocket request failed with status %d", resp.Status)
+	}
+
+	// failed actions are reported as "false"
+	var failed int
+
+	for i, res := range resp.ActionResults {
+		if ok, isBool := res.(bool); isBool && !ok && i < len(links) {
+			logWarn("%s: not added to Pocket", links[i].URL)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Pocket failed to add %d out of %d links", failed, len(links))
+	}
+
+	logInfo("added %d links to Pocket", len(links))
+	return nil
+}
+
+// reads Pocket export file, either "ril_export.html" or CSV, into "Pocket" folder
+func loadPocket(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	label := name
+
+	if name == stdin {
+		label = "STDIN"
+	}
+
+	var items []taggedLink
+
+	if bytes.HasPrefix(bytes.TrimSpace(src), []byte("<")) {
+		items = parsePocketHTML(bytes.NewReader(src))
+	} else if items, err = parsePocketCSV(bytes.NewReader(src)); err != nil {
+		return nil, fmt.Errorf("%s: %s", label, err)
+	}
+
+	return taggedTree("Pocket", "pocket", items), nil
+}
+
+// links of ril_export.html, like <a href="URL" time_added="1600000000" tags="a,b">TITLE</a>
+func parsePocketHTML(src io.Reader) (items []taggedLink) {
+	z := html.NewTokenizer(src)
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+
+			if string(name) != "a" {
+				continue
+			}
+
+			var item taggedLink
+
+			for hasAttr {
+				var k, v []byte
+
+				k, v, hasAttr = z.TagAttr()
+
+				switch string(k) {
+				case "href":
+					item.url = string(v)
+				case "time_added":
+					item.added = unixTime(string(v))
+				case "tags":
+					item.tags = splitTags(string(v), ",")
+				}
+			}
+
+			var title bytes.Buffer
+
+			for z.Next() == html.TextToken {
+				title.Write(z.Text())
+			}
+
+			if item.title = foldSpaces(title.String()); len(item.url) > 0 {
+				items = append(items, item)
+			}
+		}
+	}
+}
+
+// links of CSV export with "title", "url", "time_added" and "tags" columns, tags separated by "|"
+func parsePocketCSV(src io.Reader) (items []taggedLink, err error) {
+	err = readCSVTable(src, "url", func(field func(string) string) {
+		items = append(items, taggedLink{
+			title: foldSpaces(field("title")),
+			url:   field("url"),
+			added: unixTime(field("time_added")),
+			tags:  splitTags(field("tags"), "|"),
+		})
+	})
+
+	return
+}
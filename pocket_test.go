@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPocketAdd(t *testing.T) {
+	var got struct {
+		ConsumerKey string         `json:"consumer_key"`
+		Actions     []pocketAction `json:"actions"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Accept") != "application/json" {
+			t.Error("Missing X-Accept header")
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+
+		w.Write([]byte(`{"status": 1, "action_results": [{"item_id": "1"}, false]}`))
+	}))
+
+	defer srv.Close()
+	defer func(api string) { pocketAPI = api }(pocketAPI)
+
+	pocketAPI = srv.URL
+
+	added := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	links := []*Link{
+		{Node: Node{Name: "One", Added: added}, URL: "https://example.com/1"},
+		{Node: Node{Name: "Two", Added: added}, URL: "https://example.com/2"},
+	}
+
+	paths := linkPaths{
+		links[0]: {"Bookmarks bar", "News, Politics"},
+	}
+
+	err := pocketAdd(newWebClient(), "key", "token", links, paths)
+
+	if err == nil {
+		t.Fatal("Failed action is not reported")
+	}
+
+	if got.ConsumerKey != "key" || len(got.Actions) != 2 {
+		t.Fatalf("Unexpected request: %+v", got)
+	}
+
+	exp := pocketAction{"add", "https://example.com/1", "One", "Bookmarks bar,News  Politics", added.Unix()}
+
+	if got.Actions[0] != exp {
+		t.Fatalf("Unexpected action: %+v", got.Actions[0])
+	}
+}
+
+func TestPocketImport(t *testing.T) {
+	const page = `<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://a.com/" time_added="1600000000" tags="go,news">A &amp; B</a></li>
+<li><a href="https://b.com/" time_added="1600000001" tags="">https://b.com/</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://c.com/" time_added="1600000002" tags="Go">C</a></li>
+</ul>
+</body></html>`
+
+	const table = "title,url,time_added,tags,status\n" +
+		"A & B,https://a.com/,1600000000,go|news,unread\n" +
+		"https://b.com/,https://b.com/,1600000001,,unread\n" +
+		"C,https://c.com/,1600000002,Go,archive\n"
+
+	exp := []string{
+		"Pocket/Go/C <https://c.com/>",
+		"Pocket/go/A & B <https://a.com/>",
+		"Pocket/https://b.com/ <https://b.com/>",
+		"Pocket/news/A & B <https://a.com/>",
+	}
+
+	for _, src := range []string{page, table} {
+		items := parsePocketHTML(strings.NewReader(src))
+
+		if !strings.HasPrefix(src, "<") {
+			var err error
+
+			if items, err = parsePocketCSV(strings.NewReader(src)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		root := taggedTree("Pocket", "pocket", items)
+		lines := linkLines(root)
+
+		sort.Strings(lines)
+
+		if !reflect.DeepEqual(lines, exp) {
+			t.Errorf("Unexpected links: %q", lines)
+		}
+
+		if f, err := root.Find("Pocket/news"); err != nil || f.Links[0].Added.Unix() != 1600000000 {
+			t.Errorf("Unexpected folder: %v, %v", f, err)
+		}
+	}
+
+	if _, err := parsePocketCSV(strings.NewReader("title,link\n")); err == nil {
+		t.Error("Missing error")
+	}
+}
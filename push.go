@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/juju/gnuflag"
+)
+
+// push targets, invoked as "push TARGET [OPTIONS]"
+var pushTargets = make(commandMap)
+
+func registerPushTarget(name, about string, run func([]string) error) {
+	pushTargets[name] = &command{about, run}
+}
+
+func init() {
+	registerCommand("push", "Upload bookmarks to an online service (\"push TARGET --help\" for details)", pushCmd)
+}
+
+// "push" command
+func pushCmd(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		os.Stderr.WriteString("Usage: " + programName + " push TARGET [OPTIONS]\n\nTargets:\n")
+		pushTargets.list(os.Stderr)
+		return errors.New("Missing push target")
+	}
+
+	target, ok := pushTargets[args[0]]
+
+	if !ok {
+		return fmt.Errorf("Unknown push target %q", args[0])
+	}
+
+	return target.run(args[1:])
+}
+
+// options common to all push targets
+type pushOptions struct {
+	*options
+	folders stringList
+}
+
+func newPushOptions(fs *gnuflag.FlagSet) *pushOptions {
+	opts := &pushOptions{options: newOptions()}
+
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+	opts.networkFlags(fs)
+
+	fs.Var(&opts.folders, "folder", "Folder path like \"Bookmarks bar/News\" to upload (may be repeated; default is all folders)")
+	return opts
+}
+
+// reads the input and returns either the chosen folders, or the roots if none chosen
+func (opts *pushOptions) selectFolders() ([]*Folder, error) {
+	roots, err := opts.loadInputs()
+
+	if err != nil || len(opts.folders) == 0 {
+		return roots, err
+	}
+
+	folders := make([]*Folder, len(opts.folders))
+
+	for i, path := range opts.folders {
+		if folders[i], err = findFolder(roots, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return folders, nil
+}
+
+// links to push, with folder paths
+type linkPaths map[*Link][]string
+
+// reads the input and selects unique web links from the chosen folders
+func (opts *pushOptions) links() ([]*Link, linkPaths, error) {
+	folders, err := opts.selectFolders()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// collect links with their paths
+	var all []*Link
+
+	paths := make(linkPaths)
+
+	for _, folder := range folders {
+		// roots have no useful name, selected folders do
+		var path []string
+
+		if len(opts.folders) > 0 {
+			path = []string{folder.Name}
+		}
+
+		folder.walkLinks(path, func(p []string, link *Link) error {
+			paths[link] = p
+			all = append(all, link)
+			return nil
+		})
+	}
+
+	links := uniqueWebLinks(all)
+
+	logInfo("%d links to push", len(links))
+	return links, paths, nil
+}
+
+// converts folder names to tags
+func pathTags(path []string) []string {
+	tags := make([]string, 0, len(path))
+
+	for _, name := range path {
+		if tag := strings.TrimSpace(strings.Replace(name, ",", " ", -1)); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// takes the value from the environment if not set from the command line
+func credential(value *string, flag, env string) error {
+	if len(*value) == 0 {
+		if *value = os.Getenv(env); len(*value) == 0 {
+			return fmt.Errorf("Missing --%s option or %s environment variable", flag, env)
+		}
+	}
+
+	return nil
+}
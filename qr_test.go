@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQRSVG(t *testing.T) {
+	s := qrSVG("https://go.dev/")
+
+	// version 1 code is 21 modules wide, plus the quiet zone
+	if !strings.HasPrefix(s, "<svg ") || !strings.Contains(s, `viewBox="0 0 29 29"`) {
+		t.Fatalf("Unexpected SVG: %s", s)
+	}
+
+	// finder pattern corner
+	if !strings.Contains(s, `d="M4 4h1v1h-1zM5 4h1v1h-1z`) {
+		t.Fatalf("Unexpected path: %s", s)
+	}
+
+	if qrSVG(strings.Repeat("x", 5000)) != "" {
+		t.Fatal("Oversized text encoded")
+	}
+}
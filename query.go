@@ -0,0 +1,512 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// query language of --query option:
+//
+//	query  = [expr] {"|" stage}
+//	stage  = "sort" ["-"]FIELD | "select" FIELD {"," FIELD}
+//	expr   = and {"||" and}
+//	and    = unary {"&&" unary}
+//	unary  = "!" unary | "(" expr ")" | FIELD op value
+//	op     = "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "!~"
+//
+// values are either quoted strings, or bare words like 2024-01-01, 10 or github.com
+type query struct {
+	match  func(*queryLink) bool // nil if there is no filter
+	order  *sortOrder
+	fields []string // projection
+}
+
+// link with its folder path, as seen by the query
+type queryLink struct {
+	*Link
+	path []string
+}
+
+// types of query fields
+const (
+	fieldString = iota
+	fieldNumber
+	fieldTime
+)
+
+type queryField struct {
+	kind int
+	get  func(*queryLink) interface{}
+}
+
+var queryFields = map[string]queryField{
+	"name":        {fieldString, func(l *queryLink) interface{} { return l.Name }},
+	"url":         {fieldString, func(l *queryLink) interface{} { return l.URL }},
+	"host":        {fieldString, func(l *queryLink) interface{} { return linkHost(l.URL) }},
+	"folder":      {fieldString, func(l *queryLink) interface{} { return strings.Join(l.path, "/") }},
+	"description": {fieldString, func(l *queryLink) interface{} { return l.description() }},
+	"added":       {fieldTime, func(l *queryLink) interface{} { return l.Added }},
+	"visits":      {fieldNumber, func(l *queryLink) interface{} { return l.Visits }},
+	"last_visit":  {fieldTime, func(l *queryLink) interface{} { return l.LastVisit }},
+}
+
+func queryFieldNames() string {
+	names := make([]string, 0, len(queryFields))
+
+	for name := range queryFields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// field value as string, like in CSV output
+func (f queryField) format(l *queryLink) string {
+	switch v := f.get(l).(type) {
+	case time.Time:
+		return formatTime(v)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return v.(string)
+	}
+}
+
+func parseQuery(s, lang string) (*query, error) {
+	tokens, err := tokenizeQuery(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	q := new(query)
+
+	if p.peek() != "|" && len(tokens) > 0 {
+		if q.match, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(p.tokens) > 0 {
+		if err = p.expect("|"); err != nil {
+			return nil, err
+		}
+
+		switch stage := p.next(); stage {
+		case "sort":
+			key := p.next()
+
+			if q.order, err = querySortOrder(key, lang); err != nil {
+				return nil, err
+			}
+		case "select":
+			for {
+				name := p.next()
+
+				if _, ok := queryFields[name]; !ok {
+					return nil, fmt.Errorf("Unknown query field %q", name)
+				}
+
+				q.fields = append(q.fields, name)
+
+				if p.peek() != "," {
+					break
+				}
+
+				p.next()
+			}
+		default:
+			return nil, fmt.Errorf("Unknown query stage %q", stage)
+		}
+	}
+
+	return q, nil
+}
+
+// sort order by any query field, like "-added", with text compared by the collation rules of the language
+func querySortOrder(key, lang string) (*sortOrder, error) {
+	name := strings.TrimPrefix(key, "-")
+	f, ok := queryFields[name]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown sort key %q", key)
+	}
+
+	names, err := nameOrder(lang)
+
+	if err != nil {
+		return nil, err
+	}
+
+	less := func(a, b *Link) bool {
+		x, y := f.get(&queryLink{Link: a}), f.get(&queryLink{Link: b})
+
+		switch v := x.(type) {
+		case time.Time:
+			return v.Before(y.(time.Time))
+		case int:
+			return v < y.(int)
+		default:
+			return names(v.(string), y.(string))
+		}
+	}
+
+	return &sortOrder{key: name, less: less, names: names, desc: strings.HasPrefix(key, "-")}, nil
+}
+
+// applies the query filter and sort order to the tree
+func (q *query) apply(root *Folder) {
+	if q.match != nil {
+		paths := make(map[*Link][]string)
+
+		root.walkLinks(nil, func(path []string, link *Link) error {
+			paths[link] = path
+			return nil
+		})
+
+		filterLinks(root, func(link *Link) bool { return q.match(&queryLink{link, paths[link]}) })
+	}
+
+	if q.order != nil {
+		q.order.apply(root)
+	}
+}
+
+// query tokens
+var queryOperators = []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "(", ")", "|", ","}
+
+func tokenizeQuery(s string) (tokens []string, err error) {
+	for s = strings.TrimSpace(s); len(s) > 0; s = strings.TrimSpace(s) {
+		tok := ""
+
+		for _, op := range queryOperators {
+			if strings.HasPrefix(s, op) {
+				tok = op
+				break
+			}
+		}
+
+		switch {
+		case len(tok) > 0:
+		case s[0] == '"':
+			i := 1
+
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' {
+					i++
+				}
+
+				i++
+			}
+
+			if i >= len(s) {
+				return nil, fmt.Errorf("Unterminated string in query: %s", s)
+			}
+
+			if tok = s[:i+1]; !isQuoted(tok) {
+				return nil, fmt.Errorf("Invalid string in query: %s", tok)
+			}
+		default:
+			i := strings.IndexFunc(s, func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune("=!<>&|(),\"", r)
+			})
+
+			if i < 0 {
+				i = len(s)
+			} else if i == 0 {
+				return nil, fmt.Errorf("Invalid character in query: %s", s)
+			}
+
+			tok = s[:i]
+		}
+
+		tokens = append(tokens, tok)
+		s = s[len(tok):]
+	}
+
+	return
+}
+
+func isQuoted(s string) bool {
+	_, err := strconv.Unquote(s)
+
+	return len(s) > 1 && s[0] == '"' && err == nil
+}
+
+// recursive descent parser
+type queryParser struct {
+	tokens []string
+}
+
+func (p *queryParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+
+	return p.tokens[0]
+}
+
+func (p *queryParser) next() string {
+	tok := p.peek()
+
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+
+	return tok
+}
+
+func (p *queryParser) expect(tok string) error {
+	if s := p.next(); s != tok {
+		if len(s) == 0 {
+			return fmt.Errorf("Unexpected end of query, expected %q", tok)
+		}
+
+		return fmt.Errorf("Unexpected %q in query, expected %q", s, tok)
+	}
+
+	return nil
+}
+
+type queryFunc func(*queryLink) bool
+
+func (p *queryParser) expr() (queryFunc, error) {
+	left, err := p.and()
+
+	for err == nil && p.peek() == "||" {
+		p.next()
+
+		var right queryFunc
+
+		if right, err = p.and(); err == nil {
+			a := left
+			left = func(l *queryLink) bool { return a(l) || right(l) }
+		}
+	}
+
+	return left, err
+}
+
+func (p *queryParser) and() (queryFunc, error) {
+	left, err := p.unary()
+
+	for err == nil && p.peek() == "&&" {
+		p.next()
+
+		var right queryFunc
+
+		if right, err = p.unary(); err == nil {
+			a := left
+			left = func(l *queryLink) bool { return a(l) && right(l) }
+		}
+	}
+
+	return left, err
+}
+
+func (p *queryParser) unary() (queryFunc, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+
+		f, err := p.unary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(l *queryLink) bool { return !f(l) }, nil
+	case "(":
+		p.next()
+
+		f, err := p.expr()
+
+		if err == nil {
+			err = p.expect(")")
+		}
+
+		return f, err
+	}
+
+	return p.comparison()
+}
+
+func (p *queryParser) comparison() (queryFunc, error) {
+	name := p.next()
+	field, ok := queryFields[name]
+
+	if !ok {
+		if len(name) == 0 {
+			return nil, errors.New("Unexpected end of query")
+		}
+
+		return nil, fmt.Errorf("Unknown query field %q (expected one of: %s)", name, queryFieldNames())
+	}
+
+	op := p.next()
+
+	if !contains([]string{"==", "!=", "<", "<=", ">", ">=", "=~", "!~"}, op) {
+		return nil, fmt.Errorf("Invalid query operator %q after %q", op, name)
+	}
+
+	value := p.next()
+
+	if len(value) == 0 || contains(queryOperators, value) {
+		return nil, fmt.Errorf("Missing value after %q", name+" "+op)
+	}
+
+	if isQuoted(value) {
+		value, _ = strconv.Unquote(value)
+	}
+
+	// regular expressions
+	if op == "=~" || op == "!~" {
+		if field.kind != fieldString {
+			return nil, fmt.Errorf("Operator %q is only valid for text fields", op)
+		}
+
+		re, err := regexp.Compile(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression in query: %s", err)
+		}
+
+		return func(l *queryLink) bool { return re.MatchString(field.get(l).(string)) == (op == "=~") }, nil
+	}
+
+	// comparisons
+	var cmp func(*queryLink) int
+
+	switch field.kind {
+	case fieldString:
+		cmp = func(l *queryLink) int { return strings.Compare(field.get(l).(string), value) }
+	case fieldNumber:
+		n, err := strconv.Atoi(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q in query", value)
+		}
+
+		cmp = func(l *queryLink) int { return field.get(l).(int) - n }
+	case fieldTime:
+		ts, err := parseQueryTime(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cmp = func(l *queryLink) int {
+			switch t := field.get(l).(time.Time); {
+			case t.Before(ts):
+				return -1
+			case t.After(ts):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return func(l *queryLink) bool {
+		switch c := cmp(l); op {
+		case "==":
+			return c == 0
+		case "!=":
+			return c != 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}, nil
+}
+
+// date like 2024-01-01 in local time, or RFC3339 time
+func parseQueryTime(s string) (time.Time, error) {
+	if ts, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return ts, nil
+	}
+
+	if ts, err := time.Parse(time.RFC3339, s); err == nil {
+		return ts, nil
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid date %q in query", s)
+}
+
+// projected fields of the link as a JSON object, keeping the order of the fields
+func (q *query) linkJSON(l *queryLink) ([]byte, error) {
+	var buff bytes.Buffer
+
+	enc := json.NewEncoder(&buff)
+
+	enc.SetEscapeHTML(false)
+	buff.WriteByte('{')
+
+	for i, name := range q.fields {
+		var v interface{}
+
+		switch v = queryFields[name].get(l); t := v.(type) {
+		case time.Time:
+			v = formatTime(t)
+		}
+
+		if i > 0 {
+			buff.WriteByte(',')
+		}
+
+		buff.WriteString(strconv.Quote(name) + ":")
+
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+
+		// the encoder appends a newline
+		buff.Truncate(buff.Len() - 1)
+	}
+
+	buff.WriteString("}\n")
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		ts, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return ts
+	}
+
+	makeRoot := func() *Folder {
+		news := &Folder{Node: Node{Name: "News"}, Links: []*Link{
+			{Node: Node{Name: "BBC", Added: day("2023-05-01")}, URL: "https://www.bbc.co.uk/news"},
+			{Node: Node{Name: "Go issues", Added: day("2024-03-01")}, URL: "https://github.com/golang/go/issues", Visits: 3},
+		}}
+
+		return &Folder{Folders: []*Folder{{Node: Node{Name: "Bar"}, Folders: []*Folder{news}, Links: []*Link{
+			{Node: Node{Name: "gnuflag", Added: day("2024-01-15")}, URL: "https://github.com/juju/gnuflag", Visits: 10},
+			{Node: Node{Name: "Go", Added: day("2023-12-31")}, URL: "https://go.dev/"},
+		}}}}
+	}
+
+	tests := map[string]string{
+		`added > 2024-01-01 && host == "github.com"`:              "gnuflag Go issues",
+		`added >= 2024-01-01 && host == github.com | sort -added`: "gnuflag Go issues",
+		`!(host == github.com) || visits >= 10`:                   "gnuflag Go BBC",
+		`name =~ "^go" | sort name`:                               "",
+		`name =~ "(?i)^go" | sort name`:                           "Go Go issues",
+		`folder == "Bar/News" && name !~ BBC`:                     "Go issues",
+		`| sort -visits`:                                          "gnuflag Go Go issues BBC",
+		`description == ""`:                                       "gnuflag Go BBC Go issues",
+	}
+
+	for s, exp := range tests {
+		q, err := parseQuery(s, "")
+
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+
+		root := makeRoot()
+		q.apply(root)
+
+		var names []string
+
+		root.walkLinks(nil, func(_ []string, link *Link) error {
+			names = append(names, link.Name)
+			return nil
+		})
+
+		// "Go issues" is in a sub-folder, so it comes after the links in "Bar"
+		if res := strings.Join(names, " "); res != exp {
+			t.Errorf("%s: got %q instead of %q", s, res, exp)
+		}
+	}
+
+	for _, s := range []string{`name`, `name ==`, `size > 1`, `visits > x`, `added < yesterday`, `(name == a`,
+		`name == "a`, `visits =~ 1`, `name =~ "("`, `| order name`, `| select size`, `name == a b`} {
+		if _, err := parseQuery(s, ""); err == nil {
+			t.Errorf("%s: invalid query accepted", s)
+		}
+	}
+
+	// projection
+	opts := newOptions()
+	opts.format = "csv"
+	opts.query = `visits > 0 | select name,host,visits`
+
+	root := makeRoot()
+
+	if err := opts.transform([]*Folder{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+
+	if err := foldersToCSV(root.Folders, opts, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buff.String(); s != "name,host,visits\ngnuflag,github.com,10\nGo issues,github.com,3\n" {
+		t.Errorf("Unexpected CSV: %q", s)
+	}
+
+	buff.Reset()
+
+	if err := foldersToJSONLines(root.Folders, opts, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buff.String(); s != `{"name":"gnuflag","host":"github.com","visits":10}`+"\n"+`{"name":"Go issues","host":"github.com","visits":3}`+"\n" {
+		t.Errorf("Unexpected JSON: %q", s)
+	}
+
+	opts.format = "html"
+
+	if err := opts.transform([]*Folder{makeRoot()}); err == nil {
+		t.Error("Projection accepted with HTML output")
+	}
+}
+
+func TestQuerySortFolders(t *testing.T) {
+	folder := func(name string, links ...string) *Folder {
+		f := &Folder{Node: Node{Name: name}}
+
+		for _, s := range links {
+			f.Links = append(f.Links, &Link{Node: Node{Name: s}, URL: "https://example.com/" + s})
+		}
+
+		return f
+	}
+
+	bar := folder("Bar", "zebra", "Äpfel", "apple")
+	bar.Folders = []*Folder{folder("Zeta", "b", "a"), folder("alpha"), folder("Äther")}
+
+	opts := newOptions()
+	opts.collate = "de"
+	opts.query = "| sort name"
+
+	if err := opts.transform([]*Folder{{Folders: []*Folder{bar}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+
+	for _, f := range bar.Folders {
+		names = append(names, f.Name)
+	}
+
+	for _, link := range append(bar.Links, bar.Folders[2].Links...) {
+		names = append(names, link.Name)
+	}
+
+	if res := strings.Join(names, " "); res != "alpha Äther Zeta Äpfel apple zebra a b" {
+		t.Errorf("Unexpected order: %q", res)
+	}
+}
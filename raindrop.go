@@ -0,0 +1,322 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerPushTarget("raindrop", "Add bookmarks to Raindrop.io, creating collections for folders", pushRaindrop)
+	readers["raindrop"] = loadRaindrop
+}
+
+// Raindrop.io API endpoint, variable for testing
+var raindropAPI = "https://api.raindrop.io/rest/v1"
+
+// maximum number of raindrops in one request
+const raindropBatchSize = 100
+
+// "push raindrop" command
+func pushRaindrop(args []string) error {
+	fs := newFlagSet("push raindrop", "")
+	opts := newPushOptions(fs)
+
+	var token string
+
+	fs.StringVar(&token, "token", "", "Raindrop.io access token (default $RAINDROP_TOKEN)")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if err := credential(&token, "token", "RAINDROP_TOKEN"); err != nil {
+		return err
+	}
+
+	folders, err := opts.selectFolders()
+
+	if err != nil {
+		return err
+	}
+
+	rd := &raindrop{
+		client: opts.newWebClient(),
+		header: http.Header{"Authorization": {"Bearer " + token}},
+		seen:   make(map[string]bool),
+	}
+
+	if err = rd.loadCollections(); err != nil {
+		return err
+	}
+
+	// chosen folders become top-level collections, roots are not mirrored
+	for _, folder := range folders {
+		if len(opts.folders) > 0 {
+			err = rd.pushFolder(folder, 0)
+		} else {
+			for _, f := range folder.Folders {
+				if err = rd.pushFolder(f, 0); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Raindrop.io uploader
+type raindrop struct {
+	client      *webClient
+	header      http.Header
+	collections map[string]int // "parent id/title" -> id
+	seen        map[string]bool
+}
+
+type raindropRef struct {
+	ID int `json:"$id"`
+}
+
+type raindropCollection struct {
+	ID     int          `json:"_id,omitempty"`
+	Title  string       `json:"title"`
+	Parent *raindropRef `json:"parent,omitempty"`
+}
+
+type raindropItem struct {
+	Link       string      `json:"link"`
+	Title      string      `json:"title,omitempty"`
+	Created    string      `json:"created,omitempty"`
+	Collection raindropRef `json:"collection"`
+}
+
+func collectionKey(parent int, title string) string {
+	return strconv.Itoa(parent) + "/" + title
+}
+
+// reads existing collections, so that they are reused rather than duplicated
+func (rd *raindrop) loadCollections() error {
+	rd.collections = make(map[string]int)
+
+	for _, path := range []string{"/collections", "/collections/childrens"} {
+		req, err := http.NewRequest("GET", raindropAPI+path, nil)
+
+		if err != nil {
+			return err
+		}
+
+		for k, v := range rd.header {
+			req.Header[k] = v
+		}
+
+		resp, err := rd.client.do(req)
+
+		if err != nil {
+			return err
+		}
+
+		var res struct {
+			Items []raindropCollection `json:"items"`
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+
+		if err != nil {
+			return fmt.Errorf("Invalid response from Raindrop.io: %s", err)
+		}
+
+		for _, c := range res.Items {
+			parent := 0
+
+			if c.Parent != nil {
+				parent = c.Parent.ID
+			}
+
+			rd.collections[collectionKey(parent, c.Title)] = c.ID
+		}
+	}
+
+	return nil
+}
+
+// returns id of the collection with the given title, creating the collection if needed
+func (rd *raindrop) collection(parent int, title string) (int, error) {
+	key := collectionKey(parent, title)
+
+	if id, ok := rd.collections[key]; ok {
+		return id, nil
+	}
+
+	req := raindropCollection{Title: title}
+
+	if parent != 0 {
+		req.Parent = &raindropRef{parent}
+	}
+
+	var res struct {
+		Item raindropCollection `json:"item"`
+	}
+
+	if err := rd.client.postJSON(raindropAPI+"/collection", rd.header, &req, &res); err != nil {
+		return 0, err
+	}
+
+	if res.Item.ID == 0 {
+		return 0, fmt.Errorf("Raindrop.io has not created collection %q", title)
+	}
+
+	logInfo("created collection %q", title)
+
+	rd.collections[key] = res.Item.ID
+	return res.Item.ID, nil
+}
+
+// uploads the folder as a collection, recursively
+func (rd *raindrop) pushFolder(folder *Folder, parent int) error {
+	id, err := rd.collection(parent, folder.Name)
+
+	if err != nil {
+		return err
+	}
+
+	// links
+	var items []raindropItem
+
+	for _, link := range folder.Links {
+		if !rd.seen[link.URL] && isWebURL(link.URL) {
+			rd.seen[link.URL] = true
+			items = append(items, raindropItem{
+				Link:       link.URL,
+				Title:      link.Name,
+				Created:    link.Added.UTC().Format(time.RFC3339),
+				Collection: raindropRef{id},
+			})
+		}
+	}
+
+	for len(items) > 0 {
+		n := len(items)
+
+		if n > raindropBatchSize {
+			n = raindropBatchSize
+		}
+
+		req := struct {
+			Items []raindropItem `json:"items"`
+		}{items[:n]}
+
+		if err = rd.client.postJSON(raindropAPI+"/raindrops", rd.header, &req, nil); err != nil {
+			return err
+		}
+
+		logInfo("%s: added %d links", folder.Name, n)
+		items = items[n:]
+	}
+
+	// sub-folders
+	for _, f := range folder.Folders {
+		if err = rd.pushFolder(f, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reads Raindrop.io backup, either HTML or CSV, into "Raindrop" folder, with a sub-folder per collection,
+// and a sub-folder of the collection per tag
+func loadRaindrop(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var items []taggedLink
+
+	if bytes.HasPrefix(bytes.TrimSpace(src), []byte("<")) {
+		items = parseNetscapeHTML(bytes.NewReader(src))
+	} else if items, err = parseRaindropCSV(bytes.NewReader(src)); err != nil {
+		if name == stdin {
+			name = "STDIN"
+		}
+
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return taggedTree("Raindrop", "raindrop", items), nil
+}
+
+// links of CSV backup with "title", "note", "excerpt", "url", "folder" (like "Work/Go"),
+// "tags" (comma separated) and "created" columns
+func parseRaindropCSV(src io.Reader) (items []taggedLink, err error) {
+	err = readCSVTable(src, "url", func(field func(string) string) {
+		item := taggedLink{
+			title: foldSpaces(field("title")),
+			url:   field("url"),
+			desc:  strings.TrimSpace(field("note")),
+			tags:  splitTags(field("tags"), ","),
+		}
+
+		if len(item.desc) == 0 {
+			item.desc = strings.TrimSpace(field("excerpt"))
+		}
+
+		if ts, err := time.Parse(time.RFC3339, field("created")); err == nil {
+			item.added = ts
+		}
+
+		for _, s := range strings.Split(field("folder"), "/") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				item.path = append(item.path, s)
+			}
+		}
+
+		items = append(items, item)
+	})
+
+	return
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRaindropPushFolder(t *testing.T) {
+	var created []raindropCollection
+	var items []raindropItem
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("Missing authorization")
+		}
+
+		switch r.URL.Path {
+		case "/collections":
+			w.Write([]byte(`{"items": [{"_id": 10, "title": "Dev"}]}`))
+		case "/collections/childrens":
+			w.Write([]byte(`{"items": []}`))
+		case "/collection":
+			var c raindropCollection
+
+			json.NewDecoder(r.Body).Decode(&c)
+			created = append(created, c)
+			w.Write([]byte(`{"item": {"_id": 20}}`))
+		case "/raindrops":
+			var req struct{ Items []raindropItem }
+
+			json.NewDecoder(r.Body).Decode(&req)
+			items = append(items, req.Items...)
+			w.Write([]byte(`{"result": true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+	defer func(api string) { raindropAPI = api }(raindropAPI)
+
+	raindropAPI = srv.URL
+
+	rd := &raindrop{
+		client: newWebClient(),
+		header: http.Header{"Authorization": {"Bearer token"}},
+		seen:   make(map[string]bool),
+	}
+
+	if err := rd.loadCollections(); err != nil {
+		t.Fatal(err)
+	}
+
+	folder := &Folder{
+		Node:  Node{Name: "Dev"},
+		Links: []*Link{{URL: "https://go.dev/"}, {URL: "javascript:void(0)"}},
+		Folders: []*Folder{{
+			Node:  Node{Name: "Tools"},
+			Links: []*Link{{URL: "https://go.dev/"}, {URL: "https://github.com/"}},
+		}},
+	}
+
+	if err := rd.pushFolder(folder, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// "Dev" exists, "Tools" is created under it
+	if len(created) != 1 || created[0].Title != "Tools" || created[0].Parent == nil || created[0].Parent.ID != 10 {
+		t.Fatalf("Unexpected collections: %+v", created)
+	}
+
+	if len(items) != 2 || items[0].Collection.ID != 10 || items[1].Link != "https://github.com/" || items[1].Collection.ID != 20 {
+		t.Fatalf("Unexpected items: %+v", items)
+	}
+}
+
+func TestRaindropImport(t *testing.T) {
+	const page = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Raindrop.io Bookmarks</TITLE>
+<H1>Raindrop.io Bookmarks</H1>
+<DL><p>
+	<DT><H3 ADD_DATE="1600000000">Work</H3>
+	<DL><p>
+		<DT><H3>Go</H3>
+		<DL><p>
+			<DT><A HREF="https://go.dev/" ADD_DATE="1600000001" TAGS="lang, docs">Go</A>
+			<DD>The Go site
+		</DL><p>
+		<DT><A HREF="https://a.com/" ADD_DATE="1600000002">A</A>
+	</DL><p>
+	<DT><A HREF="https://b.com/" ADD_DATE="1600000003" TAGS="misc">B</A>
+</DL><p>`
+
+	const table = "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n" +
+		`1,Go,,The Go site,https://go.dev/,Work/Go,"lang, docs",2020-09-13T12:26:41.000Z,,,false` + "\n" +
+		"2,A,,,https://a.com/,Work,,2020-09-13T12:26:42.000Z,,,false\n" +
+		"3,B,,,https://b.com/,,misc,2020-09-13T12:26:43.000Z,,,false\n"
+
+	exp := []string{
+		"Raindrop/Work/A <https://a.com/>",
+		"Raindrop/Work/Go/docs/Go <https://go.dev/>",
+		"Raindrop/Work/Go/lang/Go <https://go.dev/>",
+		"Raindrop/misc/B <https://b.com/>",
+	}
+
+	for _, src := range []string{page, table} {
+		items := parseNetscapeHTML(strings.NewReader(src))
+
+		if !strings.HasPrefix(src, "<") {
+			var err error
+
+			if items, err = parseRaindropCSV(strings.NewReader(src)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		root := taggedTree("Raindrop", "raindrop", items)
+		lines := linkLines(root)
+
+		sort.Strings(lines)
+
+		if !reflect.DeepEqual(lines, exp) {
+			t.Errorf("Unexpected links: %q", lines)
+		}
+
+		f, err := root.Find("Raindrop/Work/Go/lang")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if link := f.Links[0]; link.description() != "The Go site" || link.Added.Unix() != 1600000001 {
+			t.Errorf("Unexpected link: %+v", link)
+		}
+	}
+}
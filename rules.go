@@ -0,0 +1,311 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// rule moving matching links into the target folder
+type linkRule struct {
+	host, url *regexp.Regexp // one of the patterns
+	keyword   string         // lower case
+	target    []string       // folder path from the root folder of the link
+}
+
+func (rule *linkRule) match(link, name string) bool {
+	switch {
+	case rule.host != nil:
+		return rule.host.MatchString(linkHost(link))
+	case rule.url != nil:
+		return rule.url.MatchString(link)
+	default:
+		return strings.Contains(strings.ToLower(name), rule.keyword)
+	}
+}
+
+// target folder of the first rule matching the link, or nil
+func linkTarget(rules []*linkRule, link, name string) []string {
+	for _, rule := range rules {
+		if rule.match(link, name) {
+			return rule.target
+		}
+	}
+
+	return nil
+}
+
+func loadLinkRules(name string) ([]*linkRule, error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	rules, err := parseLinkRules(file)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return rules, nil
+}
+
+// reads "PATTERN -> FOLDER" lines, skipping empty lines and "#" comments; the pattern is either
+// "title:KEYWORD", or URL with "*" matching any characters, or web site like "*.arxiv.org"
+func parseLinkRules(src io.Reader) (rules []*linkRule, err error) {
+	scanner := bufio.NewScanner(src)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.LastIndex(line, "->")
+
+		if i < 0 {
+			return nil, fmt.Errorf("Line %d: Missing \"->\"", n)
+		}
+
+		pattern := strings.TrimSpace(line[:i])
+		rule := new(linkRule)
+
+		for _, s := range strings.Split(line[i+2:], "/") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				rule.target = append(rule.target, s)
+			}
+		}
+
+		switch {
+		case len(pattern) == 0 || len(rule.target) == 0:
+			return nil, fmt.Errorf("Line %d: Empty pattern or folder", n)
+		case strings.HasPrefix(pattern, "title:"):
+			if rule.keyword = strings.ToLower(strings.TrimSpace(pattern[6:])); len(rule.keyword) == 0 {
+				return nil, fmt.Errorf("Line %d: Empty keyword", n)
+			}
+		case strings.Contains(pattern, "/"):
+			rule.url = regexp.MustCompile("^" + globRegexp(pattern) + "$")
+		default:
+			pattern = strings.TrimPrefix(strings.ToLower(pattern), "www.")
+
+			// "*.example.com" also matches "example.com"
+			if strings.HasPrefix(pattern, "*.") {
+				rule.host = regexp.MustCompile(`^(.*\.)?` + globRegexp(pattern[2:]) + "$")
+			} else {
+				rule.host = regexp.MustCompile("^" + globRegexp(pattern) + "$")
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// regular expression with "*" matching any characters
+func globRegexp(s string) string {
+	return strings.Replace(regexp.QuoteMeta(s), `\*`, ".*", -1)
+}
+
+// moves the links matching the rules into their target folders under the root folders
+// (like "Bookmarks bar"), unless they are already there or in a sub-folder of the target
+func applyLinkRules(root *Folder, rules []*linkRule) (moved int) {
+	if root.container {
+		for _, f := range root.Folders {
+			moved += applyLinkRules(f, rules)
+		}
+
+		return
+	}
+
+	targets := make(map[*Link][]string)
+
+	root.Walk(func(path []string, node interface{}) error {
+		if link, ok := node.(*Link); ok {
+			if target := linkTarget(rules, link.URL, link.Name); target != nil && !hasPathPrefix(path, target) {
+				targets[link] = target
+			}
+		}
+
+		return nil
+	})
+
+	if len(targets) == 0 {
+		return
+	}
+
+	var moving []*Link
+	var remove func(*Folder)
+
+	remove = func(folder *Folder) {
+		links := folder.Links[:0]
+
+		for _, link := range folder.Links {
+			if _, ok := targets[link]; ok {
+				moving = append(moving, link)
+			} else {
+				links = append(links, link)
+			}
+		}
+
+		folder.Links = links
+
+		for _, f := range folder.Folders {
+			remove(f)
+		}
+	}
+
+	remove(root)
+
+	for _, link := range moving {
+		f := root.subFolder(targets[link])
+		f.Links = append(f.Links, link)
+	}
+
+	return len(moving)
+}
+
+// moves the links of the raw tree matching the rules into their target folders under the root folders,
+// like applyLinkRules() does; only the links from the folders accepted by the function are moved
+func applyRawLinkRules(b *rawBuilder, roots interface{}, rules []*linkRule, accept func(string) bool) (changes []string) {
+	type move struct {
+		link, parent, root map[string]interface{}
+		path               string // of the root
+		target             []string
+	}
+
+	var moves []move
+	rootOf := make(map[string]map[string]interface{}) // by nodeKey()
+	rootPath := make(map[string]int)                  // path length of the root, by nodeKey()
+
+	// folders are visited before their sub-folders
+	walkRawFolders(roots, func(path []string, node map[string]interface{}) {
+		root, ok := rootOf[nodeKey(node)]
+
+		if !ok {
+			root = node
+			rootPath[nodeKey(node)] = len(path)
+		}
+
+		rel := path[rootPath[nodeKey(root)]:]
+		children, _ := node["children"].([]interface{})
+
+		for _, child := range children {
+			c, ok := child.(map[string]interface{})
+
+			if !ok {
+				continue
+			}
+
+			if rawString(c, "type") == "folder" {
+				rootOf[nodeKey(c)] = root
+			} else if target := linkTarget(rules, rawString(c, "url"), rawString(c, "name")); target != nil &&
+				!hasPathPrefix(rel, target) && accept(strings.Join(path, "/")) {
+				p := strings.Join(path[:rootPath[nodeKey(root)]], "/")
+				moves = append(moves, move{c, node, root, p, target})
+			}
+		}
+	})
+
+	moving := make(map[string]bool, len(moves))
+
+	for _, m := range moves {
+		moving[nodeKey(m.link)] = true
+	}
+
+	for _, m := range moves {
+		children, _ := m.parent["children"].([]interface{})
+		res := children[:0]
+
+		for _, child := range children {
+			if !moving[nodeKey(child.(map[string]interface{}))] {
+				res = append(res, child)
+			}
+		}
+
+		if len(res) != len(children) {
+			m.parent["children"] = res
+			m.parent["date_modified"] = b.now
+		}
+	}
+
+	for _, m := range moves {
+		f := m.root
+
+	next:
+		for _, name := range m.target {
+			children, _ := f["children"].([]interface{})
+
+			for _, child := range children {
+				if c, ok := child.(map[string]interface{}); ok && rawString(c, "type") == "folder" && rawString(c, "name") == name {
+					f = c
+					continue next
+				}
+			}
+
+			child := b.newNode("folder", name)
+			child["children"] = []interface{}{}
+			f["children"] = append(children, child)
+			f = child
+		}
+
+		children, _ := f["children"].([]interface{})
+		f["children"] = append(children, m.link)
+		f["date_modified"] = b.now
+
+		changes = append(changes, "moved "+rawString(m.link, "name")+" <"+rawString(m.link, "url")+"> to "+
+			m.path+"/"+strings.Join(m.target, "/"))
+	}
+
+	return
+}
+
+// checks if the path starts with the prefix
+func hasPathPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+
+	for i, s := range prefix {
+		if path[i] != s {
+			return false
+		}
+	}
+
+	return true
+}
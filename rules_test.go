@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testLinkRules = `# papers
+*.arxiv.org -> Papers
+https://github.com/golang/* -> Code / Go
+title:Recipe -> Cooking
+`
+
+func TestParseLinkRules(t *testing.T) {
+	rules, err := parseLinkRules(strings.NewReader(testLinkRules))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, name string
+		exp       []string
+	}{
+		{"https://arxiv.org/abs/1", "", []string{"Papers"}},
+		{"https://export.arxiv.org/abs/1", "", []string{"Papers"}},
+		{"https://notarxiv.org/", "", nil},
+		{"https://github.com/golang/go", "", []string{"Code", "Go"}},
+		{"https://github.com/other/go", "", nil},
+		{"https://example.com/", "Best recipes", []string{"Cooking"}},
+	}
+
+	for _, test := range tests {
+		if target := linkTarget(rules, test.url, test.name); !reflect.DeepEqual(target, test.exp) {
+			t.Errorf("%s: %q instead of %q", test.url, target, test.exp)
+		}
+	}
+
+	for _, s := range []string{"arxiv.org Papers", "-> Papers", "arxiv.org -> /", "title: -> X"} {
+		if _, err := parseLinkRules(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: missing error", s)
+		}
+	}
+}
+
+func TestApplyLinkRules(t *testing.T) {
+	rules, err := parseLinkRules(strings.NewReader(testLinkRules))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	papers := &Folder{Node: Node{Name: "Papers"}, Links: []*Link{
+		{Node: Node{Name: "a"}, URL: "https://arxiv.org/abs/1"},
+	}}
+
+	bar := &Folder{Node: Node{Name: "Bar"}, Folders: []*Folder{papers, {
+		Node: Node{Name: "Misc"},
+		Links: []*Link{
+			{Node: Node{Name: "b"}, URL: "https://arxiv.org/abs/2"},
+			{Node: Node{Name: "c"}, URL: "https://github.com/golang/go"},
+			{Node: Node{Name: "d"}, URL: "https://example.com/"},
+		},
+	}}}
+
+	root := &Folder{Folders: []*Folder{bar}, container: true}
+
+	if n := applyLinkRules(root, rules); n != 2 {
+		t.Errorf("Moved %d links", n)
+	}
+
+	lines := linkLines(root)
+	sort.Strings(lines)
+
+	exp := []string{
+		"Bar/Code/Go/c <https://github.com/golang/go>",
+		"Bar/Misc/d <https://example.com/>",
+		"Bar/Papers/a <https://arxiv.org/abs/1>",
+		"Bar/Papers/b <https://arxiv.org/abs/2>",
+	}
+
+	if !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+}
+
+func TestTidyRules(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0", "children": [
+			{"type": "folder", "name": "Misc", "id": "2", "date_added": "0", "date_modified": "0", "children": [
+				{"type": "url", "name": "b", "url": "https://arxiv.org/abs/2", "id": "3", "date_added": "0"},
+				{"type": "url", "name": "d", "url": "https://example.com/", "id": "4", "date_added": "0"}
+			]},
+			{"type": "url", "name": "c", "url": "https://github.com/golang/go", "id": "5", "date_added": "0"}
+		]}
+	}}`
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	rules := filepath.Join(dir, "rules")
+
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(rules, []byte(testLinkRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tidyCmd([]string{"-q", "-i", name, "--tag-rules", rules, "--folder", "Bar/Misc", "--yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := rawChildren(t, name); !reflect.DeepEqual(res, map[string][]string{
+		"Bar":        {"Misc", "c", "Papers"}, // not sorted
+		"Bar/Misc":   {"d"},
+		"Bar/Papers": {"b"},
+	}) {
+		t.Fatalf("Unexpected result: %v", res)
+	}
+}
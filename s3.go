@@ -0,0 +1,213 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// object storage location from "s3://bucket/key" output name
+type s3Object struct {
+	bucket, key string
+}
+
+func isS3URL(name string) bool {
+	return strings.HasPrefix(name, "s3://")
+}
+
+func parseS3URL(name string) (*s3Object, error) {
+	u, err := url.Parse(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &s3Object{bucket: u.Host, key: strings.TrimPrefix(u.Path, "/")}
+
+	if len(obj.bucket) == 0 || len(obj.key) == 0 || strings.HasSuffix(obj.key, "/") {
+		return nil, fmt.Errorf("Invalid S3 location %q, expected s3://BUCKET/KEY", name)
+	}
+
+	return obj, nil
+}
+
+// credentials and endpoint, taken from the environment the same way AWS tools do it
+type s3Config struct {
+	accessKey, secretKey, token string
+	region, endpoint            string // empty endpoint means AWS
+}
+
+func s3ConfigFromEnv() (*s3Config, error) {
+	cfg := &s3Config{
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		region:    os.Getenv("AWS_REGION"),
+		endpoint:  os.Getenv("AWS_ENDPOINT_URL"),
+	}
+
+	if len(cfg.accessKey) == 0 || len(cfg.secretKey) == 0 {
+		return nil, errors.New("Missing AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY environment variable")
+	}
+
+	if len(cfg.region) == 0 {
+		if cfg.region = os.Getenv("AWS_DEFAULT_REGION"); len(cfg.region) == 0 {
+			cfg.region = "us-east-1"
+		}
+	}
+
+	return cfg, nil
+}
+
+// object URL: virtual-hosted style for AWS, path style for other endpoints
+func (cfg *s3Config) objectURL(obj *s3Object) string {
+	key := s3EscapePath(obj.key)
+
+	if len(cfg.endpoint) > 0 {
+		return strings.TrimSuffix(cfg.endpoint, "/") + "/" + obj.bucket + "/" + key
+	}
+
+	return "https://" + obj.bucket + ".s3." + cfg.region + ".amazonaws.com/" + key
+}
+
+// writes the data to the object storage
+func s3Put(client *webClient, name string, data []byte) error {
+	obj, err := parseS3URL(name)
+
+	if err != nil {
+		return err
+	}
+
+	cfg, err := s3ConfigFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", cfg.objectURL(obj), bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	cfg.sign(req, data, time.Now())
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	logInfo("uploaded %s", name)
+	return resp.Body.Close()
+}
+
+// adds AWS Signature Version 4 to the request, signing the host and all the request headers
+func (cfg *s3Config) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if len(cfg.token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", cfg.token)
+	}
+
+	// canonical request
+	headers := map[string]string{"host": req.URL.Host}
+
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for k := range headers {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	var canonical bytes.Buffer
+
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n")
+
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+
+	signed := strings.Join(names, ";")
+
+	canonical.WriteString("\n" + signed + "\n" + req.Header.Get("X-Amz-Content-Sha256"))
+
+	// string to sign
+	scope := amzDate[:8] + "/" + cfg.region + "/s3/aws4_request"
+	reqSum := sha256.Sum256(canonical.Bytes())
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(reqSum[:])
+
+	// signature
+	key := []byte("AWS4" + cfg.secretKey)
+
+	for _, s := range []string{amzDate[:8], cfg.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cfg.accessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// escapes the object key as required by S3, keeping "/" separators
+func s3EscapePath(key string) string {
+	parts := strings.Split(key, "/")
+
+	for i, p := range parts {
+		parts[i] = strings.Replace(url.QueryEscape(p), "+", "%20", -1)
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,481 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+func init() {
+	registerCommand("search", "Find bookmarks by words in their names and URLs, or in the text of the pages", searchCmd)
+}
+
+// "search" command
+func searchCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("search", "[WORD...]")
+
+	var indexName string
+	var content, update bool
+	var maxAge time.Duration
+
+	opts.inputFlags(fs)
+	fs.BoolVar(&content, "content", false, "Also search the text of the pages, from the index")
+	fs.BoolVar(&update, "update-index", false, "Fetch the pages that are not in the index yet, or indexed too long ago")
+	fs.StringVar(&indexName, "index", defaultContentIndex(),
+		"File with the text of the pages (default location is $XDG_DATA_HOME/"+programName+"/content.json)")
+	fs.DurationVar(&maxAge, "max-age", 30*24*time.Hour, "Maximum age of the indexed text of a page")
+	opts.networkFlags(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 && !update {
+		return errors.New("Nothing to search for")
+	}
+
+	if (content || update) && len(indexName) == 0 {
+		return errors.New("Index location is unknown, please specify --index")
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	var index *contentIndex
+
+	if content || update {
+		if index, err = loadContentIndex(indexName); err != nil {
+			return err
+		}
+	}
+
+	if update {
+		if err = opts.updateIndex(roots, index, maxAge); err != nil {
+			return err
+		}
+	}
+
+	if fs.NArg() == 0 {
+		return nil
+	}
+
+	if !content {
+		index = nil
+	}
+
+	for _, r := range searchLinks(roots, fs.Args(), index) {
+		fmt.Print(menuLine(r.link))
+
+		if len(r.snippet) > 0 {
+			fmt.Println("    " + r.snippet)
+		}
+	}
+
+	return nil
+}
+
+// text of a page, as indexed
+type indexedPage struct {
+	Title   string    `json:"title,omitempty"`
+	Text    string    `json:"text"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// maximum number of bytes of text indexed per page
+const maxIndexedText = 64 * 1024
+
+// text of the bookmarked pages, by URL
+type contentIndex struct {
+	name  string
+	lock  sync.Mutex
+	pages map[string]*indexedPage
+	dirty bool
+}
+
+func defaultContentIndex() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "content.json")
+	}
+
+	return ""
+}
+
+// reads the index, which may not exist yet
+func loadContentIndex(name string) (*contentIndex, error) {
+	index := &contentIndex{name: name, pages: make(map[string]*indexedPage)}
+	file, err := os.Open(name)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&index.pages); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return index, nil
+}
+
+func (index *contentIndex) fresh(link string, maxAge time.Duration) bool {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	page, ok := index.pages[link]
+
+	return ok && time.Since(page.Fetched) < maxAge
+}
+
+func (index *contentIndex) put(link string, page *indexedPage) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	index.pages[link] = page
+	index.dirty = true
+}
+
+func (index *contentIndex) save() error {
+	if !index.dirty {
+		return nil
+	}
+
+	return writeJSONFile(index.name, index.pages)
+}
+
+// fetches the pages missing from the index, and drops the pages no longer bookmarked;
+// pages that cannot be fetched are reported and skipped
+func (opts *options) updateIndex(roots []*Folder, index *contentIndex, maxAge time.Duration) error {
+	links := webLinks(roots)
+	bookmarked := make(map[string]bool, len(links))
+
+	for _, link := range links {
+		bookmarked[link.URL] = true
+	}
+
+	for link := range index.pages {
+		if !bookmarked[link] {
+			delete(index.pages, link)
+			index.dirty = true
+		}
+	}
+
+	client := opts.newWebClient()
+
+	logInfo("indexing %d pages", len(links))
+
+	err := forEachLink(links, opts.concurrency, func(link *Link) error {
+		if index.fresh(link.URL, maxAge) {
+			return nil
+		}
+
+		page, err := fetchPageText(client, link.URL)
+
+		if err != nil {
+			logWarn("%s: %s", link.URL, err)
+			return nil
+		}
+
+		index.put(link.URL, page)
+		return nil
+	})
+
+	if e := index.save(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+func fetchPageText(client *webClient, link string) (*indexedPage, error) {
+	req, err := http.NewRequest("GET", link, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); len(ct) > 0 && !strings.Contains(ct, "html") {
+		return nil, errors.New("Not an HTML page: " + ct)
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), resp.Header.Get("Content-Type"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := pageText(body)
+
+	page.Fetched = time.Now().UTC()
+	return page, nil
+}
+
+// elements without readable text
+var hiddenElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true,
+}
+
+// extracts the title and the visible text of the page
+func pageText(src io.Reader) *indexedPage {
+	page := new(indexedPage)
+	z := html.NewTokenizer(src)
+
+	var text []string
+	var size, hidden int
+
+loop:
+	for size < maxIndexedText {
+		switch z.Next() {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken:
+			name, _ := z.TagName()
+
+			if string(name) == "title" && len(page.Title) == 0 && z.Next() == html.TextToken {
+				page.Title = foldSpaces(string(z.Text()))
+			} else if hiddenElements[string(name)] {
+				hidden++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); hiddenElements[string(name)] && hidden > 0 {
+				hidden--
+			}
+		case html.TextToken:
+			if s := foldSpaces(string(z.Text())); hidden == 0 && len(s) > 0 {
+				text = append(text, s)
+				size += len(s) + 1
+			}
+		}
+	}
+
+	page.Text = strings.Join(text, " ")
+
+	if len(page.Text) > maxIndexedText {
+		page.Text = truncateText(page.Text, maxIndexedText)
+	}
+
+	return page
+}
+
+// cuts the text at a rune boundary
+func truncateText(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+type searchResult struct {
+	link    *Link
+	path    []string
+	snippet string // text of the page around the first word found there
+}
+
+// finds the links with all the words (ignoring case) in the name or URL, or, given the index, in the page text;
+// links found by their names come first
+func searchLinks(roots []*Folder, words []string, index *contentIndex) []searchResult {
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+
+	var byName, byText []searchResult
+
+	for _, root := range roots {
+		root.walkLinks(nil, func(path []string, link *Link) error {
+			r := searchResult{link: link, path: path}
+			found := strings.ToLower(link.Name + " " + link.URL)
+
+			if containsAll(found, words) {
+				byName = append(byName, r)
+				return nil
+			}
+
+			if index == nil {
+				return nil
+			}
+
+			page, ok := index.pages[link.URL]
+
+			if !ok {
+				return nil
+			}
+
+			text := strings.ToLower(page.Title + " " + page.Text)
+
+			if !containsAll(found+" "+text, words) {
+				return nil
+			}
+
+			for _, w := range words {
+				if strings.Contains(text, w) {
+					r.snippet = textSnippet(page.Title+" "+page.Text, text, w)
+					break
+				}
+			}
+
+			byText = append(byText, r)
+			return nil
+		})
+	}
+
+	return append(byName, byText...)
+}
+
+func containsAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// a few words of the text around the first occurrence of the word in its lower case version
+func textSnippet(text, lower, word string) string {
+	// lower casing may change the length of some characters
+	if len(text) != len(lower) {
+		text = lower
+	}
+
+	i := strings.Index(lower, word)
+	from, to := i-60, i+len(word)+100
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to > len(text) {
+		to = len(text)
+	}
+
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	s := text[from:to]
+
+	// whole words only
+	if from > 0 {
+		if j := strings.IndexByte(s, ' '); j >= 0 && j < i-from {
+			s = s[j+1:]
+		}
+
+		s = "…" + s
+	}
+
+	if to < len(text) {
+		if j := strings.LastIndexByte(s, ' '); j > 0 {
+			s = s[:j]
+		}
+
+		s += "…"
+	}
+
+	return s
+}
+
+// result of /search request
+type searchHit struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Folder  string `json:"folder"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// serves /search?q=WORDS, also searching the page text with &content=1
+func (srv *bookmarkServer) search(w http.ResponseWriter, r *http.Request) {
+	words := strings.Fields(r.FormValue("q"))
+
+	if len(words) == 0 {
+		http.Error(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+
+	roots, err := srv.opts.loadInputs()
+
+	var index *contentIndex
+
+	if err == nil && len(r.FormValue("content")) > 0 && len(srv.index) > 0 {
+		index, err = loadContentIndex(srv.index)
+	}
+
+	if err != nil {
+		logWarn("%s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hits := []searchHit{}
+
+	for _, res := range searchLinks(roots, words, index) {
+		hits = append(hits, searchHit{res.link.Name, res.link.URL, strings.Join(res.path, "/"), res.snippet})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err = json.NewEncoder(w).Encode(hits); err != nil {
+		logWarn("%s", err)
+	}
+}
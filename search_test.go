@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageText(t *testing.T) {
+	page := pageText(strings.NewReader(`<html><head><title> The  Title </title><style>p {}</style></head>
+		<body><script>var x = 1;</script><h1>Heading</h1><p>Some   <b>bold</b> text.</p></body></html>`))
+
+	if page.Title != "The Title" || page.Text != "Heading Some bold text." {
+		t.Errorf("Unexpected page: %q, %q", page.Title, page.Text)
+	}
+}
+
+func TestTextSnippet(t *testing.T) {
+	text := strings.Repeat("lorem ipsum ", 20) + "Needle " + strings.Repeat("dolor sit amet ", 20)
+	s := textSnippet(text, strings.ToLower(text), "needle")
+
+	if !strings.HasPrefix(s, "…ipsum") || !strings.HasSuffix(s, "…") || !strings.Contains(s, " Needle dolor ") {
+		t.Errorf("Unexpected snippet %q", s)
+	}
+
+	if s = textSnippet("Short text", "short text", "text"); s != "Short text" {
+		t.Errorf("Unexpected snippet %q", s)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<title>Page %s</title><p>All about %s gardening.</p>", r.URL.Path, strings.Trim(r.URL.Path, "/"))
+	}))
+
+	defer web.Close()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	indexName := filepath.Join(dir, "content.json")
+	data := `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [
+			{"type": "url", "name": "Roses", "url": "` + web.URL + `/roses", "id": "2", "date_added": "0"},
+			{"type": "url", "name": "Tulips", "url": "` + web.URL + `/tulips", "id": "3", "date_added": "0"},
+			{"type": "url", "name": "Gardening", "url": "file:///gardening.txt", "id": "4", "date_added": "0"}
+		]}}}`
+
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := searchCmd([]string{"-q", "--update-index", "--index", indexName, "--retries", "0", "-i", name}); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := loadContentIndex(indexName)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page := index.pages[web.URL+"/tulips"]; page == nil || page.Text != "All about tulips gardening." {
+		t.Fatalf("Unexpected index: %v", index.pages)
+	}
+
+	root, err := loadTree(name, readOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		words []string
+		index *contentIndex
+		exp   string
+	}{
+		{[]string{"GARDENING"}, nil, "Gardening"},
+		{[]string{"gardening"}, index, "Gardening Roses Tulips"},
+		{[]string{"tulips", "gardening"}, index, "Tulips"},
+		{[]string{"roses", "tulips"}, index, ""},
+	}
+
+	for _, test := range tests {
+		var names []string
+
+		for _, r := range searchLinks([]*Folder{root}, test.words, test.index) {
+			names = append(names, r.link.Name)
+		}
+
+		if strings.Join(names, " ") != test.exp {
+			t.Errorf("%q: unexpected result %q", test.words, names)
+		}
+	}
+
+	// /search endpoint
+	opts := newOptions()
+	fs := newFlagSet("serve", "")
+
+	opts.inputFlags(fs)
+
+	if err = opts.parse(fs, []string{"-i", name}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(newBookmarkServer(opts, indexName))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/search?q=roses+gardening&content=1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var hits []searchHit
+
+	if err = json.NewDecoder(resp.Body).Decode(&hits); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hits) != 1 || hits[0].Name != "Roses" || hits[0].Folder != "Bar" || hits[0].Snippet != "Page /roses All about roses gardening." {
+		t.Errorf("Unexpected hits: %+v", hits)
+	}
+}
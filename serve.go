@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerCommand("serve", "Run a web server redirecting short links like /go/NICKNAME to the bookmarks, and searching them", serveCmd)
+}
+
+// "serve" command
+func serveCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("serve", "")
+
+	var addr, index string
+
+	opts.inputFlags(fs)
+	fs.StringVar(&addr, "listen", "localhost:8080", "Address to listen on")
+	fs.StringVar(&index, "index", defaultContentIndex(),
+		"File with the text of the pages, made by \"search --update-index\" (default location is $XDG_DATA_HOME/"+programName+"/content.json)")
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	logNotice("listening on %s", addr)
+
+	return http.ListenAndServe(addr, newBookmarkServer(opts, index))
+}
+
+// web server; the bookmarks are read on every request, so changes made in the browser are seen without restart
+type bookmarkServer struct {
+	opts  *options
+	index string // content index file, for /search
+	mux   *http.ServeMux
+}
+
+func newBookmarkServer(opts *options, index string) *bookmarkServer {
+	srv := &bookmarkServer{opts: opts, index: index, mux: http.NewServeMux()}
+
+	srv.mux.HandleFunc("/go/", srv.goLink)
+	srv.mux.HandleFunc("/search", srv.search)
+	return srv
+}
+
+func (srv *bookmarkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logInfo("%s %s", r.Method, r.URL.Path)
+	srv.mux.ServeHTTP(w, r)
+}
+
+// redirects /go/KEY to the bookmark, or lists all the keys for /go/
+func (srv *bookmarkServer) goLink(w http.ResponseWriter, r *http.Request) {
+	roots, err := srv.opts.loadInputs()
+
+	if err != nil {
+		logWarn("%s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	links := goLinks(roots)
+	key := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/go/"))
+
+	if len(key) == 0 {
+		keys := make([]string, 0, len(links))
+
+		for k := range links {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\n", k, links[k].URL)
+		}
+
+		return
+	}
+
+	if link, ok := links[key]; ok {
+		http.Redirect(w, r, link.URL, http.StatusFound)
+		return
+	}
+
+	http.Error(w, "No bookmark for "+key, http.StatusNotFound)
+}
+
+// short link keys of all the web links: Opera nicknames, bookmark ids, and slugs made of the names;
+// a nickname takes precedence over an id, and an id over a slug, and otherwise the first link wins
+func goLinks(roots []*Folder) map[string]*Link {
+	links := make(map[string]*Link)
+	var all []*Link
+
+	for _, root := range roots {
+		for _, link := range root.allLinks() {
+			if isWebURL(link.URL) {
+				all = append(all, link)
+			}
+		}
+	}
+
+	keys := []func(*Link) string{
+		func(link *Link) string { return strings.ToLower(strings.TrimSpace(link.nickname())) },
+		func(link *Link) string { return link.ID },
+		func(link *Link) string { return goSlug(link.Name) },
+	}
+
+	for _, key := range keys {
+		for _, link := range all {
+			if k := key(link); len(k) > 0 && !strings.Contains(k, "/") && links[k] == nil {
+				links[k] = link
+			}
+		}
+	}
+
+	return links
+}
+
+// lower case letters and digits of the name, with dashes between words
+func goSlug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, "-")
+}
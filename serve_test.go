@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoLinks(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+	data := `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [
+			{"type": "url", "name": "Go Docs!", "url": "https://go.dev/doc/", "id": "2", "date_added": "0"},
+			{"type": "url", "name": "Mail", "url": "https://mail.example.com/", "id": "3", "date_added": "0",
+				"meta_info": {"Nickname": "M"}},
+			{"type": "url", "name": "m", "url": "https://m.example.com/", "id": "4", "date_added": "0"},
+			{"type": "url", "name": "Notes", "url": "file:///notes.txt", "id": "5", "date_added": "0"}
+		]}}}`
+
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := newOptions()
+	fs := newFlagSet("serve", "")
+
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, []string{"-q", "-i", name}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(newBookmarkServer(opts, ""))
+	defer srv.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	tests := []struct{ path, exp string }{
+		{"/go/go-docs", "https://go.dev/doc/"},
+		{"/go/2", "https://go.dev/doc/"},
+		{"/go/m", "https://mail.example.com/"},
+		{"/go/M", "https://mail.example.com/"},
+		{"/go/4", "https://m.example.com/"},
+	}
+
+	for _, test := range tests {
+		resp, err := client.Get(srv.URL + test.path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != test.exp {
+			t.Errorf("%s: unexpected response %d %q", test.path, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+
+	resp, err := client.Get(srv.URL + "/go/notes")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Body.Close(); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+
+	if resp, err = client.Get(srv.URL + "/go/"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil || !strings.Contains(string(body), "go-docs\thttps://go.dev/doc/\n") {
+		t.Errorf("Unexpected listing %q, %v", body, err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+func init() {
+	readers["session"] = loadSession
+}
+
+// Session Buddy export: either saved sessions with windows, or collections with folders (version 4)
+type sessionExport struct {
+	Sessions []struct {
+		Name    string `json:"name"`
+		Created int64  `json:"created"` // milliseconds since epoch
+		Windows []struct {
+			Title string       `json:"title"`
+			Tabs  []sessionTab `json:"tabs"`
+		} `json:"windows"`
+	} `json:"sessions"`
+
+	Collections []struct {
+		Title   string `json:"title"`
+		Created int64  `json:"created"`
+		Folders []struct {
+			Title string       `json:"title"`
+			Links []sessionTab `json:"links"`
+		} `json:"folders"`
+	} `json:"collections"`
+}
+
+type sessionTab struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// reads Session Buddy JSON export into "Session Buddy" folder, with a sub-folder per session and
+// per window inside it (or per collection and its folders)
+func loadSession(name string, _ readOptions) (*Folder, error) {
+	src, err := readInput(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := parseSession(bytes.NewReader(src))
+
+	if err != nil {
+		if name == stdin {
+			name = "STDIN"
+		}
+
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return &Folder{Folders: []*Folder{top}, container: true}, nil
+}
+
+func parseSession(src io.Reader) (*Folder, error) {
+	var data sessionExport
+
+	if err := json.NewDecoder(src).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	top := &Folder{Node: Node{Name: "Session Buddy", Key: "session"}}
+
+	for i, s := range data.Sessions {
+		session := &Folder{Node: Node{Name: sessionName(s.Name, "Session", i), Added: sessionTime(s.Created)}}
+
+		for j, w := range s.Windows {
+			session.Folders = append(session.Folders, sessionFolder(sessionName(w.Title, "Window", j), session.Added, w.Tabs))
+		}
+
+		top.Folders = append(top.Folders, session)
+	}
+
+	for i, c := range data.Collections {
+		collection := &Folder{Node: Node{Name: sessionName(c.Title, "Collection", i), Added: sessionTime(c.Created)}}
+
+		for j, f := range c.Folders {
+			collection.Folders = append(collection.Folders, sessionFolder(sessionName(f.Title, "Folder", j), collection.Added, f.Links))
+		}
+
+		top.Folders = append(top.Folders, collection)
+	}
+
+	return top, nil
+}
+
+func sessionFolder(name string, added time.Time, tabs []sessionTab) *Folder {
+	folder := &Folder{Node: Node{Name: name, Added: added}}
+
+	for _, tab := range tabs {
+		if len(tab.URL) > 0 {
+			folder.Links = append(folder.Links, &Link{Node: Node{Name: foldSpaces(tab.Title), Added: added}, URL: tab.URL})
+		}
+	}
+
+	return folder
+}
+
+// the given name, or a numbered one like "Window 2"
+func sessionName(name, kind string, i int) string {
+	if name = foldSpaces(name); len(name) > 0 {
+		return name
+	}
+
+	return kind + " " + strconv.Itoa(i+1)
+}
+
+func sessionTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(ms/1000, ms%1000*1000000)
+}
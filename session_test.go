@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	const src = `{
+		"format": "nxs.json.v1",
+		"sessions": [{
+			"name": "Research", "created": 1600000000123,
+			"windows": [
+				{"tabs": [{"url": "https://a.com/", "title": "A"}, {"url": "https://b.com/", "title": " B "}]},
+				{"tabs": [{"url": "https://c.com/", "title": "C"}]}
+			]
+		}],
+		"collections": [{
+			"title": "", "folders": [{"title": "Go", "links": [{"url": "https://go.dev/", "title": "Go"}]}]
+		}]
+	}`
+
+	top, err := parseSession(strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"Research/Window 1/A <https://a.com/>",
+		"Research/Window 1/B <https://b.com/>",
+		"Research/Window 2/C <https://c.com/>",
+		"Collection 1/Go/Go <https://go.dev/>",
+	}
+
+	if lines := linkLines(top); !reflect.DeepEqual(lines, exp) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	if ts := top.Folders[0].Folders[0].Links[0].Added; ts.UnixNano() != 1600000000123000000 {
+		t.Errorf("Unexpected time: %s", ts)
+	}
+
+	if _, err = parseSession(strings.NewReader("[]")); err == nil {
+		t.Error("Missing error")
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand("site", "Make a static web site with a page per folder, ready to publish", siteCmd)
+}
+
+// "site" command
+func siteCmd(args []string) error {
+	start := time.Now()
+	opts := newOptions()
+	fs := newFlagSet("site", "")
+
+	opts.inputFlags(fs)
+	opts.treeFlags(fs)
+	opts.logFlags(fs)
+
+	fs.StringVar(&opts.outputName, "output", "site", "Output directory")
+	fs.StringVar(&opts.outputName, "o", "site", "Output directory")
+	fs.StringVar(&opts.lang, "lang", "en", "Language of the site, one of: "+languageNames())
+	fs.BoolVar(&opts.showDates, "show-dates", false, "Show bookmark dates")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if opts.outputName == stdout {
+		return errors.New("Site output must be a directory")
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	n, err := writeSite(opts.outputName, topFolders(roots), opts)
+
+	if err != nil {
+		return err
+	}
+
+	logInfo("written %d pages to %s in %s", n, opts.outputName, time.Since(start))
+	return nil
+}
+
+const siteStyle = `body { font-family: sans-serif; max-width: 50em; margin: 0 auto; padding: 1em; }
+nav.breadcrumbs { font-size: small; }
+ul.folders { list-style-type: square; }
+`
+
+// writes index page, a page per folder, and the style sheet into the directory;
+// returns the number of pages written
+func writeSite(dir string, folders []*Folder, opts *options) (int, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		return 0, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "assets", "style.css"), []byte(siteStyle), 0644); err != nil {
+		return 0, err
+	}
+
+	opts.anchors = folderAnchors(folders)
+	title := opts.phrases().title
+
+	// index page
+	err := writeSitePage(filepath.Join(dir, "index.html"), title, opts,
+		htmlTag("header", htmlTag("h1", htmlText(title))),
+		htmlTag("main", siteFolderList(folders, opts)),
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n := 1
+
+	// folder pages
+	var walk func([]*Folder, []fhtml) error
+
+	walk = func(folders []*Folder, crumbs []fhtml) error {
+		for _, f := range folders {
+			err := writeSitePage(filepath.Join(dir, sitePage(f, opts)), f.Name+" - "+title, opts,
+				htmlTag("header", htmlListArgs(
+					htmlRawText(`<nav class="breadcrumbs" aria-label="Breadcrumbs">`),
+					htmlList(crumbs),
+					htmlRawText("</nav>"),
+					htmlTag("h1", htmlText(f.Name)),
+				)),
+				htmlTag("main", htmlListArgs(
+					siteFolderList(f.Folders, opts),
+					folderLinks(f, opts),
+					omittedSummary(f, opts),
+				)),
+			)
+
+			if err != nil {
+				return err
+			}
+
+			n++
+
+			if err = walk(f.Folders, append(crumbs[:len(crumbs):len(crumbs)], siteLink(f, opts))); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return n, walk(folders, []fhtml{htmlLink("index.html", title), htmlRawText(" / ")})
+}
+
+func writeSitePage(name, title string, opts *options, fns ...fhtml) error {
+	head := fmt.Sprintf(`<!DOCTYPE HTML><html lang="%s">
+<head>
+<meta charset="utf-8"/><meta name="viewport" content="width=device-width, initial-scale=1"/>
+<title>%s</title><link rel="stylesheet" href="assets/style.css"/>
+</head>
+`, opts.lang, html.EscapeString(title))
+
+	return withWriter(name, outputMode{}, false, "")(WriterFunc(htmlListArgs(
+		htmlRawText(head),
+		htmlTag("body", htmlList(fns)),
+		htmlRawText("</html>\n"),
+	)))
+}
+
+// page file name of the folder, made of its anchor
+func sitePage(folder *Folder, opts *options) string {
+	name := strings.Replace(opts.anchors[folder], "/", ".", -1)
+
+	// anchors never contain "_"
+	if name == "index" {
+		name = "index_"
+	}
+
+	return name + ".html"
+}
+
+func siteLink(folder *Folder, opts *options) fhtml {
+	return htmlListArgs(htmlLink(sitePage(folder, opts), folder.Name), htmlRawText(" / "))
+}
+
+// list of links to the folder pages, with the number of links in each folder
+func siteFolderList(folders []*Folder, opts *options) fhtml {
+	if len(folders) == 0 {
+		return htmlNil
+	}
+
+	fns := make([]fhtml, len(folders))
+
+	for i, f := range folders {
+		_, nl := f.count()
+
+		fns[i] = htmlTag("li", htmlListArgs(
+			htmlLink(sitePage(f, opts), f.Name),
+			htmlRawText(" <small>("+strconv.Itoa(nl)+")</small>"),
+		))
+	}
+
+	return htmlListArgs(htmlRawText(`<ul class="folders">`), htmlList(fns), htmlRawText("</ul>"))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSite(t *testing.T) {
+	dir := t.TempDir()
+	sub := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{{Node: Node{Name: "A"}, URL: "https://a/"}}}
+	top := &Folder{Node: Node{Name: "Index"}, Folders: []*Folder{sub}}
+
+	n, err := writeSite(dir, []*Folder{top}, newOptions())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("Unexpected number of pages: %d", n)
+	}
+
+	pages := map[string][]string{
+		"index.html":     {`<a href="index_.html">Index</a> <small>(1)</small>`},
+		"index_.html":    {`<a href="index.sub.html">Sub</a>`},
+		"index.sub.html": {`<nav class="breadcrumbs" aria-label="Breadcrumbs"><a href="index.html">Bookmarks</a> / <a href="index_.html">Index</a> / </nav><h1>Sub</h1>`, `<a href="https://a/">A</a>`},
+	}
+
+	for name, exp := range pages {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range exp {
+			if !strings.Contains(string(data), s) {
+				t.Errorf("%s: %q not found in:\n%s", name, s, data)
+			}
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "assets", "style.css")); err != nil {
+		t.Fatal(err)
+	}
+}
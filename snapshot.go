@@ -0,0 +1,490 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+func init() {
+	registerCommand("snapshot", "Save bookmarked pages as single HTML files, with styles and images inlined", snapshotCmd)
+}
+
+// "snapshot" command
+func snapshotCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("snapshot", "")
+
+	var dir string
+	var refresh bool
+
+	opts.inputFlags(fs)
+	opts.treeFlags(fs)
+	fs.StringVar(&dir, "dir", defaultSnapshotDir(),
+		"Directory to save the pages to (default location is $XDG_DATA_HOME/"+programName+"/pages)")
+	fs.BoolVar(&refresh, "refresh", false, "Save the pages again, replacing the existing copies")
+	opts.networkFlags(fs)
+	opts.warcFlag(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(dir) == 0 {
+		return errors.New("Snapshot directory location is unknown, please specify --dir")
+	}
+
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	closeWARC, err := opts.openWARC()
+
+	if err != nil {
+		return err
+	}
+
+	links := webLinks(roots)
+	client := opts.newWebClient()
+
+	logInfo("saving %d pages", len(links))
+
+	err = forEachLink(links, opts.concurrency, func(link *Link) error {
+		name := filepath.Join(dir, pageSnapshotName(link.URL))
+
+		if _, err := os.Stat(name); err == nil && !refresh {
+			return nil
+		}
+
+		page, err := savePage(client, link.URL, time.Now())
+
+		if err != nil {
+			logWarn("%s: %s", link.URL, err)
+			return nil
+		}
+
+		return writeFileAtomic(name, page)
+	})
+
+	if e := closeWARC(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// link to the saved copy of the page, relative to the output file, or empty string if there is no copy
+func (opts *options) snapshotHref(link string) string {
+	if len(opts.snapshots) == 0 || !isWebURL(link) {
+		return ""
+	}
+
+	name := filepath.Join(opts.snapshots, pageSnapshotName(link))
+
+	if _, err := os.Stat(name); err != nil {
+		return ""
+	}
+
+	if opts.outputName != stdout {
+		if rel, err := filepath.Rel(filepath.Dir(opts.outputName), name); err == nil {
+			name = rel
+		}
+	}
+
+	return filepath.ToSlash(name)
+}
+
+func defaultSnapshotDir() string {
+	if dir := dataDir(); len(dir) > 0 {
+		return filepath.Join(dir, programName, "pages")
+	}
+
+	return ""
+}
+
+// file name of the saved page
+func pageSnapshotName(link string) string {
+	sum := sha1.Sum([]byte(link))
+
+	return hex.EncodeToString(sum[:]) + ".html"
+}
+
+// maximum size of a page or a resource it refers to
+const maxResourceSize = 10 << 20
+
+// fetches the page with its style sheets, images and icons, and returns it as a single HTML document;
+// scripts are dropped, and links are made absolute
+func savePage(client *webClient, link string, now time.Time) ([]byte, error) {
+	data, ctype, base, err := fetchResource(client, link)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if mt, _, _ := mime.ParseMediaType(ctype); mt != "text/html" && mt != "application/xhtml+xml" {
+		return nil, errors.New("Not an HTML page: " + ctype)
+	}
+
+	src, err := charset.NewReader(bytes.NewReader(data), ctype)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	in := &pageInliner{client: client, cache: make(map[string]string)}
+
+	in.inline(doc, base)
+
+	var buf bytes.Buffer
+
+	buf.WriteString("<!-- saved from " + strings.Replace(link, "--", "%2D%2D", -1) + " at " +
+		now.UTC().Format(time.RFC3339) + " -->\n")
+
+	if err = html.Render(&buf, doc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fetches the resource, returning its content, content type, and the final URL after redirects
+func fetchResource(client *webClient, link string) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequest("GET", link, nil)
+
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	resp, err := client.do(req)
+
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResourceSize+1))
+
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if len(data) > maxResourceSize {
+		return nil, "", nil, errors.New("Too large: " + link)
+	}
+
+	ctype := resp.Header.Get("Content-Type")
+
+	if len(ctype) == 0 {
+		ctype = http.DetectContentType(data)
+	}
+
+	return data, ctype, resp.Request.URL, nil
+}
+
+// replaces references to external resources with data URIs
+type pageInliner struct {
+	client *webClient
+	cache  map[string]string // data URIs by resource URL, empty for resources failed to load
+}
+
+func (in *pageInliner) inline(node *html.Node, base *url.URL) {
+	// the base URL of the document applies to the whole document
+	if href := htmlBaseHref(node); len(href) > 0 {
+		if u, err := base.Parse(href); err == nil {
+			base = u
+		}
+	}
+
+	in.inlineNode(node, base)
+}
+
+func htmlBaseHref(node *html.Node) string {
+	if node.Type == html.ElementNode && node.DataAtom == atom.Base {
+		return htmlAttr(node, "href")
+	}
+
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if href := htmlBaseHref(c); len(href) > 0 {
+			return href
+		}
+	}
+
+	return ""
+}
+
+func (in *pageInliner) inlineNode(node *html.Node, base *url.URL) {
+	for c := node.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		if c.Type == html.ElementNode {
+			switch c.DataAtom {
+			case atom.Script, atom.Base:
+				node.RemoveChild(c)
+				c = next
+				continue
+			case atom.Meta:
+				// the page is saved in UTF-8, and must not be refreshed
+				if len(htmlAttr(c, "charset")) > 0 || strings.EqualFold(htmlAttr(c, "http-equiv"), "content-type") ||
+					strings.EqualFold(htmlAttr(c, "http-equiv"), "refresh") {
+					node.RemoveChild(c)
+					c = next
+					continue
+				}
+			case atom.Link:
+				in.inlineLink(node, c, base)
+			case atom.Style:
+				if c.FirstChild != nil && c.FirstChild.Type == html.TextNode {
+					c.FirstChild.Data = in.inlineCSS(c.FirstChild.Data, base)
+				}
+			case atom.Img, atom.Input, atom.Video, atom.Audio, atom.Source, atom.Embed, atom.Iframe:
+				in.inlineSource(c, base)
+			case atom.A, atom.Area, atom.Form:
+				for i, a := range c.Attr {
+					if (a.Key == "href" || a.Key == "action") && !strings.HasPrefix(a.Val, "#") {
+						c.Attr[i].Val = resolveURL(base, a.Val)
+					}
+				}
+			}
+
+			in.inlineAttrs(c, base)
+		}
+
+		in.inlineNode(c, base)
+
+		if c.DataAtom == atom.Head {
+			c.InsertBefore(&html.Node{
+				Type: html.ElementNode, Data: "meta", DataAtom: atom.Meta,
+				Attr: []html.Attribute{{Key: "charset", Val: "utf-8"}},
+			}, c.FirstChild)
+		}
+
+		c = next
+	}
+}
+
+// replaces a style sheet link with <style> element, and inlines icons
+func (in *pageInliner) inlineLink(parent, node *html.Node, base *url.URL) {
+	rel := strings.Fields(strings.ToLower(htmlAttr(node, "rel")))
+	href := htmlAttr(node, "href")
+
+	switch {
+	case len(href) == 0:
+	case contains(rel, "stylesheet"):
+		u, err := base.Parse(href)
+
+		if err != nil {
+			return
+		}
+
+		data, _, final, err := fetchResource(in.client, u.String())
+
+		if err != nil {
+			logWarn("%s", err)
+			return
+		}
+
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+
+		if media := htmlAttr(node, "media"); len(media) > 0 {
+			style.Attr = []html.Attribute{{Key: "media", Val: media}}
+		}
+
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: in.inlineCSS(string(data), final)})
+		parent.InsertBefore(style, node)
+		parent.RemoveChild(node)
+	case contains(rel, "icon") || contains(rel, "apple-touch-icon"):
+		setHTMLAttr(node, "href", in.dataURI(base, href))
+	default:
+		// preloads and the like are of no use offline
+		setHTMLAttr(node, "href", resolveURL(base, href))
+	}
+}
+
+// inlines "src" and "poster" of images, media and frames; "srcset" is dropped in favour of "src"
+func (in *pageInliner) inlineSource(node *html.Node, base *url.URL) {
+	attrs := node.Attr[:0]
+
+	for _, a := range node.Attr {
+		switch a.Key {
+		case "srcset", "loading":
+			continue
+		case "src":
+			if node.DataAtom == atom.Iframe || node.DataAtom == atom.Embed {
+				a.Val = resolveURL(base, a.Val)
+			} else if node.DataAtom != atom.Input || strings.EqualFold(htmlAttr(node, "type"), "image") {
+				a.Val = in.dataURI(base, a.Val)
+			}
+		case "poster":
+			a.Val = in.dataURI(base, a.Val)
+		}
+
+		attrs = append(attrs, a)
+	}
+
+	node.Attr = attrs
+}
+
+// inlines url() references in "style" attributes
+func (in *pageInliner) inlineAttrs(node *html.Node, base *url.URL) {
+	for i, a := range node.Attr {
+		if a.Key == "style" {
+			node.Attr[i].Val = in.inlineCSS(a.Val, base)
+		}
+	}
+}
+
+// url(...) and @import "..." references of a style sheet
+var cssURL = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// inlines the resources referenced from the style sheet; imported style sheets are inlined as data URIs as well
+func (in *pageInliner) inlineCSS(css string, base *url.URL) string {
+	return cssURL.ReplaceAllStringFunc(css, func(s string) string {
+		m := cssURL.FindStringSubmatch(s)
+		ref := m[1] + m[2] + m[3]
+
+		if len(ref) == 0 {
+			return `@import url("` + in.dataURI(base, m[4]+m[5]) + `")`
+		}
+
+		return `url("` + in.dataURI(base, ref) + `")`
+	})
+}
+
+// fetches the resource and returns it as data URI, or its absolute URL if it cannot be fetched
+func (in *pageInliner) dataURI(base *url.URL, ref string) string {
+	if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+
+	u, err := base.Parse(strings.TrimSpace(ref))
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ref
+	}
+
+	u.Fragment = ""
+	link := u.String()
+
+	uri, ok := in.cache[link]
+
+	if !ok {
+		// style sheets may import each other
+		in.cache[link] = ""
+
+		data, ctype, final, err := fetchResource(in.client, link)
+
+		if err != nil {
+			logWarn("%s", err)
+		} else {
+			// resources of a style sheet are relative to the style sheet
+			if mt, _, _ := mime.ParseMediaType(ctype); mt == "text/css" {
+				data = []byte(in.inlineCSS(string(data), final))
+			}
+
+			uri = "data:" + ctype + ";base64," + base64.StdEncoding.EncodeToString(data)
+		}
+
+		in.cache[link] = uri
+	}
+
+	if len(uri) == 0 {
+		return link
+	}
+
+	return uri
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if u, err := base.Parse(strings.TrimSpace(ref)); err == nil {
+		return u.String()
+	}
+
+	return ref
+}
+
+func htmlAttr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func setHTMLAttr(node *html.Node, key, val string) {
+	for i, a := range node.Attr {
+		if a.Key == key {
+			node.Attr[i].Val = val
+			return
+		}
+	}
+
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: val})
+}
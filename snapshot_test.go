@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	pages := map[string]string{
+		"/page": `<html><head><title>Page</title><meta charset="windows-1251"><link rel="stylesheet" href="css/main.css">
+			<script>alert(1)</script></head><body style="background: url('bg.gif')"><img src="/img.gif" srcset="/img2.gif 2x">
+			<a href="other">Other</a> <a href="#top">Top</a> <img src="/missing.gif"></body></html>`,
+		"/css/main.css": `@import "more.css"; p { background: url(../img.gif) }`,
+		"/css/more.css": `@import "main.css"; h1 { color: red }`,
+		"/img.gif":      "GIF89a",
+		"/bg.gif":       "GIF89a",
+	}
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".css"):
+			w.Header().Set("Content-Type", "text/css")
+		case strings.HasSuffix(r.URL.Path, ".gif"):
+			w.Header().Set("Content-Type", "image/gif")
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+
+		fmt.Fprint(w, page)
+	}))
+
+	defer web.Close()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "Bookmarks")
+	data := `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0",
+		"children": [{"type": "url", "name": "Page", "url": "` + web.URL + `/page", "id": "2", "date_added": "0"}]}}}`
+
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pagesDir := filepath.Join(dir, "pages")
+
+	if err := snapshotCmd([]string{"-q", "--dir", pagesDir, "--retries", "0", "-i", name}); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := ioutil.ReadFile(filepath.Join(pagesDir, pageSnapshotName(web.URL+"/page")))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(saved)
+	gif := "data:image/gif;base64,R0lGODlh"
+
+	for _, exp := range []string{
+		"<!-- saved from " + web.URL + "/page at ",
+		`<head><meta charset="utf-8"/><title>Page</title><style>@import url("data:text/css;base64,`,
+		`p { background: url("` + gif + `") }</style>`,
+		`<body style="background: url(&#34;` + gif + `&#34;)"><img src="` + gif + `"/>`,
+		`<a href="` + web.URL + `/other">Other</a> <a href="#top">Top</a> <img src="` + web.URL + `/missing.gif"/>`,
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in:\n%s", exp, s)
+		}
+	}
+
+	if strings.Contains(s, "<script") || strings.Contains(s, "windows-1251") {
+		t.Errorf("Unexpected page:\n%s", s)
+	}
+
+	// links to the saved pages
+	out := filepath.Join(dir, "bookmarks.html")
+
+	if err = exportCmd([]string{"-q", "--snapshots", pagesDir, "-i", name, "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ioutil.ReadFile(out); err != nil || !strings.Contains(string(data),
+		` <a class="snapshot" href="pages/`+pageSnapshotName(web.URL+"/page")+`">saved copy</a>`) {
+		t.Errorf("Unexpected output: %s, %v", data, err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bookmarks file decoder building the tree directly from JSON tokens, so that only
+// the fields of the nodes on the current path are held in memory as generic values
+type treeDecoder struct {
+	dec     *json.Decoder
+	ro      readOptions
+	path    []string       // keys of the objects being read
+	errs    []string       // malformed nodes, with --all-errors
+	skipped map[string]int // number of malformed nodes per error message, with --lenient
+}
+
+// placeholder for a malformed node
+type skippedNode struct{}
+
+func newTreeDecoder(src io.Reader, ro readOptions) *treeDecoder {
+	d := &treeDecoder{
+		dec:     json.NewDecoder(src),
+		ro:      ro,
+		skipped: make(map[string]int),
+	}
+
+	d.dec.UseNumber()
+	return d
+}
+
+// reads the tree and the checksum
+func (d *treeDecoder) decode() (root *Folder, sum string, err error) {
+
+	if err = d.expect('{'); err != nil {
+		return
+	}
+
+	for d.dec.More() {
+		var key string
+
+		if key, err = d.key(); err != nil {
+			return
+		}
+
+		switch key {
+		case "checksum":
+			var tok json.Token
+
+			if tok, err = d.dec.Token(); err != nil {
+				return
+			}
+
+			var ok bool
+
+			if sum, ok = tok.(string); !ok {
+				return nil, "", errors.New("Checksum is not a string")
+			}
+		case "roots":
+			var item interface{}
+
+			if item, err = d.value(key, true); err != nil {
+				return
+			}
+
+			if root, err = buildTree(key, item); err != nil {
+				return
+			}
+		default:
+			if err = d.skip(); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = d.expect('}'); err == nil && root == nil {
+		err = errors.New("Missing \"roots\" tag")
+	}
+
+	if err == nil && len(d.errs) > 0 {
+		err = fmt.Errorf("%d malformed nodes:\n  %s", len(d.errs), strings.Join(d.errs, "\n  "))
+	}
+
+	return
+}
+
+// reads a value; with nodes flag set, objects with "type" tag are turned into *Folder or *Link
+func (d *treeDecoder) value(key string, nodes bool) (interface{}, error) {
+	tok, err := d.dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		return d.object(key, nodes)
+	case json.Delim('['):
+		return d.array(key, nodes)
+	}
+
+	return tok, nil
+}
+
+func (d *treeDecoder) object(key string, nodes bool) (interface{}, error) {
+	d.path = append(d.path, key)
+	defer func() { d.path = d.path[:len(d.path)-1] }()
+
+	obj := make(map[string]interface{})
+
+	for d.dec.More() {
+		k, err := d.key()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// meta info is just strings
+		v, err := d.value(k, nodes && !strings.HasSuffix(k, "meta_info"))
+
+		if err != nil {
+			if _, ok := err.(*ParserError); ok {
+				err = mapError(key, err)
+			}
+
+			return nil, err
+		}
+
+		if _, skip := v.(skippedNode); !skip {
+			obj[k] = v
+		}
+	}
+
+	if err := d.expect('}'); err != nil {
+		return nil, err
+	}
+
+	if _, ok := obj["type"]; !ok || !nodes {
+		return obj, nil
+	}
+
+	node, err := makeNode(key, obj)
+
+	if err != nil && d.ro.lenient {
+		if e, ok := err.(*ParserError); ok {
+			d.skipped[e.msg]++
+			return skippedNode{}, nil
+		}
+	}
+
+	if err != nil && d.ro.allErrors {
+		// the error path only has the node key
+		if parent := strings.Join(d.path[:len(d.path)-1], "/"); len(parent) > 0 {
+			err = mapError(parent, err)
+		}
+
+		d.errs = append(d.errs, err.Error())
+		return skippedNode{}, nil
+	}
+
+	return node, err
+}
+
+func (d *treeDecoder) array(key string, nodes bool) (interface{}, error) {
+	var list []interface{}
+
+	for i := 0; d.dec.More(); i++ {
+		v, err := d.value("#"+strconv.Itoa(i), nodes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, skip := v.(skippedNode); !skip {
+			list = append(list, v)
+		}
+	}
+
+	return list, d.expect(']')
+}
+
+func (d *treeDecoder) key() (string, error) {
+	tok, err := d.dec.Token()
+
+	if err != nil {
+		return "", err
+	}
+
+	return tok.(string), nil // the decoder guarantees a string here
+}
+
+// skips a value of any type
+func (d *treeDecoder) skip() error {
+	depth := 0
+
+	for {
+		tok, err := d.dec.Token()
+
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (d *treeDecoder) expect(delim json.Delim) error {
+	tok, err := d.dec.Token()
+
+	if err == nil && tok != delim {
+		err = errors.New("Expected " + delim.String() + " in JSON")
+	}
+
+	return err
+}
+
+// number of malformed nodes skipped in lenient mode, and the summary of the errors
+func (d *treeDecoder) skippedNodes() (int, string) {
+	msgs := make([]string, 0, len(d.skipped))
+	total := 0
+
+	for msg, n := range d.skipped {
+		msgs = append(msgs, msg+": "+strconv.Itoa(n))
+		total += n
+	}
+
+	sort.Strings(msgs)
+	return total, strings.Join(msgs, "; ")
+}
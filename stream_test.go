@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeTree(t *testing.T) {
+	const src = `{"checksum": "x", "sync_metadata": {"a": [1, {"b": null}]}, "roots": {
+		"bookmark_bar": {"children": [
+			{"type": "url", "id": "2", "name": "A", "url": "https://a/", "date_added": "0",
+				"meta_info": {"Description": "about", "type": "not a node"}}
+		], "type": "folder", "id": "1", "name": "Bar", "date_added": "0"},
+		"custom_root": {"speedDial": {"type": "folder", "id": "3", "name": "Speed Dial", "date_added": "0"}}
+	}, "version": 1}`
+
+	root, sum, err := newTreeDecoder(strings.NewReader(src), readOptions{}).decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != "x" {
+		t.Errorf("Unexpected checksum %q", sum)
+	}
+
+	bar, err := findFolder([]*Folder{root}, "Bar")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bar.Links) != 1 || bar.Links[0].description() != "about" || bar.Links[0].ID != "2" {
+		t.Fatalf("Unexpected links: %v", bar.Links)
+	}
+
+	if _, err = findFolder([]*Folder{root}, "custom_root/Speed Dial"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeTreeErrors(t *testing.T) {
+	cases := map[string]string{
+		`{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "date_added": "0", "children": [
+			{"type": "url", "name": "A", "date_added": "0"}]}}}`: `Node roots/bookmark_bar/#0: Tag "url" is not found`,
+		`{"roots": {"bookmark_bar": {"type": "nonsense"}}}`: `Node roots/bookmark_bar: Unknown type "nonsense"`,
+		`{"version": 1}`: `Missing "roots" tag`,
+		`{"roots": {`:    `unexpected end of JSON input`,
+	}
+
+	for src, exp := range cases {
+		if _, _, err := newTreeDecoder(strings.NewReader(src), readOptions{}).decode(); err == nil || err.Error() != exp {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestDecodeTreeAllErrors(t *testing.T) {
+	const src = `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "date_added": "0", "children": [
+		{"type": "url", "name": "A", "date_added": "0"},
+		{"type": "url", "name": "B", "url": "https://b/", "date_added": "0"},
+		{"type": "folder", "name": "F", "date_added": "x", "children": [{"type": "url", "url": "https://c/"}]}
+	]}}}`
+
+	_, _, err := newTreeDecoder(strings.NewReader(src), readOptions{allErrors: true}).decode()
+
+	exp := `3 malformed nodes:
+  Node roots/bookmark_bar/#0: Tag "url" is not found
+  Node roots/bookmark_bar/#2/#0: Tag "name" is not found
+  Node roots/bookmark_bar/#2: Value for tag "date_added" is not an integer: "x"`
+
+	if err == nil || err.Error() != exp {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestDecodeTreeLenient(t *testing.T) {
+	const src = `{"roots": {"bookmark_bar": {"type": "folder", "name": "Bar", "date_added": "0", "children": [
+		{"type": "url", "name": "A", "date_added": "0"},
+		{"type": "url", "name": "B", "url": "https://b/", "date_added": "0"},
+		{"type": "url", "name": "C", "date_added": "0"},
+		{"type": "url", "url": "https://d/", "date_added": "0"}
+	]}}}`
+
+	dec := newTreeDecoder(strings.NewReader(src), readOptions{lenient: true})
+	root, _, err := dec.decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := linkLines(root); len(lines) != 1 || lines[0] != "Bar/B <https://b/>" {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	n, summary := dec.skippedNodes()
+
+	if exp := `Tag "name" is not found: 1; Tag "url" is not found: 2`; n != 3 || summary != exp {
+		t.Fatalf("Unexpected summary: %d, %s", n, summary)
+	}
+}
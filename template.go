@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func init() {
+	registerFormat(exportFunc{"template", foldersToTemplate})
+}
+
+// data passed to the template
+type templateData struct {
+	Folders []*Folder       // top-level folders
+	Links   []*templateLink // all links, depth first
+}
+
+type templateLink struct {
+	*Link
+	Path        []string // names of the folders on the path to the link
+	Description string
+}
+
+// helper functions available to templates, in addition to the standard ones
+var templateFuncs = template.FuncMap{
+	"pathJoin": func(sep string, path []string) string {
+		return strings.Join(path, sep)
+	},
+	"dateFmt": func(layout string, ts time.Time) string {
+		if !ts.After(googleEpoch) {
+			return ""
+		}
+
+		return ts.Format(layout)
+	},
+	"urlHost": func(s string) string {
+		if u, err := url.Parse(s); err == nil {
+			return u.Hostname()
+		}
+
+		return ""
+	},
+	"xml": xmlEscape,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"tex":   texEscaper.Replace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+var texEscaper = strings.NewReplacer(
+	"\\", "\\textbackslash{}", "{", "\\{", "}", "\\}", "$", "\\$", "&", "\\&", "#", "\\#",
+	"%", "\\%", "_", "\\_", "^", "\\^{}", "~", "\\~{}",
+)
+
+// output made by the template from --template-file
+func foldersToTemplate(folders []*Folder, opts *options, dest StringWriter) error {
+	if len(opts.templateFile) == 0 {
+		return errors.New("Template format requires --template-file")
+	}
+
+	text, err := ioutil.ReadFile(opts.templateFile)
+
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(opts.templateFile).Funcs(templateFuncs).Parse(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	data := &templateData{Folders: folders}
+	root := &Folder{Folders: folders}
+
+	root.walkLinks(nil, func(path []string, link *Link) error {
+		data.Links = append(data.Links, &templateLink{link, path, link.description()})
+		return nil
+	})
+
+	return tmpl.Execute(asWriter(dest), data)
+}
+
+// XML escaped text, also valid as an attribute value
+func xmlEscape(s string) string {
+	var buff bytes.Buffer
+
+	xml.EscapeText(&buff, []byte(s))
+	return buff.String()
+}
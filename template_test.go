@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	const tmpl = `{{range .Links}}@misc{ {{- .Name | lower}},
+  title = { {{- tex .Name}}},
+  howpublished = {\url{ {{- .URL}}}},
+  keywords = { {{- pathJoin ", " .Path}}},
+  note = { {{- urlHost .URL}}, {{dateFmt "2006" .Added}}}
+}
+{{end}}`
+
+	name := filepath.Join(t.TempDir(), "bib.tmpl")
+
+	if err := os.WriteFile(name, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, _, err := newTreeDecoder(strings.NewReader(testBookmarks("R&D_1")), readOptions{}).decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := newOptions()
+	opts.templateFile = name
+
+	var buff bytes.Buffer
+
+	if err = foldersToTemplate(root.Folders, opts, &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `@misc{r&d_1,
+  title = {R\&D\_1},
+  howpublished = {\url{https://example.com/}},
+  keywords = {Bar},
+  note = {example.com, }
+}
+`
+
+	if buff.String() != exp {
+		t.Fatalf("Unexpected output:\n%s", buff.String())
+	}
+}
@@ -0,0 +1,446 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plain text format, one line per folder or link, indented with tabs by the folder depth:
+//
+//	Bookmarks bar/
+//		News/
+//			Go blog<TAB>https://go.dev/blog/
+//
+// Folder lines end with "/", and link lines have the name and the URL separated by a tab.
+// Empty lines and lines starting with "#" are ignored.
+
+func init() {
+	registerFormat(exportFunc{"text", foldersToText})
+	registerCommand("apply", "Replace the bookmarks with those from a text file made by \"--format text\"", applyCmd)
+}
+
+func foldersToText(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &textWriter{dest: dest}
+
+	textFolders(w, folders, "")
+	return w.err
+}
+
+func textFolders(w *textWriter, folders []*Folder, indent string) {
+	for _, folder := range folders {
+		w.write(indent + textName(folder.Name) + "/\n")
+
+		for _, link := range folder.Links {
+			w.write(indent + "\t" + textName(link.Name) + "\t" + link.URL + "\n")
+		}
+
+		textFolders(w, folder.Folders, indent+"\t")
+	}
+}
+
+var textEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+func textName(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// node of the text file
+type textNode struct {
+	name, url string
+	folder    bool
+	children  []*textNode
+}
+
+// reads the text format
+func parseText(src io.Reader) ([]*textNode, error) {
+	root := &textNode{folder: true}
+	stack := []*textNode{root} // folders on the path to the current line
+	scanner := bufio.NewScanner(src)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		body := strings.TrimLeft(line, "\t")
+		level := len(line) - len(body)
+
+		if level >= len(stack) {
+			return nil, fmt.Errorf("Line %d: Unexpected indentation", n)
+		}
+
+		stack = stack[:level+1]
+		parent := stack[level]
+
+		if i := strings.IndexByte(body, '\t'); i >= 0 {
+			url := strings.TrimSpace(body[i+1:])
+
+			if len(url) == 0 {
+				return nil, fmt.Errorf("Line %d: Missing URL", n)
+			}
+
+			if parent == root {
+				return nil, fmt.Errorf("Line %d: Link outside of any folder", n)
+			}
+
+			parent.children = append(parent.children, &textNode{name: body[:i], url: url})
+		} else if strings.HasSuffix(body, "/") {
+			folder := &textNode{name: body[:len(body)-1], folder: true}
+
+			parent.children = append(parent.children, folder)
+			stack = append(stack, folder)
+		} else {
+			return nil, fmt.Errorf("Line %d: Expected either folder name ending with \"/\", or name and URL separated by a tab", n)
+		}
+	}
+
+	return root.children, scanner.Err()
+}
+
+// "apply" command
+func applyCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("apply", "TEXT-FILE")
+
+	opts.inputFlags(fs)
+	opts.writeBackFlags(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("Exactly one text file is expected")
+	}
+
+	if len(opts.inputs) > 1 || opts.inputs[0].name == stdin {
+		return errors.New("Only one input file is allowed, and it cannot be STDIN")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	nodes, err := parseText(file)
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", fs.Arg(0), err)
+	}
+
+	name := opts.inputs[0].name
+	data, err := loadRawData(name)
+
+	if err != nil {
+		return err
+	}
+
+	if !data.sumValid {
+		return errors.New(name + ": Checksum mismatch, the file may be corrupted or edited by hand")
+	}
+
+	from, err := buildTree("roots", data.Roots)
+
+	if err != nil {
+		return err
+	}
+
+	if err = applyText(data.Roots, nodes, time.Now()); err != nil {
+		return err
+	}
+
+	to, err := buildTree("roots", data.Roots)
+
+	if err != nil {
+		return err
+	}
+
+	changes := treeChanges(from, to)
+
+	if len(changes) == 0 {
+		logNotice("no changes")
+		return nil
+	}
+
+	if apply, err := opts.confirm(changes); !apply {
+		return err
+	}
+
+	if err = data.save(name); err != nil {
+		return err
+	}
+
+	logNotice("updated %s; Opera must not be running while the file is replaced", name)
+	return nil
+}
+
+// replaces the contents of the roots present in the text; the top-level text folders are matched
+// against the roots by their names as shown in the export, and the existing nodes are kept
+// (with their ids and dates) where they match the text
+func applyText(roots interface{}, nodes []*textNode, now time.Time) error {
+	targets := make(map[string]map[string]interface{})
+
+	walkRawRoots(roots, nil, func(path []string, node map[string]interface{}) {
+		targets[strings.Join(path, "/")] = node
+	})
+
+	b := &rawBuilder{now: googleTimeStamp(now), used: make(map[string]bool)}
+
+	walkRawFolders(roots, func(_ []string, node map[string]interface{}) {
+		b.maxID(node)
+	})
+
+	var match func([]*textNode, []string) error
+
+	match = func(nodes []*textNode, path []string) error {
+		for _, n := range nodes {
+			p := append(path[:len(path):len(path)], n.name)
+
+			if !n.folder {
+				return fmt.Errorf("Link %q is not inside a root folder", n.name)
+			}
+
+			if root, ok := targets[strings.Join(p, "/")]; ok {
+				b.collectLinks(root)
+
+				children, _ := root["children"].([]interface{})
+				root["children"] = b.children(n.children, children)
+				root["date_modified"] = b.now
+			} else if err := match(n.children, p); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return match(nodes, nil)
+}
+
+// calls the function on every root folder, with its path as shown in the export
+func walkRawRoots(item interface{}, path []string, fn func([]string, map[string]interface{})) {
+	node, _ := item.(map[string]interface{})
+
+	for _, key := range sortedKeys(node) {
+		child, ok := node[key].(map[string]interface{})
+
+		if !ok {
+			continue
+		}
+
+		if _, typed := child["type"]; !typed {
+			walkRawRoots(child, append(path[:len(path):len(path)], rootDisplayName(key, "")), fn)
+		} else if rawString(child, "type") == "folder" {
+			fn(append(path[:len(path):len(path)], rootDisplayName(key, rawString(child, "name"))), child)
+		}
+	}
+}
+
+// name of the root as renameRoots() shows it without --root-names
+func rootDisplayName(key, name string) string {
+	if s, ok := defaultRootNames[key]; ok && len(name) == 0 {
+		return s
+	}
+
+	if len(name) == 0 {
+		return key
+	}
+
+	return name
+}
+
+// maker of raw nodes
+type rawBuilder struct {
+	lastID int64
+	now    string
+	links  map[string][]map[string]interface{} // existing links of the root, by URL
+	used   map[string]bool                     // existing nodes already placed, by nodeKey()
+}
+
+// identity of the raw node
+func nodeKey(node map[string]interface{}) string {
+	return fmt.Sprintf("%p", node)
+}
+
+// collects the links of the root, so that the links moved to another folder keep their ids and dates
+func (b *rawBuilder) collectLinks(root map[string]interface{}) {
+	b.links = make(map[string][]map[string]interface{})
+
+	var walk func(map[string]interface{})
+
+	walk = func(node map[string]interface{}) {
+		children, _ := node["children"].([]interface{})
+
+		for _, child := range children {
+			if c, ok := child.(map[string]interface{}); ok {
+				if rawString(c, "type") == "url" {
+					b.links[rawString(c, "url")] = append(b.links[rawString(c, "url")], c)
+				} else {
+					walk(c)
+				}
+			}
+		}
+	}
+
+	walk(root)
+}
+
+// takes the first unused node, preferring the one with the given name
+func (b *rawBuilder) take(nodes []map[string]interface{}, name string) map[string]interface{} {
+	var res map[string]interface{}
+
+	for _, node := range nodes {
+		if !b.used[nodeKey(node)] {
+			if rawString(node, "name") == name {
+				res = node
+				break
+			}
+
+			if res == nil {
+				res = node
+			}
+		}
+	}
+
+	if res != nil {
+		b.used[nodeKey(res)] = true
+	}
+
+	return res
+}
+
+// finds the maximum id of the folder and its links
+func (b *rawBuilder) maxID(node map[string]interface{}) {
+	if id, err := strconv.ParseInt(rawString(node, "id"), 10, 64); err == nil && id > b.lastID {
+		b.lastID = id
+	}
+
+	children, _ := node["children"].([]interface{})
+
+	for _, child := range children {
+		if c, ok := child.(map[string]interface{}); ok && rawString(c, "type") == "url" {
+			b.maxID(c)
+		}
+	}
+}
+
+func (b *rawBuilder) newNode(typ, name string) map[string]interface{} {
+	b.lastID++
+
+	return map[string]interface{}{
+		"type":       typ,
+		"name":       name,
+		"id":         strconv.FormatInt(b.lastID, 10),
+		"date_added": b.now,
+	}
+}
+
+// makes the list of children from the text nodes, reusing the matching existing nodes
+func (b *rawBuilder) children(nodes []*textNode, existing []interface{}) []interface{} {
+	var folders, links []map[string]interface{}
+
+	for _, item := range existing {
+		if node, ok := item.(map[string]interface{}); ok {
+			if rawString(node, "type") == "folder" {
+				folders = append(folders, node)
+			} else {
+				links = append(links, node)
+			}
+		}
+	}
+
+	res := make([]interface{}, 0, len(nodes))
+
+	for _, n := range nodes {
+		var node map[string]interface{}
+
+		if n.folder {
+			var same []map[string]interface{}
+
+			for _, f := range folders {
+				if rawString(f, "name") == n.name {
+					same = append(same, f)
+				}
+			}
+
+			if node = b.take(same, n.name); node == nil {
+				node = b.newNode("folder", n.name)
+				node["date_modified"] = b.now
+			}
+
+			children, _ := node["children"].([]interface{})
+			node["children"] = b.children(n.children, children)
+		} else {
+			// the link from the same folder, or moved from another folder
+			var same []map[string]interface{}
+
+			for _, l := range links {
+				if rawString(l, "url") == n.url {
+					same = append(same, l)
+				}
+			}
+
+			if node = b.take(same, n.name); node == nil {
+				node = b.take(b.links[n.url], n.name)
+			}
+
+			if node == nil {
+				node = b.newNode("url", n.name)
+				node["url"] = n.url
+			}
+
+			node["name"] = n.name
+		}
+
+		res = append(res, node)
+	}
+
+	return res
+}
+
+// Google timestamp, the number of microseconds since 01/01/1601 00:00.00
+func googleTimeStamp(ts time.Time) string {
+	const epochDiff = 11644473600 // seconds from 1601 to 1970
+
+	return strconv.FormatInt((ts.Unix()+epochDiff)*1000000+int64(ts.Nanosecond()/1000), 10)
+}
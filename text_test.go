@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextRoundTrip(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "children": [
+			{"type": "url", "name": "A", "url": "https://a/", "id": "2", "date_added": "10"},
+			{"type": "folder", "name": "Sub/dir", "id": "3", "date_added": "0", "children": [
+				{"type": "url", "name": "B\tC", "url": "https://b/", "id": "4", "date_added": "0"}
+			]}
+		]},
+		"other": {"type": "folder", "name": "Other", "id": "5", "date_added": "0", "children": [
+			{"type": "url", "name": "O", "url": "https://o/", "id": "6", "date_added": "0"}
+		]},
+		"custom_root": {"trash": {"type": "folder", "name": "Trash", "id": "7", "date_added": "0", "children": []}}
+	}}`
+
+	data, err := decodeRawData("test", strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := buildTree("roots", data.Roots)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := findFolder([]*Folder{root}, "Bar")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+
+	if err = foldersToText([]*Folder{bar}, newOptions(), &buff); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = "Bar/\n\tA\thttps://a/\n\tSub/dir/\n\t\tB C\thttps://b/\n"
+
+	if buff.String() != exp {
+		t.Fatalf("Unexpected text: %q", buff.String())
+	}
+
+	// edit: rename a link, move another, add a new one, and delete from Trash
+	text := "# my bookmarks\nBar/\n\tSub/dir/\n\t\tB C\thttps://b/\n\t\tA\thttps://a/\n\tNew/\n\t\tN\thttps://n/\n" +
+		"\n\tRenamed\thttps://b/\nOpera/\n\tTrash/\n"
+
+	nodes, err := parseText(strings.NewReader(text))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err = applyText(data.Roots, nodes, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if root, err = buildTree("roots", data.Roots); err != nil {
+		t.Fatal(err)
+	}
+
+	bar, _ = findFolder([]*Folder{root}, "Bar")
+
+	var lines []string
+
+	bar.walkLinks(nil, func(path []string, link *Link) error {
+		lines = append(lines, strings.Join(append(path, link.Name, link.URL, link.ID), " "))
+		return nil
+	})
+
+	exp2 := []string{
+		"Renamed https://b/ 10",
+		"Sub/dir B C https://b/ 4",
+		"Sub/dir A https://a/ 2",
+		"New N https://n/ 9",
+	}
+
+	if !reflect.DeepEqual(lines, exp2) {
+		t.Fatalf("Unexpected links: %q", lines)
+	}
+
+	if a := bar.Folders[0].Links[1]; !a.Added.Equal(googleTime(10)) {
+		t.Fatalf("Date of an existing link is lost: %s", a.Added)
+	}
+
+	if lines = linkLines(root); len(lines) != 5 || !strings.Contains(strings.Join(lines, "\n"), "Other/O <https://o/>") {
+		t.Fatalf("Other root is modified: %q", lines)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	cases := map[string]string{
+		"Bar/\n\t\tA\thttps://a/\n": "Line 2: Unexpected indentation",
+		"A\thttps://a/\n":           "Line 1: Link outside of any folder",
+		"Bar/\n\tA\t\n":             "Line 2: Missing URL",
+		"Bar\n":                     "Line 1: Expected either folder name ending with \"/\", or name and URL separated by a tab",
+	}
+
+	for src, exp := range cases {
+		if _, err := parseText(strings.NewReader(src)); err == nil || err.Error() != exp {
+			t.Errorf("%q: unexpected error: %v", src, err)
+		}
+	}
+}
+
+func TestGoogleTimeStamp(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	val, err := strconv.ParseInt(googleTimeStamp(ts), 10, 64)
+
+	if err != nil || !googleTime(val).Equal(ts) {
+		t.Fatalf("Unexpected result: %d, %v", val, err)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerFormat(exportFunc{"gallery", foldersToGallery})
+}
+
+// browsers capable of taking screenshots in headless mode
+var headlessBrowsers = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable"}
+
+// time limit for taking one screenshot
+const screenshotTimeout = time.Minute
+
+// captures thumbnails for all web links into the directory, skipping the existing ones
+func (opts *options) captureThumbnails(roots []*Folder) error {
+	browser := opts.browser
+
+	if len(browser) == 0 {
+		for _, name := range headlessBrowsers {
+			if path, err := exec.LookPath(name); err == nil {
+				browser = path
+				break
+			}
+		}
+
+		if len(browser) == 0 {
+			return errors.New("No headless browser found, please specify --browser")
+		}
+	}
+
+	if err := os.MkdirAll(opts.thumbnails, 0755); err != nil {
+		return err
+	}
+
+	links := webLinks(roots)
+
+	logInfo("capturing thumbnails for %d links", len(links))
+
+	return forEachLink(links, opts.concurrency, func(link *Link) error {
+		name := filepath.Join(opts.thumbnails, thumbnailName(link.URL))
+
+		if _, err := os.Stat(name); err == nil {
+			return nil
+		}
+
+		if err := screenshot(browser, link.URL, name); err != nil {
+			logWarn("%s: %s", link.URL, err)
+		}
+
+		return nil
+	})
+}
+
+// thumbnail file name for the URL
+func thumbnailName(link string) string {
+	sum := sha1.Sum([]byte(link))
+
+	return hex.EncodeToString(sum[:]) + ".png"
+}
+
+// takes screenshot of the page using browser's headless mode
+func screenshot(browser, link, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), screenshotTimeout)
+
+	defer cancel()
+
+	// each browser process needs its own profile directory to run concurrently
+	profile, err := ioutil.TempDir("", programName)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(profile)
+
+	out, err := exec.CommandContext(ctx, browser,
+		"--headless", "--disable-gpu", "--hide-scrollbars", "--no-first-run",
+		"--user-data-dir="+profile,
+		"--window-size=1280,800",
+		"--screenshot="+name,
+		link,
+	).CombinedOutput()
+
+	if err != nil {
+		os.Remove(name)
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// "speed dial" gallery page
+func foldersToGallery(folders []*Folder, opts *options, dest StringWriter) error {
+	w := &textWriter{dest: dest}
+
+	// thumbnails are referenced relative to the output file
+	dir := opts.thumbnails
+
+	if len(dir) > 0 && opts.outputName != stdout {
+		if rel, err := filepath.Rel(filepath.Dir(opts.outputName), dir); err == nil {
+			dir = rel
+		}
+	}
+
+	w.write(fmt.Sprintf(galleryHeader, opts.lang, html.EscapeString(opts.phrases().title)))
+	galleryFolders(w, folders, dir)
+	w.write("</body></html>\n")
+	return w.err
+}
+
+const galleryHeader = `<!DOCTYPE HTML><html lang="%s">
+<head>
+<meta charset="utf-8"/><title>%s</title>
+<style>
+body { font-family: sans-serif; }
+.grid { display: flex; flex-wrap: wrap; gap: 1em; }
+.grid a { width: 240px; text-decoration: none; color: inherit; }
+.grid img, .grid .blank { width: 240px; height: 150px; object-fit: cover; border: 1px solid #ccc; display: block; }
+.grid span { display: block; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+</style>
+</head>
+<body>
+`
+
+func galleryFolders(w *textWriter, folders []*Folder, dir string) {
+	for _, folder := range folders {
+		w.write("<h4>" + html.EscapeString(folder.Name) + "</h4>\n")
+
+		if len(folder.Links) > 0 {
+			w.write(`<div class="grid">` + "\n")
+
+			for _, link := range folder.Links {
+				img := `<div class="blank"></div>`
+
+				if len(dir) > 0 && isWebURL(link.URL) {
+					src := filepath.ToSlash(filepath.Join(dir, thumbnailName(link.URL)))
+					img = `<img src="` + html.EscapeString(src) + `" alt="" loading="lazy"/>`
+				}
+
+				w.write(`<a href="` + htmlHref(link.URL) + `">` + img +
+					"<span>" + html.EscapeString(link.Name) + "</span></a>\n")
+			}
+
+			w.write("</div>\n")
+		}
+
+		galleryFolders(w, folder.Folders, dir)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGallery(t *testing.T) {
+	folders := []*Folder{{
+		Node:  Node{Name: "Dial"},
+		Links: []*Link{{Node: Node{Name: "Go"}, URL: "https://go.dev/"}, {Node: Node{Name: "JS"}, URL: "javascript:void(0)"}},
+	}}
+
+	dir := t.TempDir()
+	opts := newOptions()
+	opts.outputName = filepath.Join(dir, "out.html")
+	opts.thumbnails = filepath.Join(dir, "thumbs")
+
+	var buf strings.Builder
+
+	if err := foldersToGallery(folders, opts, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s := buf.String()
+
+	if exp := `<a href="https://go.dev/"><img src="thumbs/` + thumbnailName("https://go.dev/") + `"`; !strings.Contains(s, exp) {
+		t.Fatalf("Missing %s in:\n%s", exp, s)
+	}
+
+	if exp := `<a href="javascript:void(0)"><div class="blank"></div>`; !strings.Contains(s, exp) {
+		t.Fatalf("Missing %s in:\n%s", exp, s)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand("tidy", "Sort links and folders inside the Bookmarks file itself", tidyCmd)
+}
+
+// "tidy" command
+func tidyCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("tidy", "")
+
+	opts.inputFlags(fs)
+	opts.writeBackFlags(fs)
+	opts.logFlags(fs)
+
+	var folders stringList
+
+	fs.StringVar(&opts.sortBy, "sort", "name", "Sort by one of: "+tidyKeyNames()+"; prefix with \"-\" for descending order")
+	opts.collateFlag(fs)
+	fs.Var(&folders, "folder", "Only sort this folder, like \"Bookmarks bar/News\", and its sub-folders (may be repeated)")
+	fs.StringVar(&opts.tagRules, "tag-rules", "",
+		"File of rules like \"*.arxiv.org -> Papers\", moving matching links into folders before sorting")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	less, err := tidyOrder(opts.sortBy, opts.collate)
+
+	if err != nil {
+		return err
+	}
+
+	if len(opts.inputs) > 1 || opts.inputs[0].name == stdin {
+		return errors.New("Only one input file is allowed, and it cannot be STDIN")
+	}
+
+	name := opts.inputs[0].name
+	data, err := loadRawData(name)
+
+	if err != nil {
+		return err
+	}
+
+	if !data.sumValid {
+		return errors.New(name + ": Checksum mismatch, the file may be corrupted or edited by hand")
+	}
+
+	var changes []string
+	found := make(map[string]bool, len(folders))
+
+	if len(opts.tagRules) > 0 {
+		rules, err := loadLinkRules(opts.tagRules)
+
+		if err != nil {
+			return err
+		}
+
+		b := &rawBuilder{now: googleTimeStamp(time.Now())}
+
+		walkRawFolders(data.Roots, func(_ []string, node map[string]interface{}) {
+			b.maxID(node)
+		})
+
+		changes = applyRawLinkRules(b, data.Roots, rules, func(path string) bool {
+			return selected(path, folders, found)
+		})
+	}
+
+	// sort
+
+	walkRawFolders(data.Roots, func(path []string, node map[string]interface{}) {
+		p := strings.Join(path, "/")
+
+		if !selected(p, folders, found) {
+			return
+		}
+
+		children, _ := node["children"].([]interface{})
+
+		if sortChildren(children, less) {
+			changes = append(changes, "sorted "+p)
+
+			for _, child := range children {
+				changes = append(changes, "    "+rawString(child, "name"))
+			}
+		}
+	})
+
+	for _, f := range folders {
+		if !found[strings.Trim(f, "/")] {
+			return fmt.Errorf("Folder %q is not found", f)
+		}
+	}
+
+	if len(changes) == 0 {
+		logNotice("nothing to change")
+		return nil
+	}
+
+	if apply, err := opts.confirm(changes); !apply {
+		return err
+	}
+
+	if err = data.save(name); err != nil {
+		return err
+	}
+
+	logNotice("sorted %s; Opera must not be running while the file is replaced", name)
+	return nil
+}
+
+// checks if the folder path is one of the given folders or their sub-folders, recording the folders found
+func selected(path string, folders []string, found map[string]bool) bool {
+	if len(folders) == 0 {
+		return true
+	}
+
+	for _, f := range folders {
+		f = strings.Trim(f, "/")
+
+		if path == f {
+			found[f] = true
+			return true
+		}
+
+		if strings.HasPrefix(path, f+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// calls the function on every folder node in the raw tree, with the folder path
+// named the same way as in findFolder()
+func walkRawFolders(roots interface{}, fn func([]string, map[string]interface{})) {
+	walkRawContainer(roots, nil, fn)
+}
+
+// roots, or container of roots like Opera "custom_root"
+func walkRawContainer(item interface{}, path []string, fn func([]string, map[string]interface{})) {
+	node, _ := item.(map[string]interface{})
+
+	for _, key := range sortedKeys(node) {
+		child, ok := node[key].(map[string]interface{})
+
+		switch _, typed := child["type"]; {
+		case !ok:
+		case typed:
+			walkRawNode(child, path, fn)
+		default:
+			walkRawContainer(child, append(path[:len(path):len(path)], key), fn)
+		}
+	}
+}
+
+func walkRawNode(node map[string]interface{}, path []string, fn func([]string, map[string]interface{})) {
+	if rawString(node, "type") != "folder" {
+		return
+	}
+
+	path = append(path[:len(path):len(path)], rawString(node, "name"))
+	fn(path, node)
+
+	children, _ := node["children"].([]interface{})
+
+	for _, child := range children {
+		if c, ok := child.(map[string]interface{}); ok {
+			walkRawNode(c, path, fn)
+		}
+	}
+}
+
+// sorts folders first, then links, returning true if the order has changed
+func sortChildren(children []interface{}, less func(a, b map[string]interface{}) bool) bool {
+	index := make([]int, len(children))
+
+	for i := range index {
+		index[i] = i
+	}
+
+	sort.SliceStable(index, func(i, j int) bool {
+		a, _ := children[index[i]].(map[string]interface{})
+		b, _ := children[index[j]].(map[string]interface{})
+
+		if fa, fb := rawString(a, "type") == "folder", rawString(b, "type") == "folder"; fa != fb {
+			return fa
+		}
+
+		return less(a, b)
+	})
+
+	sorted := make([]interface{}, len(children))
+	changed := false
+
+	for i, k := range index {
+		sorted[i] = children[k]
+		changed = changed || i != k
+	}
+
+	copy(children, sorted)
+	return changed
+}
+
+// string value from the raw node, or empty string
+func rawString(item interface{}, key string) string {
+	node, _ := item.(map[string]interface{})
+	s, _ := node[key].(string)
+
+	return s
+}
+
+// sort keys for raw nodes
+var tidyKeys = map[string]func(a, b map[string]interface{}) bool{
+	"name": func(a, b map[string]interface{}) bool {
+		return strings.ToLower(rawString(a, "name")) < strings.ToLower(rawString(b, "name"))
+	},
+	"added": func(a, b map[string]interface{}) bool {
+		ta, _ := strconv.ParseInt(rawString(a, "date_added"), 10, 64)
+		tb, _ := strconv.ParseInt(rawString(b, "date_added"), 10, 64)
+
+		return ta < tb
+	},
+}
+
+func tidyKeyNames() string {
+	names := make([]string, 0, len(tidyKeys))
+
+	for name := range tidyKeys {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func tidyOrder(s, lang string) (func(a, b map[string]interface{}) bool, error) {
+	key := strings.TrimPrefix(s, "-")
+	less := tidyKeys[key]
+
+	if less == nil {
+		return nil, fmt.Errorf("Unknown sort key %q", s)
+	}
+
+	if key == "name" && len(lang) > 0 {
+		names, err := nameOrder(lang)
+
+		if err != nil {
+			return nil, err
+		}
+
+		less = func(a, b map[string]interface{}) bool { return names(rawString(a, "name"), rawString(b, "name")) }
+	}
+
+	if strings.HasPrefix(s, "-") {
+		return func(a, b map[string]interface{}) bool { return less(b, a) }, nil
+	}
+
+	return less, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTidy(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "0", "date_modified": "0", "children": [
+			{"type": "url", "name": "b", "url": "https://b/", "id": "2", "date_added": "3"},
+			{"type": "url", "name": "A", "url": "https://a/", "id": "3", "date_added": "2"},
+			{"type": "folder", "name": "Z", "id": "4", "date_added": "1", "date_modified": "0", "children": [
+				{"type": "url", "name": "y", "url": "https://y/", "id": "5", "date_added": "2"},
+				{"type": "url", "name": "x", "url": "https://x/", "id": "6", "date_added": "1"}
+			]}
+		]},
+		"custom_root": {"speedDial": {"type": "folder", "name": "Speed Dial", "id": "7", "date_added": "0",
+			"date_modified": "0", "children": [
+				{"type": "url", "name": "d", "url": "https://d/", "id": "8", "date_added": "0"},
+				{"type": "url", "name": "c", "url": "https://c/", "id": "9", "date_added": "0"}
+			]}}
+	}}`
+
+	name := filepath.Join(t.TempDir(), "Bookmarks")
+
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tidyCmd([]string{"-q", "-i", name, "--folder", "Bar"}); err == nil {
+		t.Fatal("Changed without confirmation")
+	}
+
+	if err := tidyCmd([]string{"-q", "-i", name, "--folder", "Nonsense", "--yes"}); err == nil {
+		t.Fatal("Missing folder is not reported")
+	}
+
+	if err := tidyCmd([]string{"-q", "-i", name, "--folder", "Bar", "--yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := loadRawData(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !data.sumValid {
+		t.Fatal("Invalid checksum")
+	}
+
+	// children names per folder
+	res := make(map[string][]string)
+
+	walkRawFolders(data.Roots, func(path []string, node map[string]interface{}) {
+		for _, child := range node["children"].([]interface{}) {
+			res[strings.Join(path, "/")] = append(res[strings.Join(path, "/")], rawString(child, "name"))
+		}
+	})
+
+	exp := map[string][]string{
+		"Bar":                    {"Z", "A", "b"},
+		"Bar/Z":                  {"x", "y"},
+		"custom_root/Speed Dial": {"d", "c"}, // not selected
+	}
+
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("Unexpected result: %q", res)
+	}
+}
+
+func TestWalkRawFolders(t *testing.T) {
+	data, err := decodeRawData("test", strings.NewReader(`{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "children": [{"type": "folder", "name": "Sub"}]},
+		"custom_root": {"speedDial": {"type": "folder", "name": "Speed Dial"}}
+	}}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+
+	walkRawFolders(data.Roots, func(path []string, _ map[string]interface{}) {
+		paths = append(paths, filepath.Join(path...))
+	})
+
+	if exp := []string{"Bar", "Bar/Sub", "custom_root/Speed Dial"}; !reflect.DeepEqual(paths, exp) {
+		t.Fatalf("Unexpected paths: %q", paths)
+	}
+}
@@ -0,0 +1,582 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// applies all the requested transformations to the trees
+func (opts *options) transform(roots []*Folder) error {
+	var q *query
+
+	if len(opts.query) > 0 {
+		var err error
+
+		if q, err = parseQuery(opts.query, opts.collate); err != nil {
+			return err
+		}
+
+		if len(q.fields) > 0 {
+			if opts.format != "csv" && opts.format != "jsonl" {
+				return errors.New("Query \"select\" is only supported with csv and jsonl formats")
+			}
+
+			opts.projection = q
+		}
+	}
+
+	for _, root := range roots {
+		renameRoots(root, opts.rootNames)
+	}
+
+	if opts.cleanURLs || len(opts.stripParams) > 0 {
+		params := append(append([]string(nil), trackingParams...), opts.stripParams...)
+
+		for _, root := range roots {
+			for _, link := range root.allLinks() {
+				link.URL = cleanURL(link.URL, params)
+			}
+		}
+	}
+
+	if opts.normalizeNames {
+		for _, root := range roots {
+			normalizeNames(root)
+		}
+	}
+
+	if opts.deriveTitles {
+		for _, root := range roots {
+			for _, link := range root.allLinks() {
+				if len(strings.TrimSpace(link.Name)) == 0 {
+					link.Name = urlTitle(link.URL)
+				}
+			}
+		}
+	}
+
+	if len(opts.tagRules) > 0 {
+		rules, err := loadLinkRules(opts.tagRules)
+
+		if err != nil {
+			return err
+		}
+
+		for _, root := range roots {
+			logInfo("%s: moved %d links by rules", root.Name, applyLinkRules(root, rules))
+		}
+	}
+
+	if opts.onlyBookmarklets || opts.noBookmarklets {
+		for _, root := range roots {
+			filterLinks(root, func(link *Link) bool { return isBookmarklet(link.URL) == opts.onlyBookmarklets })
+		}
+	}
+
+	if len(opts.paths) > 0 {
+		for _, p := range opts.paths {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("Invalid path pattern %q", p)
+			}
+		}
+
+		for _, root := range roots {
+			keep := make(map[*Link]bool)
+
+			for _, p := range opts.paths {
+				for _, link := range root.Glob(p) {
+					keep[link] = true
+				}
+			}
+
+			filterLinks(root, func(link *Link) bool { return keep[link] })
+		}
+	}
+
+	if opts.mergeFolders {
+		for _, root := range roots {
+			mergeFolders(root)
+		}
+	}
+
+	if opts.maxDepth > 0 {
+		for _, root := range roots {
+			limitDepth(root, opts.maxDepth)
+		}
+	}
+
+	if opts.maxPerFolder > 0 {
+		for _, root := range roots {
+			limitLinks(root, opts.maxPerFolder)
+		}
+	}
+
+	if len(opts.history) > 0 {
+		if err := addHistory(opts.history, roots); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.refreshTitles) > 0 || opts.descriptions {
+		pages, err := opts.fetchPages(roots)
+
+		if err != nil {
+			return err
+		}
+
+		if len(opts.refreshTitles) > 0 {
+			refreshTitles(roots, pages, opts.refreshTitles == "fix")
+		}
+
+		if opts.descriptions {
+			addDescriptions(roots, pages)
+		}
+	}
+
+	if opts.embedFavicons {
+		var err error
+
+		if opts.favicons, err = opts.fetchFavicons(roots); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.thumbnails) > 0 {
+		if err := opts.captureThumbnails(roots); err != nil {
+			return err
+		}
+	}
+
+	if q != nil {
+		for _, root := range roots {
+			q.apply(root)
+		}
+	}
+
+	if len(opts.sortBy) > 0 {
+		order, err := parseSortOrder(opts.sortBy, opts.collate)
+
+		if err != nil {
+			return err
+		}
+
+		for _, root := range roots {
+			order.apply(root)
+		}
+	}
+
+	return nil
+}
+
+// display names of the roots and containers of roots, used when a root has no name of its own
+var defaultRootNames = map[string]string{
+	"bookmark_bar": "Bookmarks bar",
+	"other":        "Other bookmarks",
+	"synced":       "Mobile bookmarks",
+	"custom_root":  "Opera",
+	"speedDial":    "Speed Dial",
+	"trash":        "Trash",
+	"unsorted":     "Unsorted bookmarks",
+	"userRoot":     "User bookmarks",
+}
+
+// gives the roots display names instead of the internal keys, the given names taking precedence
+func renameRoots(container *Folder, names map[string]string) {
+	for _, f := range container.Folders {
+		if name, ok := names[f.Key]; ok {
+			f.Name = name
+		} else if name, ok = defaultRootNames[f.Key]; ok && (f.container || len(f.Name) == 0) {
+			f.Name = name
+		}
+
+		if f.container {
+			renameRoots(f, names)
+		}
+	}
+}
+
+// sorting
+type sortOrder struct {
+	key   string
+	less  func(a, b *Link) bool
+	names func(a, b string) bool // order of folder names
+	desc  bool
+}
+
+var sortKeys = map[string]func(a, b *Link) bool{
+	"name": func(a, b *Link) bool {
+		return lessName(&a.Node, &b.Node)
+	},
+	"added": func(a, b *Link) bool {
+		return a.Added.Before(b.Added)
+	},
+	"visits": func(a, b *Link) bool {
+		return a.Visits < b.Visits
+	},
+	"visited": func(a, b *Link) bool {
+		return a.LastVisit.Before(b.LastVisit)
+	},
+}
+
+func sortKeyNames() string {
+	names := make([]string, 0, len(sortKeys))
+
+	for name := range sortKeys {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parses the sort key; names are compared by the collation rules of the language, if given
+func parseSortOrder(s, lang string) (*sortOrder, error) {
+	order := &sortOrder{key: strings.TrimPrefix(s, "-"), desc: strings.HasPrefix(s, "-")}
+
+	if order.less = sortKeys[order.key]; order.less == nil {
+		return nil, fmt.Errorf("Unknown sort key %q", s)
+	}
+
+	var err error
+
+	if order.names, err = nameOrder(lang); err != nil {
+		return nil, err
+	}
+
+	if order.key == "name" && len(lang) > 0 {
+		order.less = func(a, b *Link) bool { return order.names(a.Name, b.Name) }
+	}
+
+	return order, nil
+}
+
+func lessName(a, b *Node) bool {
+	return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+}
+
+// compares names ignoring case, either by the collation rules of the language, like "de" or "sv"
+// ("und" for the rules common to all languages), or by character codes if no language is given
+func nameOrder(lang string) (func(a, b string) bool, error) {
+	if len(lang) == 0 {
+		return func(a, b string) bool { return strings.ToLower(a) < strings.ToLower(b) }, nil
+	}
+
+	tag, err := language.Parse(lang)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid collation language %q", lang)
+	}
+
+	c := collate.New(tag, collate.IgnoreCase)
+
+	return func(a, b string) bool { return c.CompareString(a, b) < 0 }, nil
+}
+
+// sorts links in every folder; folders themselves are sorted by name or date added, if that is the key
+func (order *sortOrder) apply(folder *Folder) {
+	sort.SliceStable(folder.Links, func(i, j int) bool {
+		if order.desc {
+			return order.less(folder.Links[j], folder.Links[i])
+		}
+
+		return order.less(folder.Links[i], folder.Links[j])
+	})
+
+	var less func(a, b *Node) bool
+
+	switch order.key {
+	case "name":
+		less = func(a, b *Node) bool { return order.names(a.Name, b.Name) }
+	case "added":
+		less = func(a, b *Node) bool { return a.Added.Before(b.Added) }
+	}
+
+	if less != nil {
+		sort.SliceStable(folder.Folders, func(i, j int) bool {
+			if order.desc {
+				return less(&folder.Folders[j].Node, &folder.Folders[i].Node)
+			}
+
+			return less(&folder.Folders[i].Node, &folder.Folders[j].Node)
+		})
+	}
+
+	for _, f := range folder.Folders {
+		order.apply(f)
+	}
+}
+
+// converts names of all folders and links to NFC form, with any white space folded
+// into a single space and invisible characters removed
+func normalizeNames(folder *Folder) {
+	folder.Name = normalizeName(folder.Name)
+
+	for _, link := range folder.Links {
+		link.Name = normalizeName(link.Name)
+	}
+
+	for _, f := range folder.Folders {
+		normalizeNames(f)
+	}
+}
+
+func normalizeName(s string) string {
+	return foldSpaces(norm.NFC.String(strings.Map(dropInvisible, s)))
+}
+
+// zero width spaces and joiners, soft hyphen and byte order mark
+func dropInvisible(r rune) rune {
+	switch r {
+	case '\u00ad', '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return -1
+	}
+
+	return r
+}
+
+// query parameters removed by --clean-urls; a trailing "*" matches any suffix
+var trackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_hsenc", "_hsmi", "igshid",
+}
+
+// display title made from the URL, like "example.com / blog / my post" for
+// "https://www.example.com/blog/my-post.html"
+func urlTitle(s string) string {
+	u, err := url.Parse(s)
+
+	if err != nil || len(u.Host) == 0 {
+		return s
+	}
+
+	parts := []string{strings.TrimPrefix(u.Hostname(), "www.")}
+
+	for _, p := range strings.Split(u.Path, "/") {
+		if ext := path.Ext(p); urlTitleExts[ext] {
+			p = p[:len(p)-len(ext)]
+		}
+
+		if p = strings.TrimSpace(urlTitleSpaces.Replace(p)); len(p) > 0 && p != "index" {
+			parts = append(parts, p)
+		}
+	}
+
+	return strings.Join(parts, " / ")
+}
+
+var urlTitleExts = map[string]bool{".html": true, ".htm": true, ".php": true, ".asp": true, ".aspx": true}
+
+var urlTitleSpaces = strings.NewReplacer("-", " ", "_", " ", "+", " ")
+
+// removes the given query parameters from a web URL
+func cleanURL(s string, params []string) string {
+	if !isWebURL(s) {
+		return s
+	}
+
+	// the URL is not re-encoded, only the query is cut
+	end := len(s)
+
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		end = i
+	}
+
+	start := strings.IndexByte(s[:end], '?')
+
+	if start < 0 {
+		return s
+	}
+
+	var keep []string
+
+	for _, param := range strings.Split(s[start+1:end], "&") {
+		name := param
+
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name = param[:i]
+		}
+
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+
+		if len(param) > 0 && !matchParam(name, params) {
+			keep = append(keep, param)
+		}
+	}
+
+	query := ""
+
+	if len(keep) > 0 {
+		query = "?" + strings.Join(keep, "&")
+	}
+
+	return s[:start] + query + s[end:]
+}
+
+func matchParam(name string, params []string) bool {
+	for _, p := range params {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(name, p[:len(p)-1]) || name == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// merges sibling folders with the same name, keeping the earliest date added
+func mergeFolders(folder *Folder) {
+	byName := make(map[string]*Folder, len(folder.Folders))
+	folders := folder.Folders[:0]
+
+	for _, f := range folder.Folders {
+		first, ok := byName[f.Name]
+
+		if !ok {
+			byName[f.Name] = f
+			folders = append(folders, f)
+			continue
+		}
+
+		first.Links = append(first.Links, f.Links...)
+		first.Folders = append(first.Folders, f.Folders...)
+
+		if !f.Added.IsZero() && (first.Added.IsZero() || f.Added.Before(first.Added)) {
+			first.Added = f.Added
+		}
+
+		if f.Modified.After(first.Modified) {
+			first.Modified = f.Modified
+		}
+	}
+
+	folder.Folders = folders
+
+	for _, f := range folders {
+		mergeFolders(f)
+	}
+}
+
+// removes links not satisfying the predicate, and folders left empty after that;
+// returns the number of links removed
+func filterLinks(folder *Folder, keep func(*Link) bool) (removed int) {
+	links := folder.Links[:0]
+
+	for _, link := range folder.Links {
+		if keep(link) {
+			links = append(links, link)
+		} else {
+			removed++
+		}
+	}
+
+	folder.Links = links
+	folders := folder.Folders[:0]
+
+	for _, f := range folder.Folders {
+		n := filterLinks(f, keep)
+
+		if n == 0 || len(f.Links) > 0 || len(f.Folders) > 0 {
+			folders = append(folders, f)
+		}
+
+		removed += n
+	}
+
+	folder.Folders = folders
+	return
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cuts off folders deeper than the given number of levels, counting what is omitted
+func limitDepth(folder *Folder, levels int) {
+	if levels > 0 {
+		for _, f := range folder.Folders {
+			limitDepth(f, levels-1)
+		}
+
+		return
+	}
+
+	for _, f := range folder.Folders {
+		nf, nl := f.count()
+
+		folder.OmittedFolders += nf + 1
+		folder.OmittedLinks += nl
+	}
+
+	folder.Folders = nil
+}
+
+// keeps only the given number of most recently added links in every folder, in their original order
+func limitLinks(folder *Folder, n int) {
+	if len(folder.Links) > n {
+		newest := append([]*Link(nil), folder.Links...)
+
+		sort.SliceStable(newest, func(i, j int) bool { return newest[i].Added.After(newest[j].Added) })
+
+		keep := make(map[*Link]bool, n)
+
+		for _, link := range newest[:n] {
+			keep[link] = true
+		}
+
+		links := folder.Links[:0]
+
+		for _, link := range folder.Links {
+			if keep[link] {
+				links = append(links, link)
+			}
+		}
+
+		folder.Links = links
+	}
+
+	for _, f := range folder.Folders {
+		limitLinks(f, n)
+	}
+}
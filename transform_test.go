@@ -0,0 +1,235 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := map[string]string{
+		"Cafe\u0301":                 "Caf\u00e9",
+		"\u00a0Go \u2003 Blog\t\n":   "Go Blog",
+		"zero\u200bwidth\ufeff":      "zerowidth",
+		"ideographic\u3000space":     "ideographic space",
+		"soft\u00adhyphen\u2009thin": "softhyphen thin",
+		"A\u030a ngstro\u0308m":      "Å ngström",
+	}
+
+	for src, exp := range cases {
+		if res := normalizeName(src); res != exp {
+			t.Errorf("%q: got %q instead of %q", src, res, exp)
+		}
+	}
+}
+
+func TestCleanURL(t *testing.T) {
+	params := append(trackingParams, "ref")
+
+	cases := map[string]string{
+		"https://example.com/a?utm_source=x&id=1&fbclid=y#top": "https://example.com/a?id=1#top",
+		"https://example.com/a?utm_source=x&utm_medium=y":      "https://example.com/a",
+		"https://example.com/a?ref=1&referrer=2":               "https://example.com/a?referrer=2",
+		"https://example.com/a?q=a%20b&gclid=z":                "https://example.com/a?q=a%20b",
+		"https://example.com/#/route?utm_source=x":             "https://example.com/#/route?utm_source=x",
+		"javascript:f('?utm_source=x')":                        "javascript:f('?utm_source=x')",
+		"https://example.com/a?":                               "https://example.com/a",
+	}
+
+	for src, exp := range cases {
+		if res := cleanURL(src, params); res != exp {
+			t.Errorf("%q: got %q instead of %q", src, res, exp)
+		}
+	}
+}
+
+func TestMergeFolders(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	link := &Link{Node: Node{Name: "L"}}
+	sub1 := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{link}}
+	sub2 := &Folder{Node: Node{Name: "Sub"}, Links: []*Link{link}}
+
+	root := &Folder{Folders: []*Folder{
+		{Node: Node{Name: "A", Added: day(5), Modified: day(6)}, Folders: []*Folder{sub1}},
+		{Node: Node{Name: "B"}},
+		{Node: Node{Name: "A", Added: day(2), Modified: day(3)}, Links: []*Link{link}, Folders: []*Folder{sub2}},
+	}}
+
+	mergeFolders(root)
+
+	if len(root.Folders) != 2 || root.Folders[0].Name != "A" || root.Folders[1].Name != "B" {
+		t.Fatalf("Unexpected folders: %v", root.Folders)
+	}
+
+	a := root.Folders[0]
+
+	if !a.Added.Equal(day(2)) || !a.Modified.Equal(day(6)) {
+		t.Errorf("Unexpected dates: %s, %s", a.Added, a.Modified)
+	}
+
+	if len(a.Links) != 1 || len(a.Folders) != 1 || len(a.Folders[0].Links) != 2 {
+		t.Errorf("Unexpected content: %d links, %d folders", len(a.Links), len(a.Folders))
+	}
+}
+
+func TestFilterLinks(t *testing.T) {
+	js := &Link{URL: "JavaScript:void(0)"}
+	web := &Link{URL: "https://example.com/"}
+
+	root := &Folder{
+		Links: []*Link{web, js},
+		Folders: []*Folder{
+			{Node: Node{Name: "Web"}, Links: []*Link{web}},
+			{Node: Node{Name: "Empty"}},
+		},
+	}
+
+	if n := filterLinks(root, func(link *Link) bool { return isBookmarklet(link.URL) }); n != 2 {
+		t.Errorf("Unexpected number of removed links: %d", n)
+	}
+
+	if len(root.Links) != 1 || root.Links[0] != js {
+		t.Errorf("Unexpected links: %v", root.Links)
+	}
+
+	// originally empty folder stays
+	if len(root.Folders) != 1 || root.Folders[0].Name != "Empty" {
+		t.Errorf("Unexpected folders: %v", root.Folders)
+	}
+}
+
+func TestLimitDepth(t *testing.T) {
+	link := &Link{}
+	deep := &Folder{Links: []*Link{link, link}}
+	sub := &Folder{Links: []*Link{link}, Folders: []*Folder{deep}}
+	top := &Folder{Links: []*Link{link}, Folders: []*Folder{sub, {}}}
+	root := &Folder{Folders: []*Folder{top}}
+
+	limitDepth(root, 1)
+
+	if len(top.Folders) != 0 || top.OmittedFolders != 3 || top.OmittedLinks != 3 {
+		t.Fatalf("Unexpected result: %d folders, %d omitted folders, %d omitted links",
+			len(top.Folders), top.OmittedFolders, top.OmittedLinks)
+	}
+
+	if s := top.omitted(); s != "3 more links in 3 sub-folders" {
+		t.Fatalf("Unexpected summary: %q", s)
+	}
+}
+
+func TestLimitLinks(t *testing.T) {
+	link := func(name string, day int) *Link {
+		return &Link{Node: Node{Name: name, Added: time.Date(2020, 1, day, 0, 0, 0, 0, time.UTC)}}
+	}
+
+	sub := &Folder{Links: []*Link{link("x", 1)}}
+	root := &Folder{
+		Links:   []*Link{link("a", 3), link("b", 1), link("c", 5), link("d", 4)},
+		Folders: []*Folder{sub},
+	}
+
+	limitLinks(root, 2)
+
+	if len(root.Links) != 2 || root.Links[0].Name != "c" || root.Links[1].Name != "d" {
+		t.Fatalf("Unexpected links: %v", root.Links)
+	}
+
+	if len(sub.Links) != 1 {
+		t.Fatalf("Unexpected links: %v", sub.Links)
+	}
+}
+
+func TestRenameRoots(t *testing.T) {
+	const src = `{"roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "date_added": "0", "children": []},
+		"other": {"type": "folder", "name": "", "date_added": "0", "children": []},
+		"custom_root": {"trash": {"type": "folder", "name": "Trash", "date_added": "0", "children": []}}
+	}}`
+
+	root, _, err := newTreeDecoder(strings.NewReader(src), readOptions{}).decode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renameRoots(root, stringMap{"trash": "Deleted"})
+
+	names := make(map[string]string)
+
+	for _, f := range root.Folders {
+		names[f.Key] = f.Name
+
+		for _, c := range f.Folders {
+			names[c.Key] = c.Name
+		}
+	}
+
+	exp := map[string]string{
+		"bookmark_bar": "Bar",
+		"other":        "Other bookmarks",
+		"custom_root":  "Opera",
+		"trash":        "Deleted",
+	}
+
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Unexpected names: %v", names)
+	}
+}
+
+func TestURLTitle(t *testing.T) {
+	cases := map[string]string{
+		"https://www.example.com/blog/my-post.html": "example.com / blog / my post",
+		"https://example.com/":                      "example.com",
+		"http://host:8080/a_b/index.php?x=1":        "host / a b",
+		"https://example.com/caf%C3%A9/":            "example.com / café",
+		"javascript:alert(1)":                       "javascript:alert(1)",
+	}
+
+	for src, exp := range cases {
+		if res := urlTitle(src); res != exp {
+			t.Errorf("%q: got %q instead of %q", src, res, exp)
+		}
+	}
+}
+
+func TestCollation(t *testing.T) {
+	tests := []struct{ lang, exp string }{
+		{"", "apple Zebra Äpfel"},
+		{"de", "Äpfel apple Zebra"},
+		{"sv", "apple Zebra Äpfel"},
+	}
+
+	for _, test := range tests {
+		root := &Folder{
+			Links:   []*Link{{Node: Node{Name: "Zebra"}}, {Node: Node{Name: "Äpfel"}}, {Node: Node{Name: "apple"}}},
+			Folders: []*Folder{{Node: Node{Name: "Zebra"}}, {Node: Node{Name: "Äpfel"}}, {Node: Node{Name: "apple"}}},
+		}
+
+		order, err := parseSortOrder("name", test.lang)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		order.apply(root)
+
+		var links, folders []string
+
+		for i := range root.Links {
+			links = append(links, root.Links[i].Name)
+			folders = append(folders, root.Folders[i].Name)
+		}
+
+		if strings.Join(links, " ") != test.exp || strings.Join(folders, " ") != test.exp {
+			t.Errorf("%q: unexpected order %q, %q", test.lang, links, folders)
+		}
+	}
+
+	if _, err := parseSortOrder("name", "not a language"); err == nil {
+		t.Error("Invalid language accepted")
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerCommand("validate", "Check the Bookmarks file against the Chromium schema, reporting all violations", validateCmd)
+}
+
+// "validate" command
+func validateCmd(args []string) error {
+	opts := newOptions()
+	fs := newFlagSet("validate", "")
+
+	opts.inputFlags(fs)
+	opts.logFlags(fs)
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	if len(opts.inputs) > 1 {
+		return errors.New("Only one input file is allowed")
+	}
+
+	data, err := loadRawData(opts.inputs[0].name)
+
+	if err != nil {
+		return err
+	}
+
+	errs := validateRawData(data, time.Now())
+
+	for _, e := range errs {
+		fmt.Println(e)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %d schema violations found", data.name, len(errs))
+	}
+
+	logInfo("%s is valid", data.name)
+	return nil
+}
+
+// schema checker, collecting all violations
+type validator struct {
+	errs []string
+	ids  map[string]string // node id -> node path
+	now  time.Time
+}
+
+// checks the Bookmarks file, returning the list of violations
+func validateRawData(data *rawData, now time.Time) []string {
+	v := &validator{ids: make(map[string]string), now: now}
+
+	// top level
+	if !checksumRe.MatchString(data.Checksum) {
+		v.fail("checksum", "Tag \"checksum\" is missing or not an MD5 hex digest")
+	} else if !data.sumValid {
+		v.fail("checksum", "Checksum mismatch")
+	}
+
+	if n, err := strconv.Atoi(string(data.Version)); err != nil || n != 1 {
+		v.fail("version", fmt.Sprintf("Unsupported version %s", data.Version))
+	}
+
+	roots, ok := data.Roots.(map[string]interface{})
+
+	if !ok {
+		v.fail("roots", "Tag \"roots\" is missing or not an object")
+		return v.errs
+	}
+
+	for _, key := range standardRoots {
+		if _, ok := roots[key]; !ok {
+			v.fail("roots/"+key, "Root folder is missing")
+		}
+	}
+
+	v.roots(roots, "roots")
+	return v.errs
+}
+
+var checksumRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func (v *validator) fail(path, msg string) {
+	v.errs = append(v.errs, (&ParserError{path: path, msg: msg}).Error())
+}
+
+// roots, or container of roots like Opera "custom_root"
+func (v *validator) roots(roots map[string]interface{}, path string) {
+	for _, key := range sortedKeys(roots) {
+		p := path + "/" + key
+		node, ok := roots[key].(map[string]interface{})
+
+		switch _, typed := node["type"]; {
+		case !ok:
+			v.fail(p, "Root is not an object")
+		case typed:
+			if v.node(node, p) == "url" {
+				v.fail(p, "Root is not a folder")
+			}
+		case isStandardRoot(key) || len(path) > len("roots"):
+			v.fail(p, "Tag \"type\" is not found")
+		default:
+			v.roots(node, p)
+		}
+	}
+}
+
+// checks the node and its children, returning the node type
+func (v *validator) node(node map[string]interface{}, path string) string {
+	if id, ok := v.str(node, "id", path, true); ok {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			v.fail(path, fmt.Sprintf("Invalid id %q", id))
+		} else if other, dup := v.ids[id]; dup {
+			v.fail(path, fmt.Sprintf("Duplicate id %q, also used by node %s", id, other))
+		} else {
+			v.ids[id] = path
+		}
+	}
+
+	v.str(node, "name", path, true)
+	v.str(node, "guid", path, false)
+	v.timeStamp(node, "date_added", path, true)
+	v.timeStamp(node, "date_last_used", path, false)
+
+	if meta, ok := node["meta_info"]; ok {
+		m, ok := meta.(map[string]interface{})
+
+		if !ok {
+			v.fail(path, "Tag \"meta_info\" is not an object")
+		}
+
+		for _, key := range sortedKeys(m) {
+			if _, ok := m[key].(string); !ok {
+				v.fail(path+"/meta_info", fmt.Sprintf("Value for tag %q is not a string", key))
+			}
+		}
+	}
+
+	typ, _ := v.str(node, "type", path, true)
+
+	switch typ {
+	case "url":
+		if s, ok := v.str(node, "url", path, true); ok {
+			if u, err := url.Parse(s); err != nil || len(u.Scheme) == 0 {
+				v.fail(path, fmt.Sprintf("Invalid URL %q", s))
+			}
+		}
+	case "folder":
+		v.timeStamp(node, "date_modified", path, false)
+
+		children, ok := node["children"].([]interface{})
+
+		if !ok {
+			v.fail(path, "Tag \"children\" is missing or not an array")
+		}
+
+		for i, item := range children {
+			p := path + "/children/#" + strconv.Itoa(i)
+
+			if child, ok := item.(map[string]interface{}); ok {
+				v.node(child, p)
+			} else {
+				v.fail(p, "Node is not an object")
+			}
+		}
+	case "":
+	default:
+		v.fail(path, fmt.Sprintf("Unknown node type %q", typ))
+	}
+
+	return typ
+}
+
+// checks that the tag is a string, if present or required
+func (v *validator) str(node map[string]interface{}, key, path string, required bool) (string, bool) {
+	s, err := readString(key, node)
+
+	if _, missing := err.(KeyNotFoundError); missing && !required {
+		return "", false
+	}
+
+	if err != nil {
+		v.fail(path, err.Error())
+		return "", false
+	}
+
+	return s, true
+}
+
+// the earliest plausible bookmark time
+var minBookmarkTime = time.Date(1995, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// checks that the tag is a timestamp, either zero (not set) or within a plausible range
+func (v *validator) timeStamp(node map[string]interface{}, key, path string, required bool) {
+	if _, ok := v.str(node, key, path, required); !ok {
+		return
+	}
+
+	val, err := readInt(key, node, 64)
+
+	switch {
+	case err != nil:
+		v.fail(path, err.Error())
+	case val == 0:
+	case val < 0:
+		v.fail(path, fmt.Sprintf("Negative timestamp in tag %q", key))
+	default:
+		if ts := googleTime(val); ts.Before(minBookmarkTime) || ts.After(v.now.Add(24*time.Hour)) {
+			v.fail(path, fmt.Sprintf("Timestamp in tag %q is out of range: %s", key, ts.Format(time.RFC3339)))
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	const src = `{"checksum": "0123", "version": 2, "roots": {
+		"bookmark_bar": {"type": "folder", "name": "Bar", "id": "1", "date_added": "13100000000000000", "children": [
+			{"type": "url", "name": "A", "url": "https://a/", "id": "2", "date_added": "99999999999999999"},
+			{"type": "url", "name": "B", "url": "b", "id": "2", "date_added": "0"},
+			{"type": "link", "name": "C", "id": "4", "date_added": "x"},
+			{"type": "folder", "name": 5, "id": "5", "date_added": "0", "meta_info": {"a": 1}}
+		]},
+		"other": {"type": "url", "name": "Other", "url": "https://o/", "id": "6", "date_added": "0"},
+		"custom_root": {"speedDial": {"type": "folder", "name": "SD", "id": "7", "date_added": "0", "children": []}}
+	}}`
+
+	data, err := decodeRawData("test", strings.NewReader(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := validateRawData(data, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	exp := []string{
+		`Node checksum: Tag "checksum" is missing or not an MD5 hex digest`,
+		`Node version: Unsupported version 2`,
+		`Node roots/synced: Root folder is missing`,
+		`Node roots/bookmark_bar/children/#0: Timestamp in tag "date_added" is out of range: 4769-11-16T09:46:39Z`,
+		`Node roots/bookmark_bar/children/#1: Duplicate id "2", also used by node roots/bookmark_bar/children/#0`,
+		`Node roots/bookmark_bar/children/#1: Invalid URL "b"`,
+		`Node roots/bookmark_bar/children/#2: Value for tag "date_added" is not an integer: "x"`,
+		`Node roots/bookmark_bar/children/#2: Unknown node type "link"`,
+		`Node roots/bookmark_bar/children/#3: Tag "name" is not a string`,
+		`Node roots/bookmark_bar/children/#3/meta_info: Value for tag "a" is not a string`,
+		`Node roots/bookmark_bar/children/#3: Tag "children" is missing or not an array`,
+		`Node roots/other: Root is not a folder`,
+	}
+
+	if strings.Join(errs, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("Unexpected violations:\n%s", strings.Join(errs, "\n"))
+	}
+
+	// valid file, apart from the missing roots
+	if data, err = decodeRawData("test", strings.NewReader(testBookmarks("v1"))); err != nil {
+		t.Fatal(err)
+	}
+
+	data.Checksum, data.Version = rootsChecksum(data.Roots, false), []byte("1")
+
+	if errs = validateRawData(data, time.Now()); len(errs) != 2 {
+		t.Fatalf("Unexpected violations: %q", errs)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// tree traversal order
+type WalkOrder int
+
+const (
+	DepthFirst WalkOrder = iota
+	BreadthFirst
+)
+
+// function called for every node of the tree, with the names of the folders on the path
+// to the node, starting from the children of the walked folder; the node is either *Folder or *Link;
+// the path slice may be shared between calls, and must not be modified
+type WalkFunc func(path []string, node interface{}) error
+
+// SkipFolder returned from WalkFunc for a folder skips the contents of the folder
+var SkipFolder = errors.New("Skip this folder")
+
+// Walk calls fn for every folder and link below the folder, depth first,
+// with links of each folder before its sub-folders; the folder itself is not visited
+func (folder *Folder) Walk(fn WalkFunc) error {
+	return folder.WalkOrder(DepthFirst, fn)
+}
+
+// WalkOrder is Walk with the given traversal order
+func (folder *Folder) WalkOrder(order WalkOrder, fn WalkFunc) error {
+	switch order {
+	case DepthFirst:
+		return walkDepthFirst(folder, nil, fn)
+	case BreadthFirst:
+		return walkBreadthFirst(folder, fn)
+	default:
+		return errors.New("Invalid walk order")
+	}
+}
+
+func walkDepthFirst(folder *Folder, path []string, fn WalkFunc) error {
+	for _, link := range folder.Links {
+		if err := fn(path, link); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range folder.Folders {
+		switch err := fn(path, f); err {
+		case nil:
+			if err = walkDepthFirst(f, append(path[:len(path):len(path)], f.Name), fn); err != nil {
+				return err
+			}
+		case SkipFolder:
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkBreadthFirst(folder *Folder, fn WalkFunc) error {
+	type item struct {
+		folder *Folder
+		path   []string
+	}
+
+	for queue := []item{{folder, nil}}; len(queue) > 0; queue = queue[1:] {
+		folder, path := queue[0].folder, queue[0].path
+
+		for _, link := range folder.Links {
+			if err := fn(path, link); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range folder.Folders {
+			switch err := fn(path, f); err {
+			case nil:
+				queue = append(queue, item{f, append(path[:len(path):len(path)], f.Name)})
+			case SkipFolder:
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Find returns the folder at the given path of folder names like "Bookmarks bar/News",
+// starting from the children of the folder
+func (folder *Folder) Find(path string) (*Folder, error) {
+	f := folder
+
+next:
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		for _, child := range f.Folders {
+			if child.Name == name {
+				f = child
+				continue next
+			}
+		}
+
+		return nil, fmt.Errorf("Folder %q is not found", path)
+	}
+
+	return f, nil
+}
+
+// finds the sub-folder by the names of the folders on the path, making the missing folders
+func (folder *Folder) subFolder(names []string) *Folder {
+	f := folder
+
+next:
+	for _, name := range names {
+		for _, child := range f.Folders {
+			if child.Name == name {
+				f = child
+				continue next
+			}
+		}
+
+		child := &Folder{Node: Node{Name: name}}
+		f.Folders = append(f.Folders, child)
+		f = child
+	}
+
+	return f
+}
+
+// Glob returns all links with the path, made of the folder names starting from the children
+// of the folder, and the link name, matching the pattern like "Bookmarks bar/*/Go*",
+// where "*" does not match "/"; see path.Match for the pattern syntax
+func (folder *Folder) Glob(pattern string) (links []*Link) {
+	pattern = strings.Trim(pattern, "/")
+
+	folder.Walk(func(names []string, node interface{}) error {
+		if link, ok := node.(*Link); ok {
+			if match, _ := path.Match(pattern, strings.Join(append(names[:len(names):len(names)], link.Name), "/")); match {
+				links = append(links, link)
+			}
+		}
+
+		return nil
+	})
+
+	return
+}
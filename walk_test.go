@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	c := &Folder{Node: Node{Name: "C"}, Links: []*Link{{Node: Node{Name: "c1"}}}}
+	b := &Folder{Node: Node{Name: "B"}, Folders: []*Folder{c}, Links: []*Link{{Node: Node{Name: "b1"}}}}
+	d := &Folder{Node: Node{Name: "D"}, Links: []*Link{{Node: Node{Name: "d1"}}}}
+	root := &Folder{Folders: []*Folder{b, d}, Links: []*Link{{Node: Node{Name: "r1"}}}}
+
+	walk := func(order WalkOrder, skip string) string {
+		var res []string
+
+		err := root.WalkOrder(order, func(path []string, node interface{}) error {
+			switch n := node.(type) {
+			case *Link:
+				res = append(res, strings.Join(append(path, n.Name), "/"))
+			case *Folder:
+				res = append(res, strings.Join(append(path, n.Name), "/")+"/")
+
+				if n.Name == skip {
+					return SkipFolder
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return strings.Join(res, " ")
+	}
+
+	tests := []struct {
+		order    WalkOrder
+		skip     string
+		expected string
+	}{
+		{DepthFirst, "", "r1 B/ B/b1 B/C/ B/C/c1 D/ D/d1"},
+		{BreadthFirst, "", "r1 B/ D/ B/b1 B/C/ D/d1 B/C/c1"},
+		{DepthFirst, "B", "r1 B/ D/ D/d1"},
+		{BreadthFirst, "B", "r1 B/ D/ D/d1"},
+	}
+
+	for _, test := range tests {
+		if s := walk(test.order, test.skip); s != test.expected {
+			t.Errorf("order %d, skip %q: got %q instead of %q", test.order, test.skip, s, test.expected)
+		}
+	}
+
+	// errors stop the walk
+	errStop := errors.New("stop")
+	n := 0
+
+	err := root.Walk(func(_ []string, _ interface{}) error {
+		if n++; n == 2 {
+			return errStop
+		}
+
+		return nil
+	})
+
+	if err != errStop || n != 2 {
+		t.Errorf("Unexpected result: %v after %d nodes", err, n)
+	}
+}
+
+func TestFindGlob(t *testing.T) {
+	news := &Folder{Node: Node{Name: "News"}, Links: []*Link{
+		{Node: Node{Name: "Go blog"}, URL: "https://go.dev/blog"},
+		{Node: Node{Name: "BBC"}, URL: "https://bbc.co.uk/"},
+	}}
+	bar := &Folder{Node: Node{Name: "Bookmarks bar"}, Folders: []*Folder{news}, Links: []*Link{
+		{Node: Node{Name: "Go"}, URL: "https://go.dev/"},
+	}}
+	root := &Folder{Folders: []*Folder{bar}}
+
+	if f, err := root.Find("/Bookmarks bar/News/"); err != nil || f != news {
+		t.Errorf("Unexpected result: %v, %v", f, err)
+	}
+
+	if _, err := root.Find("Bookmarks bar/Sport"); err == nil {
+		t.Error("Non-existent folder found")
+	}
+
+	tests := map[string]string{
+		"Bookmarks bar/*":      "https://go.dev/",
+		"Bookmarks bar/*/*":    "https://go.dev/blog https://bbc.co.uk/",
+		"*/*/Go*":              "https://go.dev/blog",
+		"Bookmarks bar/News/[": "",
+	}
+
+	for pattern, exp := range tests {
+		var urls []string
+
+		for _, link := range root.Glob(pattern) {
+			urls = append(urls, link.URL)
+		}
+
+		if s := strings.Join(urls, " "); s != exp {
+			t.Errorf("%q: got %q instead of %q", pattern, s, exp)
+		}
+	}
+
+	// --path option
+	opts := newOptions()
+	opts.paths = stringList{"*/News/B*", "*/Go"}
+
+	if err := opts.transform([]*Folder{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := linkLines(root); strings.Join(lines, " ") != "Bookmarks bar/Go <https://go.dev/> Bookmarks bar/News/BBC <https://bbc.co.uk/>" {
+		t.Errorf("Unexpected links: %q", lines)
+	}
+
+	opts.paths = stringList{"["}
+
+	if err := opts.transform([]*Folder{root}); err == nil {
+		t.Error("Invalid pattern accepted")
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerPushTarget("wallabag", "Save bookmarks as Wallabag articles, with folder names as tags", pushWallabag)
+}
+
+// "push wallabag" command
+func pushWallabag(args []string) error {
+	fs := newFlagSet("push wallabag", "")
+	opts := newPushOptions(fs)
+
+	var server string
+	var cred wallabagCredentials
+
+	fs.StringVar(&server, "server", "", "Wallabag server URL (default $WALLABAG_SERVER)")
+	fs.StringVar(&cred.clientID, "client-id", "", "API client id (default $WALLABAG_CLIENT_ID)")
+	fs.StringVar(&cred.clientSecret, "client-secret", "", "API client secret (default $WALLABAG_CLIENT_SECRET)")
+	fs.StringVar(&cred.username, "username", "", "User name (default $WALLABAG_USERNAME)")
+	fs.StringVar(&cred.password, "password", "", "Password (default $WALLABAG_PASSWORD)")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := noArgs(fs); err != nil {
+		return err
+	}
+
+	for _, c := range []struct {
+		value     *string
+		flag, env string
+	}{
+		{&server, "server", "WALLABAG_SERVER"},
+		{&cred.clientID, "client-id", "WALLABAG_CLIENT_ID"},
+		{&cred.clientSecret, "client-secret", "WALLABAG_CLIENT_SECRET"},
+		{&cred.username, "username", "WALLABAG_USERNAME"},
+		{&cred.password, "password", "WALLABAG_PASSWORD"},
+	} {
+		if err := credential(c.value, c.flag, c.env); err != nil {
+			return err
+		}
+	}
+
+	links, paths, err := opts.links()
+
+	if err != nil {
+		return err
+	}
+
+	wb := &wallabag{
+		server: strings.TrimRight(server, "/"),
+		client: opts.newWebClient(),
+	}
+
+	if err = wb.login(&cred); err != nil {
+		return err
+	}
+
+	var failed int
+	var mu sync.Mutex
+
+	err = forEachLink(links, opts.concurrency, func(link *Link) error {
+		if err := wb.add(link, paths[link]); err != nil {
+			if _, ok := err.(*HTTPError); !ok {
+				return err
+			}
+
+			logWarn("%s: %s", link.URL, err)
+
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			return nil
+		}
+
+		logInfo("%s: saved to Wallabag", link.URL)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed to save %d out of %d links to Wallabag", failed, len(links))
+	}
+
+	return nil
+}
+
+type wallabagCredentials struct {
+	clientID, clientSecret, username, password string
+}
+
+// Wallabag API client
+type wallabag struct {
+	server, token string
+	client        *webClient
+}
+
+// obtains OAuth access token
+func (wb *wallabag) login(cred *wallabagCredentials) error {
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	err := wb.postForm("/oauth/v2/token", url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {cred.clientID},
+		"client_secret": {cred.clientSecret},
+		"username":      {cred.username},
+		"password":      {cred.password},
+	}, &res)
+
+	if err != nil {
+		return err
+	}
+
+	if len(res.AccessToken) == 0 {
+		return errors.New("Wallabag has not issued an access token")
+	}
+
+	wb.token = res.AccessToken
+	return nil
+}
+
+// creates new entry
+func (wb *wallabag) add(link *Link, path []string) error {
+	params := url.Values{"url": {link.URL}}
+
+	if len(link.Name) > 0 {
+		params.Set("title", link.Name)
+	}
+
+	if tags := pathTags(path); len(tags) > 0 {
+		params.Set("tags", strings.Join(tags, ","))
+	}
+
+	return wb.postForm("/api/entries.json", params, nil)
+}
+
+func (wb *wallabag) postForm(path string, params url.Values, result interface{}) error {
+	req, err := http.NewRequest("POST", wb.server+path, strings.NewReader(params.Encode()))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if len(wb.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+wb.token)
+	}
+
+	resp, err := wb.client.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("Invalid response from Wallabag: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWallabag(t *testing.T) {
+	var tags string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/v2/token":
+			if r.FormValue("grant_type") != "password" || r.FormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			w.Write([]byte(`{"access_token": "token"}`))
+		case "/api/entries.json":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			tags = r.FormValue("tags")
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer srv.Close()
+
+	wb := &wallabag{server: srv.URL, client: newWebClient()}
+
+	if err := wb.login(&wallabagCredentials{"id", "secret", "user", "pass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := &Link{Node: Node{Name: "Article"}, URL: "https://example.com/article"}
+
+	if err := wb.add(link, []string{"Read Later", "Long"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags != "Read Later,Long" {
+		t.Fatalf("Unexpected tags: %q", tags)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/gnuflag"
+)
+
+func (opts *options) warcFlag(fs *gnuflag.FlagSet) {
+	fs.StringVar(&opts.warcName, "warc", "",
+		"Record all HTTP requests and responses into this WARC file (compressed if the name ends with .gz), appending to it if it exists")
+}
+
+// opens the WARC file given by --warc, if any, for the web clients to record into;
+// the returned function closes the file
+func (opts *options) openWARC() (func() error, error) {
+	if len(opts.warcName) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	w, err := createWARC(opts.warcName, time.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.warc = w
+	return w.close, nil
+}
+
+// writer of WARC 1.1 records, safe for concurrent use
+type warcWriter struct {
+	lock     sync.Mutex
+	file     *os.File
+	compress bool
+	err      error // first write error
+}
+
+func createWARC(name string, now time.Time) (*warcWriter, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &warcWriter{file: file, compress: strings.HasSuffix(name, ".gz")}
+	info := "software: " + programName + "\r\nformat: WARC File Format 1.1\r\n"
+
+	w.write(now, "warcinfo", warcRecordID(), "application/warc-fields", []byte(info), nil)
+
+	if w.err != nil {
+		file.Close()
+		return nil, w.err
+	}
+
+	return w, nil
+}
+
+func (w *warcWriter) close() error {
+	err := w.file.Close()
+
+	if w.err != nil {
+		err = w.err
+	}
+
+	return err
+}
+
+// records the request and the response, which must not have been read yet; the response body is read
+// up to maxResourceSize, and replaced with the data read
+func (w *warcWriter) record(resp *http.Response) {
+	now := time.Now()
+
+	req, err := httputil.DumpRequestOut(resp.Request, false)
+
+	if err != nil {
+		logWarn("%s", err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResourceSize+1))
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	extra := http.Header{}
+
+	if len(body) > maxResourceSize {
+		body = body[:maxResourceSize]
+		extra.Set("WARC-Truncated", "length")
+	} else if err != nil {
+		extra.Set("WARC-Truncated", "disconnect")
+	}
+
+	// the body may have been decompressed by the client, so the headers are made to match it
+	header := resp.Header.Clone()
+
+	header.Del("Transfer-Encoding")
+
+	if resp.Uncompressed {
+		header.Del("Content-Encoding")
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	var block bytes.Buffer
+
+	fmt.Fprintf(&block, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	header.Write(&block)
+	block.WriteString("\r\n")
+	block.Write(body)
+
+	target := urlString(resp.Request.URL)
+	id := warcRecordID()
+
+	extra.Set("WARC-Target-URI", target)
+	extra.Set("WARC-Payload-Digest", warcDigest(body))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.write(now, "response", id, "application/http;msgtype=response", block.Bytes(), extra)
+
+	extra = http.Header{}
+	extra.Set("WARC-Target-URI", target)
+	extra.Set("WARC-Concurrent-To", id)
+	w.write(now, "request", warcRecordID(), "application/http;msgtype=request", req, extra)
+}
+
+// writes one record; the caller holds the lock
+func (w *warcWriter) write(now time.Time, typ, id, contentType string, block []byte, extra http.Header) {
+	if w.err != nil {
+		return
+	}
+
+	var rec bytes.Buffer
+
+	rec.WriteString("WARC/1.1\r\n")
+	rec.WriteString("WARC-Type: " + typ + "\r\n")
+	rec.WriteString("WARC-Date: " + now.UTC().Format(time.RFC3339) + "\r\n")
+	rec.WriteString("WARC-Record-ID: " + id + "\r\n")
+
+	for _, k := range []string{"WARC-Target-URI", "WARC-Concurrent-To", "WARC-Truncated", "WARC-Payload-Digest"} {
+		if v := extra.Get(k); len(v) > 0 {
+			rec.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+
+	rec.WriteString("WARC-Block-Digest: " + warcDigest(block) + "\r\n")
+	rec.WriteString("Content-Type: " + contentType + "\r\n")
+	rec.WriteString("Content-Length: " + strconv.Itoa(len(block)) + "\r\n\r\n")
+	rec.Write(block)
+	rec.WriteString("\r\n\r\n")
+
+	data := rec.Bytes()
+
+	// compressed files have a gzip member per record
+	if w.compress {
+		var buf bytes.Buffer
+
+		z := gzip.NewWriter(&buf)
+
+		z.Write(data)
+		z.Close()
+		data = buf.Bytes()
+	}
+
+	_, w.err = w.file.Write(data)
+}
+
+func warcRecordID() string {
+	var b [16]byte
+
+	rand.Read(b[:])
+
+	// random UUID, version 4
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWARC(t *testing.T) {
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "Hello")
+	}))
+
+	defer web.Close()
+
+	for _, name := range []string{"test.warc", "test.warc.gz"} {
+		name = filepath.Join(t.TempDir(), name)
+		w, err := createWARC(name, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client := newWebClient()
+		client.warc = w
+
+		for _, path := range []string{"/page", "/none"} {
+			req, err := http.NewRequest("GET", web.URL+path, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.do(req)
+
+			if path == "/none" {
+				if err == nil {
+					t.Fatal("Missing error")
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the body is still readable
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if err != nil || string(body) != "Hello" {
+				t.Fatalf("Unexpected body %q, %v", body, err)
+			}
+		}
+
+		if err = w.close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data := readWARC(t, name)
+		types := regexp.MustCompile(`WARC-Type: (\w+)`).FindAllStringSubmatch(data, -1)
+
+		if len(types) != 5 || types[0][1] != "warcinfo" || types[1][1] != "response" || types[2][1] != "request" {
+			t.Fatalf("%s: unexpected records %q", name, types)
+		}
+
+		for _, exp := range []string{
+			"WARC-Target-URI: " + web.URL + "/page\r\n",
+			"Content-Type: application/http;msgtype=response\r\n",
+			"HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n",
+			"\r\n\r\nHello\r\n\r\n",
+			"GET /page HTTP/1.1\r\n",
+			"HTTP/1.1 404 Not Found\r\n",
+		} {
+			if !strings.Contains(data, exp) {
+				t.Errorf("%s: missing %q in:\n%s", name, exp, data)
+			}
+		}
+	}
+}
+
+func readWARC(t *testing.T, name string) string {
+	file, err := os.Open(name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	if !strings.HasSuffix(name, ".gz") {
+		data, err := ioutil.ReadAll(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	z, err := gzip.NewReader(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(z)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
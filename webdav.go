@@ -0,0 +1,232 @@
+/*
+Copyright (c) 2016, Maxim Konakov
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software without
+   specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED.
+IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT,
+INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY
+OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+func init() {
+	registerCommand("sync", "Upload the exported file to a WebDAV server, like Nextcloud", syncCmd)
+}
+
+// "sync" command
+func syncCmd(args []string) error {
+	start := time.Now()
+	opts := newOptions()
+	fs := newFlagSet("sync", "webdav[s]://HOST/PATH")
+
+	opts.inputFlags(fs)
+	opts.outputFlags(fs)
+	opts.treeFlags(fs)
+	opts.pageFlags(fs)
+	opts.networkFlags(fs)
+	opts.logFlags(fs)
+
+	var username, password, download string
+
+	fs.StringVar(&username, "username", "", "WebDAV user name (default is from the URL, or $WEBDAV_USERNAME)")
+	fs.StringVar(&password, "password", "", "WebDAV password (default $WEBDAV_PASSWORD)")
+	fs.StringVar(&download, "download", "", "Save the remote copy, if any, to this file before replacing it")
+
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("Exactly one WebDAV URL is expected")
+	}
+
+	target, user, err := webdavURL(fs.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	if len(username) == 0 {
+		username = user.Username()
+	}
+
+	if len(username) == 0 {
+		username = os.Getenv("WEBDAV_USERNAME")
+	}
+
+	if p, ok := user.Password(); ok && len(password) == 0 {
+		password = p
+	}
+
+	if len(password) == 0 {
+		password = os.Getenv("WEBDAV_PASSWORD")
+	}
+
+	// export
+	roots, err := opts.loadInputs()
+
+	if err != nil {
+		return err
+	}
+
+	if err = opts.transform(roots); err != nil {
+		return err
+	}
+
+	folders := topFolders(roots)
+
+	var buff bytes.Buffer
+
+	err = writeOutput(&buff, opts.compress, opts.passphrase, func(out StringWriter) error {
+		return formats[opts.format].Write(folders, opts, out)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// local copy
+	if opts.outputName != stdout {
+		if err = ioutil.WriteFile(opts.outputName, buff.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	// upload
+	dav := &webdavClient{opts.newWebClient(), username, password}
+
+	if len(download) > 0 {
+		if err = dav.download(target, download); err != nil {
+			return err
+		}
+	}
+
+	if err = dav.upload(target, buff.Bytes()); err != nil {
+		return err
+	}
+
+	logInfo("uploaded %s in %s", target, time.Since(start))
+	return nil
+}
+
+// converts "webdav://" and "webdavs://" URL to "http://" or "https://", separating the credentials
+func webdavURL(s string) (string, *url.Userinfo, error) {
+	u, err := url.Parse(s)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch u.Scheme {
+	case "webdav", "http":
+		u.Scheme = "http"
+	case "webdavs", "https":
+		u.Scheme = "https"
+	default:
+		return "", nil, fmt.Errorf("Unsupported WebDAV URL %q", s)
+	}
+
+	if len(u.Host) == 0 || len(u.Path) == 0 || u.Path[len(u.Path)-1] == '/' {
+		return "", nil, fmt.Errorf("WebDAV URL %q must include the file name", s)
+	}
+
+	user := u.User
+
+	if user == nil {
+		user = url.User("")
+	}
+
+	u.User = nil
+	return u.String(), user, nil
+}
+
+// WebDAV client with basic authentication
+type webdavClient struct {
+	client             *webClient
+	username, password string
+}
+
+func (dav *webdavClient) do(method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dav.username) > 0 {
+		req.SetBasicAuth(dav.username, dav.password)
+	}
+
+	resp, err := dav.client.do(req)
+
+	if e, ok := err.(*HTTPError); ok && e.Status == http.StatusUnauthorized {
+		return nil, errors.New("WebDAV: invalid user name or password")
+	}
+
+	return resp, err
+}
+
+// saves the remote file, if it exists
+func (dav *webdavClient) download(target, name string) error {
+	resp, err := dav.do("GET", target, nil)
+
+	if e, ok := err.(*HTTPError); ok && e.Status == http.StatusNotFound {
+		logInfo("%s does not exist yet", target)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// puts the file to the server, replacing the existing one
+func (dav *webdavClient) upload(target string, data []byte) error {
+	resp, err := dav.do("PUT", target, data)
+
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}